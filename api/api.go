@@ -0,0 +1,12 @@
+// Package api holds the published contracts of the service. The files are
+// generated from internal/model by internal/apispec; run `make gen-api` after
+// changing the model.
+package api
+
+import _ "embed"
+
+//go:embed order.schema.json
+var OrderSchema []byte
+
+//go:embed openapi.json
+var OpenAPI []byte
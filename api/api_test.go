@@ -0,0 +1,41 @@
+package api
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+
+	"orderservice/internal/apispec"
+)
+
+var update = flag.Bool("update", false, "rewrite the published specs from the model")
+
+func TestPublishedSpecsMatchModel(t *testing.T) {
+	cases := []struct {
+		file      string
+		published []byte
+		generate  func() map[string]any
+	}{
+		{"order.schema.json", OrderSchema, apispec.OrderSchema},
+		{"openapi.json", OpenAPI, apispec.OpenAPI},
+	}
+
+	for _, tc := range cases {
+		want, err := apispec.Marshal(tc.generate())
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", tc.file, err)
+		}
+
+		if *update {
+			if err := os.WriteFile(tc.file, want, 0o644); err != nil {
+				t.Fatalf("%s: write: %v", tc.file, err)
+			}
+			continue
+		}
+
+		if !bytes.Equal(tc.published, want) {
+			t.Errorf("%s is out of date with internal/model; run `make gen-api`", tc.file)
+		}
+	}
+}
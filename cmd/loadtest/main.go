@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"orderservice/config"
+	"orderservice/pkg/generator"
+
+	"github.com/segmentio/kafka-go"
+	"golang.org/x/time/rate"
+)
+
+type options struct {
+	count        int
+	rate         float64
+	publishers   int
+	pollers      int
+	apiKey       string
+	baseURL      string
+	pollInterval time.Duration
+	timeout      time.Duration
+	seed         int64
+	out          string
+	label        string
+}
+
+func parseFlags() options {
+	var o options
+	flag.IntVar(&o.count, "count", 1000, "number of orders to publish")
+	flag.Float64Var(&o.rate, "rate", 20, "target publish rate, messages per second")
+	flag.IntVar(&o.publishers, "publishers", 4, "number of concurrent Kafka publishers")
+	flag.IntVar(&o.pollers, "pollers", 4, "maximum number of HTTP polls in flight")
+	flag.StringVar(&o.apiKey, "api-key", "", "X-API-Key sent with every HTTP request; one of RATE_LIMIT_API_KEYS gets a rate limit bucket of its own")
+	flag.StringVar(&o.baseURL, "url", "http://localhost:8080", "order service base URL")
+	flag.DurationVar(&o.pollInterval, "poll-interval", 100*time.Millisecond, "delay between polls of the same order")
+	flag.DurationVar(&o.timeout, "timeout", 30*time.Second, "how long to wait for an order to become visible")
+	flag.Int64Var(&o.seed, "seed", 1, "generator seed")
+	flag.StringVar(&o.out, "out", "loadtest-report.json", "path of the JSON report, - for stdout")
+	flag.StringVar(&o.label, "label", "", "free-form label stored in the report, e.g. a commit hash")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), `Usage: loadtest [flags]
+
+Every published order is polled on its own every poll-interval until it is
+visible, so polls run at about rate * visibility latency / poll-interval
+requests per second from a single client, with at most pollers in flight.
+With the defaults that stays under the service's default rate limit of 50 rps
+while orders become visible within 250ms. For heavier runs raise RATE_LIMIT_RPS / RATE_LIMIT_BURST or
+RATE_LIMIT_ROUTES for /orders/{id} on the service, or pass -api-key with a
+key listed in RATE_LIMIT_API_KEYS; otherwise polls get 429 and the report
+measures the limiter.
+
+Flags:
+`)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	return o
+}
+
+func main() {
+	opts := parseFlags()
+	if opts.count <= 0 || opts.rate <= 0 || opts.publishers <= 0 || opts.pollers <= 0 {
+		log.Fatalf("invalid flags: -count, -rate, -publishers and -pollers must be positive")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	genOpts := generator.DefaultOptions()
+	genOpts.Seed = uint64(opts.seed)
+	gen, err := generator.New(genOpts)
+	if err != nil {
+		log.Fatalf("failed to create generator: %v", err)
+	}
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers()...),
+		Topic:        cfg.KafkaOrderTopic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 5 * time.Millisecond,
+	}
+	defer writer.Close()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	p := &poller{
+		client:   client,
+		baseURL:  strings.TrimRight(opts.baseURL, "/"),
+		apiKey:   opts.apiKey,
+		interval: opts.pollInterval,
+		timeout:  opts.timeout,
+		slots:    make(chan struct{}, opts.pollers),
+	}
+
+	var (
+		rec       = newRecorder()
+		pending   = make(chan published, opts.count)
+		pollWG    sync.WaitGroup
+		publishWG sync.WaitGroup
+		limiter   = rate.NewLimiter(rate.Limit(opts.rate), 1)
+		remaining atomic.Int64
+		start     = time.Now()
+	)
+	remaining.Store(int64(opts.count))
+
+	// Each order gets its own poller, so an order is first polled right after
+	// it is published rather than once an earlier one becomes visible.
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		for pub := range pending {
+			pollWG.Add(1)
+			go func() {
+				defer pollWG.Done()
+				p.waitVisible(ctx, pub, rec)
+			}()
+		}
+	}()
+
+	for i := 0; i < opts.publishers; i++ {
+		publishWG.Add(1)
+		go func() {
+			defer publishWG.Done()
+			for remaining.Add(-1) >= 0 {
+				if err := limiter.Wait(ctx); err != nil {
+					return
+				}
+				publish(ctx, writer, gen, rec, pending)
+			}
+		}()
+	}
+	publishWG.Wait()
+	publishDone := time.Since(start)
+	close(pending)
+	<-dispatched
+	pollWG.Wait()
+
+	rep := rec.report(opts, publishDone, time.Since(start))
+	if err := writeReport(opts.out, rep); err != nil {
+		log.Fatalf("failed to write report: %v", err)
+	}
+	log.Printf("published %d/%d, visible %d, timed out %d; ingest p50=%.1fms p99=%.1fms; http p99=%.1fms; poll wait p99=%.1fms",
+		rep.Published, opts.count, rep.Visible, rep.Errors.VisibilityTimeouts,
+		rep.IngestToVisibleMS.P50, rep.IngestToVisibleMS.P99, rep.HTTPLatencyMS.P99, rep.PollWaitMS.P99)
+}
+
+func publish(ctx context.Context, writer *kafka.Writer, gen *generator.Generator, rec *recorder, pending chan<- published) {
+	ord := gen.Order()
+	data, err := json.Marshal(ord)
+	if err != nil {
+		rec.publishError(err)
+		return
+	}
+
+	sentAt := time.Now()
+	err = writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(ord.OrderUID),
+		Value: data,
+		Headers: []kafka.Header{
+			{Key: "content-type", Value: []byte("application/json")},
+		},
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			rec.publishError(err)
+		}
+		return
+	}
+	rec.published(time.Since(sentAt))
+	pending <- published{orderUID: ord.OrderUID, sentAt: sentAt}
+}
+
+type published struct {
+	orderUID string
+	sentAt   time.Time
+}
+
+type poller struct {
+	client   *http.Client
+	baseURL  string
+	apiKey   string
+	interval time.Duration
+	timeout  time.Duration
+	// slots caps the polls in flight; the time spent waiting for one is
+	// reported as poll_wait_ms.
+	slots chan struct{}
+}
+
+// waitVisible polls GET /orders/{id} until the order is served or the
+// timeout expires, recording every request's latency.
+func (p *poller) waitVisible(ctx context.Context, pub published, rec *recorder) {
+	deadline := pub.sentAt.Add(p.timeout)
+	url := p.baseURL + "/orders/" + pub.orderUID
+
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			rec.httpError(err)
+			return
+		}
+		if p.apiKey != "" {
+			req.Header.Set("X-API-Key", p.apiKey)
+		}
+
+		waitStart := time.Now()
+		select {
+		case <-ctx.Done():
+			return
+		case p.slots <- struct{}{}:
+		}
+		rec.pollWait(time.Since(waitStart))
+
+		reqStart := time.Now()
+		resp, err := p.client.Do(req)
+		<-p.slots
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			rec.httpError(err)
+		} else {
+			resp.Body.Close()
+			rec.httpRequest(time.Since(reqStart), resp.StatusCode)
+			if resp.StatusCode == http.StatusOK {
+				rec.visible(time.Since(pub.sentAt))
+				return
+			}
+		}
+
+		if time.Now().After(deadline) {
+			rec.visibilityTimeout()
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.interval):
+		}
+	}
+}
+
+func writeReport(path string, rep report) error {
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
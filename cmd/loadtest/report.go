@@ -0,0 +1,171 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type percentiles struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+type errorCounts struct {
+	Publish            int            `json:"publish"`
+	HTTP               int            `json:"http"`
+	VisibilityTimeouts int            `json:"visibility_timeouts"`
+	HTTPStatus         map[string]int `json:"http_status"`
+}
+
+type report struct {
+	Label     string    `json:"label,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+
+	TargetRate       float64 `json:"target_rate"`
+	Requested        int     `json:"requested"`
+	Published        int     `json:"published"`
+	Visible          int     `json:"visible"`
+	PublishDurationS float64 `json:"publish_duration_s"`
+	TotalDurationS   float64 `json:"total_duration_s"`
+	PublishRate      float64 `json:"publish_rate"`
+	VisibleRate      float64 `json:"visible_rate"`
+
+	PublishLatencyMS  percentiles `json:"publish_latency_ms"`
+	IngestToVisibleMS percentiles `json:"ingest_to_visible_ms"`
+	HTTPLatencyMS     percentiles `json:"http_latency_ms"`
+	// PollWaitMS is how long polls waited for a free slot; a high value means
+	// -pollers, not the service, held back ingest_to_visible_ms.
+	PollWaitMS percentiles `json:"poll_wait_ms"`
+
+	Errors errorCounts `json:"errors"`
+}
+
+type recorder struct {
+	mu        sync.Mutex
+	startedAt time.Time
+	publish   []time.Duration
+	ingest    []time.Duration
+	http      []time.Duration
+	waits     []time.Duration
+	errors    errorCounts
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		startedAt: time.Now(),
+		errors:    errorCounts{HTTPStatus: make(map[string]int)},
+	}
+}
+
+func (r *recorder) published(d time.Duration) {
+	r.mu.Lock()
+	r.publish = append(r.publish, d)
+	r.mu.Unlock()
+}
+
+func (r *recorder) publishError(err error) {
+	r.mu.Lock()
+	r.errors.Publish++
+	r.mu.Unlock()
+}
+
+func (r *recorder) visible(d time.Duration) {
+	r.mu.Lock()
+	r.ingest = append(r.ingest, d)
+	r.mu.Unlock()
+}
+
+func (r *recorder) visibilityTimeout() {
+	r.mu.Lock()
+	r.errors.VisibilityTimeouts++
+	r.mu.Unlock()
+}
+
+func (r *recorder) httpRequest(d time.Duration, status int) {
+	r.mu.Lock()
+	r.http = append(r.http, d)
+	r.errors.HTTPStatus[strconv.Itoa(status)]++
+	r.mu.Unlock()
+}
+
+func (r *recorder) pollWait(d time.Duration) {
+	r.mu.Lock()
+	r.waits = append(r.waits, d)
+	r.mu.Unlock()
+}
+
+func (r *recorder) httpError(err error) {
+	r.mu.Lock()
+	r.errors.HTTP++
+	r.mu.Unlock()
+}
+
+func (r *recorder) report(o options, publishDur, totalDur time.Duration) report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rep := report{
+		Label:             o.label,
+		StartedAt:         r.startedAt.UTC(),
+		TargetRate:        o.rate,
+		Requested:         o.count,
+		Published:         len(r.publish),
+		Visible:           len(r.ingest),
+		PublishDurationS:  publishDur.Seconds(),
+		TotalDurationS:    totalDur.Seconds(),
+		PublishLatencyMS:  summarize(r.publish),
+		IngestToVisibleMS: summarize(r.ingest),
+		HTTPLatencyMS:     summarize(r.http),
+		PollWaitMS:        summarize(r.waits),
+		Errors:            r.errors,
+	}
+	if publishDur > 0 {
+		rep.PublishRate = float64(rep.Published) / publishDur.Seconds()
+	}
+	if totalDur > 0 {
+		rep.VisibleRate = float64(rep.Visible) / totalDur.Seconds()
+	}
+	return rep
+}
+
+func summarize(samples []time.Duration) percentiles {
+	if len(samples) == 0 {
+		return percentiles{}
+	}
+	ms := make([]float64, len(samples))
+	var sum float64
+	for i, d := range samples {
+		ms[i] = float64(d) / float64(time.Millisecond)
+		sum += ms[i]
+	}
+	sort.Float64s(ms)
+
+	return percentiles{
+		Count: len(ms),
+		Min:   ms[0],
+		Mean:  sum / float64(len(ms)),
+		P50:   quantile(ms, 0.50),
+		P90:   quantile(ms, 0.90),
+		P95:   quantile(ms, 0.95),
+		P99:   quantile(ms, 0.99),
+		Max:   ms[len(ms)-1],
+	}
+}
+
+// quantile uses the nearest-rank method on sorted values.
+func quantile(sorted []float64, q float64) float64 {
+	idx := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
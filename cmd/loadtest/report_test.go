@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuantile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for _, tc := range []struct {
+		q    float64
+		want float64
+	}{
+		{0, 1},
+		{0.1, 1},
+		{0.11, 2},
+		{0.5, 5},
+		{0.9, 9},
+		{0.95, 10},
+		{0.99, 10},
+		{1, 10},
+	} {
+		if got := quantile(sorted, tc.q); got != tc.want {
+			t.Errorf("q=%v: expected %v, got %v", tc.q, tc.want, got)
+		}
+	}
+	if got := quantile([]float64{42}, 0.99); got != 42 {
+		t.Errorf("expected the single sample, got %v", got)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	if got := summarize(nil); got != (percentiles{}) {
+		t.Fatalf("expected zero percentiles for no samples, got %+v", got)
+	}
+
+	var samples []time.Duration
+	for i := 100; i >= 1; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	got := summarize(samples)
+	want := percentiles{Count: 100, Min: 1, Mean: 50.5, P50: 50, P90: 90, P95: 95, P99: 99, Max: 100}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if samples[0] != 100*time.Millisecond {
+		t.Fatal("expected the samples to be left unsorted")
+	}
+
+	got = summarize([]time.Duration{1500 * time.Microsecond})
+	if got.P50 != 1.5 || got.Max != 1.5 {
+		t.Fatalf("expected fractional milliseconds, got %+v", got)
+	}
+}
+
+func TestRecorder_Report(t *testing.T) {
+	r := newRecorder()
+	for range 10 {
+		r.published(time.Millisecond)
+	}
+	for range 8 {
+		r.visible(20 * time.Millisecond)
+	}
+	r.httpRequest(time.Millisecond, 404)
+	r.httpRequest(time.Millisecond, 200)
+	r.httpRequest(time.Millisecond, 429)
+	r.httpRequest(time.Millisecond, 429)
+	r.pollWait(0)
+	r.pollWait(3 * time.Millisecond)
+	r.visibilityTimeout()
+	r.publishError(nil)
+
+	rep := r.report(options{count: 12, rate: 20, label: "abc"}, 2*time.Second, 4*time.Second)
+	if rep.Published != 10 || rep.Visible != 8 || rep.Requested != 12 || rep.Label != "abc" {
+		t.Fatalf("unexpected counts %+v", rep)
+	}
+	if rep.PublishRate != 5 || rep.VisibleRate != 2 {
+		t.Fatalf("expected 5 published and 2 visible per second, got %v and %v", rep.PublishRate, rep.VisibleRate)
+	}
+	if rep.HTTPLatencyMS.Count != 4 || rep.Errors.HTTPStatus["429"] != 2 || rep.Errors.HTTPStatus["200"] != 1 {
+		t.Fatalf("unexpected http stats %+v, %+v", rep.HTTPLatencyMS, rep.Errors)
+	}
+	if rep.PollWaitMS.Count != 2 || rep.PollWaitMS.Max != 3 {
+		t.Fatalf("unexpected poll wait stats %+v", rep.PollWaitMS)
+	}
+	if rep.Errors.VisibilityTimeouts != 1 || rep.Errors.Publish != 1 {
+		t.Fatalf("unexpected errors %+v", rep.Errors)
+	}
+
+	rep = r.report(options{}, 0, 0)
+	if rep.PublishRate != 0 || rep.VisibleRate != 0 {
+		t.Fatalf("expected zero rates for zero durations, got %+v", rep)
+	}
+}
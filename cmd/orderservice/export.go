@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"orderservice/config"
+	"orderservice/internal/di"
+	"orderservice/internal/export"
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+	"orderservice/pkg/connectors"
+)
+
+func runExport(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatFlag := fs.String("format", "csv", "output format: csv, jsonl or parquet")
+	fromFlag := fs.String("from", "", "export orders created at or after this date (YYYY-MM-DD or RFC 3339)")
+	toFlag := fs.String("to", "", "export orders created before this date (YYYY-MM-DD or RFC 3339)")
+	out := fs.String("o", "-", "output file, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := export.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+	from, err := parseExportTime(*fromFlag)
+	if err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	to, err := parseExportTime(*toFlag)
+	if err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+
+	opts, err := di.PIIOptions(cfg)
+	if err != nil {
+		return err
+	}
+	db, err := connectors.ConnectPostgres(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var (
+		dst  io.Writer = os.Stdout
+		file *os.File
+	)
+	if *out != "-" {
+		if file, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer file.Close()
+		dst = file
+	}
+	buf := bufio.NewWriterSize(dst, 1<<16)
+
+	w, err := export.NewWriter(buf, format)
+	if err != nil {
+		return err
+	}
+	n := 0
+	err = repo.NewRepo(db, opts...).ExportOrders(ctx, from, to, func(o *model.Order) error {
+		n++
+		return w.Write(o)
+	})
+	if err != nil {
+		return fmt.Errorf("after %d orders: %w", n, err)
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "export: %d orders written\n", n)
+	return nil
+}
+
+func parseExportTime(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("want YYYY-MM-DD or RFC 3339, got %q", v)
+		}
+	}
+	t = t.UTC()
+	return &t, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"orderservice/config"
+	"orderservice/internal/audit"
+	"orderservice/internal/di"
+	"orderservice/internal/export"
+	"orderservice/internal/importer"
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+	"orderservice/pkg/connectors"
+)
+
+func runImport(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatFlag := fs.String("format", "", "input format: jsonl or csv; by default taken from the file extension")
+	batch := fs.Int("batch", 1000, "orders per transaction")
+	rejects := fs.String("rejects", "", "reject file, <input>.rejects.jsonl by default")
+	checkpoint := fs.String("checkpoint", "", "checkpoint file, <input>.checkpoint by default")
+	restart := fs.Bool("restart", false, "ignore an existing checkpoint and start from the beginning")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: import [flags] <file>")
+	}
+	input := fs.Arg(0)
+
+	if *formatFlag == "" {
+		*formatFlag = strings.TrimPrefix(filepath.Ext(input), ".")
+	}
+	format, err := export.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+	if *rejects == "" {
+		*rejects = input + ".rejects.jsonl"
+	}
+	if *checkpoint == "" {
+		*checkpoint = input + ".checkpoint"
+	}
+
+	opts, err := di.PIIOptions(cfg)
+	if err != nil {
+		return err
+	}
+	db, err := connectors.ConnectPostgres(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx = audit.WithSource(ctx, model.AuditSource{Kind: model.SourceSystem, Principal: "import"})
+	sum, err := importer.Run(ctx, repo.NewImporter(db, opts...), input, importer.Options{
+		Format:     format,
+		BatchSize:  *batch,
+		Rejects:    *rejects,
+		Checkpoint: *checkpoint,
+		Restart:    *restart,
+	})
+	if sum != nil {
+		resumed := ""
+		if sum.Resumed {
+			resumed = ", resumed from checkpoint"
+		}
+		fmt.Fprintf(os.Stderr, "import: %d imported, %d already stored, %d rejected, %d lines read%s\n",
+			sum.Imported, sum.Existing, sum.Rejected, sum.Lines, resumed)
+		if sum.Rejected > 0 {
+			fmt.Fprintf(os.Stderr, "import: rejects written to %s\n", *rejects)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%w; rerun to resume from %s", err, *checkpoint)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"os/signal"
 	"syscall"
@@ -10,20 +11,56 @@ import (
 	"orderservice/config"
 	ctrlhttp "orderservice/internal/controller/http"
 	"orderservice/internal/di"
+	"orderservice/internal/lifecycle"
 
 	"go.uber.org/zap"
 )
 
 func main() {
+	dev := flag.Bool("dev", false, "run with in-memory storage and HTTP ingest, without Postgres and Kafka")
+	flag.Parse()
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	if *dev {
+		cfg.EnableDevMode()
+	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	logger, err := zap.NewProduction()
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(ctx, cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+	if flag.Arg(0) == "export" {
+		if err := runExport(ctx, cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("export: %v", err)
+		}
+		return
+	}
+	if flag.Arg(0) == "import" {
+		if err := runImport(ctx, cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("import: %v", err)
+		}
+		return
+	}
+	if flag.Arg(0) == "pii" {
+		if err := runPII(ctx, cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("pii: %v", err)
+		}
+		return
+	}
+
+	newLogger := zap.NewProduction
+	if cfg.DevMode {
+		newLogger = zap.NewDevelopment
+	}
+	logger, err := newLogger()
 	if err != nil {
 		log.Fatalf("Failed to create logger: %v", err)
 	}
@@ -32,29 +69,90 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to initialize app: %v", err)
 	}
-	defer container.DB.Close()
-
-	go func() {
-		if err := container.Kafka.Start(ctx); err != nil {
-			log.Printf("main: kafka controller stopped: %v\n", err)
-		}
-	}()
 
 	server := ctrlhttp.NewServer(logger, container.Router, cfg.HTTPPort)
-	server.Start()
-
-	<-ctx.Done()
-	log.Println("main: shutting down")
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Printf("http server shutdown error: %v", err)
+	lc := lifecycle.NewManager(logger)
+	if container.DB != nil {
+		lc.Add(lifecycle.Component{
+			Name: "postgres",
+			Stop: func(ctx context.Context) error {
+				container.DB.Close()
+				return nil
+			},
+			StopTimeout: 5 * time.Second,
+		})
+	}
+	lc.Add(lifecycle.Component{
+		Name: "cache",
+		Stop: func(ctx context.Context) error {
+			container.Cache.Close()
+			return nil
+		},
+		StopTimeout: time.Second,
+	})
+	if container.Outbox != nil {
+		lc.Add(lifecycle.Component{
+			Name:        "kafka events writer",
+			Stop:        func(ctx context.Context) error { return container.Events.Close() },
+			StopTimeout: 5 * time.Second,
+		})
+		lc.Add(lifecycle.Component{
+			Name:        "outbox relay",
+			Start:       container.Outbox.Start,
+			Stop:        container.Outbox.Stop,
+			StopTimeout: 10 * time.Second,
+		})
+	}
+	if container.Retention != nil {
+		lc.Add(lifecycle.Component{
+			Name:        "retention job",
+			Start:       container.Retention.Start,
+			Stop:        container.Retention.Stop,
+			StopTimeout: 30 * time.Second,
+		})
 	}
+	if container.Reports != nil {
+		lc.Add(lifecycle.Component{
+			Name:        "report views refresher",
+			Start:       container.Reports.Start,
+			Stop:        container.Reports.Stop,
+			StopTimeout: 30 * time.Second,
+		})
+	}
+	lc.Add(lifecycle.Component{
+		Name:        "http server",
+		Start:       func(ctx context.Context) error { return server.Start() },
+		Stop:        server.Shutdown,
+		StopTimeout: cfg.HTTPShutdownTimeout,
+	})
+	// Components stop in reverse order: the consumer first, then the retry
+	// and DLQ writer it used, then HTTP.
+	if container.Consumer != nil {
+		lc.Add(lifecycle.Component{
+			Name:        "kafka writer",
+			Stop:        func(ctx context.Context) error { return container.Consumer.CloseWriter() },
+			StopTimeout: 5 * time.Second,
+		})
+	}
+	lc.Add(lifecycle.Component{
+		Name:        "kafka consumer",
+		Start:       container.Kafka.Start,
+		Stop:        container.Kafka.Stop,
+		StopTimeout: cfg.KafkaShutdownTimeout,
+	})
 
-	if err := container.Kafka.Stop(ctx); err != nil {
-		log.Printf("kafka stop error: %v", err)
+	if err := lc.Start(ctx); err != nil {
+		log.Fatalf("failed to start app: %v", err)
 	}
 
-	container.Cache.Close()
+	<-ctx.Done()
+	log.Println("main: shutting down")
+	container.Health.SetShuttingDown()
+
+	if err := lc.Stop(context.Background()); err != nil {
+		log.Printf("main: shutdown completed with errors: %v", err)
+		return
+	}
+	log.Println("main: shutdown complete")
 }
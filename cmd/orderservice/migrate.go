@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"orderservice/config"
+	"orderservice/internal/infrastructure/migrate"
+)
+
+const migrateUsage = "usage: orderservice migrate up|down|status|redo"
+
+func runMigrate(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	m, err := migrate.Open(cfg.PostgresDSN)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		return m.Up(ctx, os.Stdout)
+	case "down":
+		return m.Down(ctx, os.Stdout)
+	case "status":
+		return m.Status(ctx, os.Stdout)
+	case "redo":
+		return m.Redo(ctx, os.Stdout)
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+}
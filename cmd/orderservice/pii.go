@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"orderservice/config"
+	"orderservice/internal/di"
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/pkg/connectors"
+)
+
+const piiUsage = "usage: orderservice pii rotate|encrypt|decrypt"
+
+const piiBatchSize = 500
+
+func runPII(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New(piiUsage)
+	}
+
+	opts, err := di.PIIOptions(cfg)
+	if err != nil {
+		return err
+	}
+	db, err := connectors.ConnectPostgres(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	k := repo.NewKeyMaintainer(db, opts...)
+
+	var step func(context.Context, int) (int, error)
+	switch args[0] {
+	case "rotate":
+		step = k.Rewrap
+	case "encrypt":
+		step = k.Encrypt
+	case "decrypt":
+		step = k.Decrypt
+	default:
+		return fmt.Errorf("unknown pii command %q\n%s", args[0], piiUsage)
+	}
+
+	total := 0
+	for {
+		n, err := step(ctx, piiBatchSize)
+		if err != nil {
+			return fmt.Errorf("after %d rows: %w", total, err)
+		}
+		if n == 0 {
+			break
+		}
+		total += n
+	}
+	fmt.Printf("pii %s: %d rows updated\n", args[0], total)
+	return nil
+}
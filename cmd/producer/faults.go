@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"orderservice/internal/model"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type faultRates struct {
+	invalidEmail float64
+	zeroAmount   float64
+	malformed    float64
+	duplicate    float64
+}
+
+func (f faultRates) validate() error {
+	for name, v := range map[string]float64{
+		"-fault-invalid-email": f.invalidEmail,
+		"-fault-zero-amount":   f.zeroAmount,
+		"-fault-malformed":     f.malformed,
+		"-fault-duplicate":     f.duplicate,
+	} {
+		if v < 0 || v > 1 {
+			return fmt.Errorf("%s must be within [0, 1], got %v", name, v)
+		}
+	}
+	return nil
+}
+
+// faultInjector corrupts orders on purpose to exercise validation, retries
+// and the DLQ. It is used from a single goroutine.
+type faultInjector struct {
+	rates  faultRates
+	rnd    *rand.Rand
+	counts map[string]int
+}
+
+func newFaultInjector(rates faultRates, seed int64) *faultInjector {
+	return &faultInjector{
+		rates:  rates,
+		rnd:    rand.New(rand.NewSource(seed + 1)),
+		counts: make(map[string]int),
+	}
+}
+
+func (fi *faultInjector) hit(rate float64, name string) bool {
+	if rate <= 0 || fi.rnd.Float64() >= rate {
+		return false
+	}
+	fi.counts[name]++
+	return true
+}
+
+// apply returns the messages to send for ord: usually one, two for an
+// injected duplicate.
+func (fi *faultInjector) apply(ord *model.Order, key []byte) ([]kafka.Message, error) {
+	if fi.hit(fi.rates.invalidEmail, "invalid_email") {
+		ord.Delivery.Email = "not-an-email"
+	}
+	if fi.hit(fi.rates.zeroAmount, "zero_amount") {
+		ord.Payment.Amount = 0
+	}
+
+	data, err := json.Marshal(ord)
+	if err != nil {
+		return nil, fmt.Errorf("marshal order: %w", err)
+	}
+	if fi.hit(fi.rates.malformed, "malformed") {
+		data = data[:len(data)/2]
+	}
+
+	msg := kafka.Message{
+		Key:   key,
+		Value: data,
+		Time:  time.Now(),
+		Headers: []kafka.Header{
+			{Key: "content-type", Value: []byte("application/json")},
+		},
+	}
+	if fi.hit(fi.rates.duplicate, "duplicate") {
+		return []kafka.Message{msg, msg}, nil
+	}
+	return []kafka.Message{msg}, nil
+}
+
+func (fi *faultInjector) summary() string {
+	if len(fi.counts) == 0 {
+		return "none"
+	}
+	return fmt.Sprint(fi.counts)
+}
@@ -2,33 +2,104 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
-	"orderservice/config"
-	"orderservice/pkg/generator"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"orderservice/config"
+
 	"github.com/segmentio/kafka-go"
+	"golang.org/x/time/rate"
 )
 
+type options struct {
+	count         int
+	rate          float64
+	concurrency   int
+	keyStrategy   string
+	file          string
+	seed          int64
+	consistent    bool
+	customerReuse float64
+	faults        faultRates
+}
+
+func parseFlags() options {
+	var o options
+	flag.IntVar(&o.count, "count", 0, "number of orders to send; 0 sends one generated order, or the whole -file")
+	flag.Float64Var(&o.rate, "rate", 0, "messages per second, 0 for unlimited")
+	flag.IntVar(&o.concurrency, "concurrency", 1, "number of concurrent senders")
+	flag.StringVar(&o.keyStrategy, "key", keyOrderUID, "message key strategy: order_uid, customer_id or random")
+	flag.StringVar(&o.file, "file", "", "read orders from a JSONL file instead of generating them; - for stdin")
+	flag.Int64Var(&o.seed, "seed", 0, "seed for generated orders, fault injection and random keys, 0 for a time-based seed")
+	flag.BoolVar(&o.consistent, "consistent", true, "generate orders that pass validation and financial consistency checks")
+	flag.Float64Var(&o.customerReuse, "customer-reuse", 0, "probability that a generated order reuses an earlier customer_id")
+	flag.Float64Var(&o.faults.invalidEmail, "fault-invalid-email", 0, "fraction of orders sent with an invalid delivery email")
+	flag.Float64Var(&o.faults.zeroAmount, "fault-zero-amount", 0, "fraction of orders sent with a zero payment amount")
+	flag.Float64Var(&o.faults.malformed, "fault-malformed", 0, "fraction of messages sent as malformed JSON")
+	flag.Float64Var(&o.faults.duplicate, "fault-duplicate", 0, "fraction of messages sent twice")
+	flag.Parse()
+
+	if o.count == 0 && o.file == "" {
+		o.count = 1
+	}
+	if o.seed == 0 {
+		o.seed = time.Now().UnixNano()
+	}
+	return o
+}
+
+func (o options) validate() error {
+	if o.count < 0 {
+		return fmt.Errorf("-count must not be negative")
+	}
+	if o.rate < 0 {
+		return fmt.Errorf("-rate must not be negative")
+	}
+	if o.concurrency < 1 {
+		return fmt.Errorf("-concurrency must be at least 1")
+	}
+	switch o.keyStrategy {
+	case keyOrderUID, keyCustomerID, keyRandom:
+	default:
+		return fmt.Errorf("unknown -key %q", o.keyStrategy)
+	}
+	return o.faults.validate()
+}
+
 func main() {
+	opts := parseFlags()
+	if err := opts.validate(); err != nil {
+		log.Fatalf("invalid flags: %v", err)
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	order := generator.RandomOrder()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	data, err := json.Marshal(order)
+	src, err := newSource(opts)
 	if err != nil {
-		log.Fatalf("failed to marshal order: %v", err)
+		log.Fatalf("failed to open order source: %v", err)
 	}
+	defer src.Close()
 
 	writer := &kafka.Writer{
-		Addr:         kafka.TCP(cfg.KafkaBrokers),
+		Addr:         kafka.TCP(cfg.Brokers()...),
 		Topic:        cfg.KafkaOrderTopic,
-		Balancer:     &kafka.LeastBytes{},
+		Balancer:     &kafka.Hash{},
 		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
 	}
 
 	defer func() {
@@ -37,17 +108,69 @@ func main() {
 		}
 	}()
 
-	err = writer.WriteMessages(context.Background(),
-		kafka.Message{
-			Key:   []byte("order_key_1"),
-			Value: data,
-			Time:  time.Now(),
-		},
-	)
+	var limiter *rate.Limiter
+	if opts.rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.rate), 1)
+	}
 
-	if err != nil {
-		log.Fatalf("failed to write message: %v", err)
+	msgs := make(chan kafka.Message, opts.concurrency)
+	var sent, failed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < opts.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range msgs {
+				if limiter != nil {
+					if err := limiter.Wait(ctx); err != nil {
+						return
+					}
+				}
+				if err := writer.WriteMessages(ctx, msg); err != nil {
+					failed.Add(1)
+					log.Printf("failed to write message %s: %v", msg.Key, err)
+					continue
+				}
+				sent.Add(1)
+			}
+		}()
+	}
+
+	start := time.Now()
+	injector := newFaultInjector(opts.faults, opts.seed)
+	keys := rand.New(rand.NewSource(opts.seed))
+
+produce:
+	for n := 0; opts.count == 0 || n < opts.count; n++ {
+		ord, err := src.Next()
+		if err != nil {
+			if err == errSourceDone {
+				break
+			}
+			log.Fatalf("failed to read order: %v", err)
+		}
+
+		batch, err := injector.apply(ord, messageKey(opts.keyStrategy, ord, keys))
+		if err != nil {
+			log.Fatalf("failed to build message: %v", err)
+		}
+		for _, msg := range batch {
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				break produce
+			}
+		}
 	}
+	close(msgs)
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	log.Printf("sent %d messages (%d failed) in %s, %.1f msg/s; faults injected: %s",
+		sent.Load(), failed.Load(), elapsed.Round(time.Millisecond),
+		float64(sent.Load())/elapsed.Seconds(), injector.summary())
 
-	log.Println("Order successfully sent to Kafka")
+	if failed.Load() > 0 {
+		os.Exit(1)
+	}
 }
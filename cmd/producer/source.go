@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+
+	"orderservice/internal/model"
+	"orderservice/pkg/generator"
+
+	"github.com/google/uuid"
+)
+
+const (
+	keyOrderUID   = "order_uid"
+	keyCustomerID = "customer_id"
+	keyRandom     = "random"
+)
+
+var errSourceDone = errors.New("source exhausted")
+
+type source interface {
+	Next() (*model.Order, error)
+	Close() error
+}
+
+func newSource(o options) (source, error) {
+	if o.file == "" {
+		genOpts := generator.DefaultOptions()
+		genOpts.Seed = uint64(o.seed)
+		genOpts.CustomerReuse = o.customerReuse
+		genOpts.Consistent = o.consistent
+		gen, err := generator.New(genOpts)
+		if err != nil {
+			return nil, err
+		}
+		return generatorSource{gen: gen}, nil
+	}
+	if o.file == "-" {
+		return newFileSource(os.Stdin, "stdin"), nil
+	}
+	f, err := os.Open(o.file)
+	if err != nil {
+		return nil, err
+	}
+	return newFileSource(f, o.file), nil
+}
+
+type generatorSource struct {
+	gen *generator.Generator
+}
+
+func (s generatorSource) Next() (*model.Order, error) {
+	return s.gen.Order(), nil
+}
+
+func (generatorSource) Close() error {
+	return nil
+}
+
+// fileSource reads one JSON order per line; blank lines are skipped.
+type fileSource struct {
+	rc      io.ReadCloser
+	name    string
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newFileSource(rc io.ReadCloser, name string) *fileSource {
+	sc := bufio.NewScanner(rc)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &fileSource{rc: rc, name: name, scanner: sc}
+}
+
+func (s *fileSource) Next() (*model.Order, error) {
+	for s.scanner.Scan() {
+		s.line++
+		line := s.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var ord model.Order
+		if err := json.Unmarshal(line, &ord); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s.name, s.line, err)
+		}
+		return &ord, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errSourceDone
+}
+
+func (s *fileSource) Close() error {
+	if s.rc == os.Stdin {
+		return nil
+	}
+	return s.rc.Close()
+}
+
+func messageKey(strategy string, ord *model.Order, rnd *rand.Rand) []byte {
+	switch strategy {
+	case keyCustomerID:
+		return []byte(ord.CustomerID)
+	case keyRandom:
+		var b [16]byte
+		rnd.Read(b[:])
+		id, _ := uuid.FromBytes(b[:])
+		return []byte(id.String())
+	default:
+		return []byte(ord.OrderUID)
+	}
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLoad_RejectsZeroBurst(t *testing.T) {
+	t.Setenv("RATE_LIMIT_BURST", "0")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected a zero burst to be rejected")
+	}
+
+	// Without a rate there is no limiter, so the burst does not matter.
+	t.Setenv("RATE_LIMIT_RPS", "0")
+	if _, err := Load(); err != nil {
+		t.Fatalf("expected a disabled limit to load, got %v", err)
+	}
+}
+
+func TestBrokers(t *testing.T) {
+	cfg := Config{KafkaBrokers: "kafka-1:9092, kafka-2:9092,"}
+	if got, want := cfg.Brokers(), []string{"kafka-1:9092", "kafka-2:9092"}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,161 @@
+package apispec
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"orderservice/internal/codec"
+	"orderservice/internal/model"
+)
+
+const (
+	jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+	orderSchemaID     = "https://orderservice/schema/order.json"
+	nonBlankPattern   = `\S`
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// constraints mirrors the checks in model.Validate, keyed by "Type.json_field".
+// Cross-field rules that JSON Schema cannot express are documented instead.
+var constraints = map[string]map[string]any{
+	"Order.order_uid":    {"pattern": nonBlankPattern},
+	"Order.track_number": {"pattern": nonBlankPattern},
+	"Order.customer_id":  {"pattern": nonBlankPattern},
+	"Order.date_created": {"description": "Must not be older than 10 years or more than 1 hour in the future."},
+	"Order.items":        {"minItems": 1},
+	"Order.status": {
+		"readOnly":    true,
+		"description": "Least advanced status among items that are not returned or cancelled; set in responses only.",
+	},
+
+	"Delivery.name":    {"pattern": nonBlankPattern},
+	"Delivery.phone":   {"pattern": nonBlankPattern},
+	"Delivery.city":    {"pattern": nonBlankPattern},
+	"Delivery.address": {"pattern": nonBlankPattern},
+	"Delivery.email":   {"pattern": "@"},
+
+	"Payment.transaction": {"pattern": nonBlankPattern},
+	"Payment.currency":    {"pattern": nonBlankPattern},
+	"Payment.provider":    {"pattern": nonBlankPattern},
+	"Payment.amount":      {"exclusiveMinimum": 0},
+
+	"Item.name":        {"pattern": nonBlankPattern},
+	"Item.brand":       {"pattern": nonBlankPattern},
+	"Item.price":       {"exclusiveMinimum": 0},
+	"Item.total_price": {"description": "Must be at least price - sale."},
+	"Item.status": {
+		"enum":        itemStatusCodes(),
+		"description": "202 accepted, 203 assembled, 204 shipped, 205 delivered, 206 received, 207 returned, 208 cancelled. Taken from the payload for new items only.",
+	},
+	"Item.status_history": {"readOnly": true},
+}
+
+func itemStatusCodes() []int {
+	var codes []int
+	for _, s := range model.ItemStatuses() {
+		codes = append(codes, int(s))
+	}
+	return codes
+}
+
+// OrderSchema returns the JSON Schema of the Kafka order payload and of the
+// GET /orders/{id} response.
+func OrderSchema() map[string]any {
+	defs := map[string]any{}
+	root := objectSchema(reflect.TypeOf(model.Order{}), "#/$defs/", defs)
+	delete(defs, "Order")
+
+	// Only Kafka payloads carry the version; it is not part of the HTTP response.
+	root["properties"].(map[string]any)["schema_version"] = map[string]any{
+		"type":    "integer",
+		"minimum": 1,
+		"maximum": codec.CurrentSchemaVersion,
+	}
+
+	root["$schema"] = jsonSchemaDialect
+	root["$id"] = orderSchemaID
+	root["title"] = "Order"
+	root["$defs"] = defs
+	return root
+}
+
+// componentSchemas returns schemas for all model types referencing each other
+// through prefix, e.g. "#/components/schemas/".
+func componentSchemas(prefix string) map[string]any {
+	defs := map[string]any{}
+	objectSchema(reflect.TypeOf(model.Order{}), prefix, defs)
+	return defs
+}
+
+func objectSchema(t reflect.Type, prefix string, defs map[string]any) map[string]any {
+	props := map[string]any{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+
+		prop := typeSchema(f.Type, prefix, defs)
+		for k, v := range constraints[t.Name()+"."+name] {
+			prop[k] = v
+		}
+		props[name] = prop
+	}
+
+	s := map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+	defs[t.Name()] = s
+	return s
+}
+
+func typeSchema(t reflect.Type, prefix string, defs map[string]any) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			objectSchema(t, prefix, defs)
+		}
+		return map[string]any{"$ref": prefix + t.Name()}
+	case t.Kind() == reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), prefix, defs)}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]any{"type": "number"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	default:
+		return map[string]any{}
+	}
+}
+
+// Marshal renders a document the way it is committed under api/.
+func Marshal(doc map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
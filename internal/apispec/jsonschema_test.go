@@ -0,0 +1,96 @@
+package apispec
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"orderservice/internal/model"
+)
+
+func validOrder() *model.Order {
+	return &model.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Email: "test@gmail.com",
+		},
+		Payment: model.Payment{Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay", Amount: 1817},
+		Items: []model.Item{{
+			RID: "ab4219087a764ae0btest", Name: "Mascaras", Brand: "Vivienne Sabo", Price: 453, Sale: 30, TotalPrice: 423,
+			Status: model.ItemAccepted,
+		}},
+		CustomerID:  "test",
+		DateCreated: time.Now().Add(-time.Hour),
+	}
+}
+
+// structFor returns the addressable struct value of validOrder that holds
+// fields of the given model type.
+func structFor(o *model.Order, typeName string) reflect.Value {
+	switch typeName {
+	case "Order":
+		return reflect.ValueOf(o).Elem()
+	case "Delivery":
+		return reflect.ValueOf(&o.Delivery).Elem()
+	case "Payment":
+		return reflect.ValueOf(&o.Payment).Elem()
+	case "Item":
+		return reflect.ValueOf(&o.Items[0]).Elem()
+	}
+	return reflect.Value{}
+}
+
+func fieldByJSONName(v reflect.Value, name string) reflect.Value {
+	for i := 0; i < v.NumField(); i++ {
+		tag, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		if tag == name {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// Every machine-checkable constraint published in the schema must be enforced
+// by model.Validate, otherwise the schema and the model have drifted.
+func TestConstraintsMatchValidate(t *testing.T) {
+	if err := validOrder().Validate(); err != nil {
+		t.Fatalf("fixture must be valid: %v", err)
+	}
+
+	for key, c := range constraints {
+		typeName, field, _ := strings.Cut(key, ".")
+
+		o := validOrder()
+		s := structFor(o, typeName)
+		if !s.IsValid() {
+			t.Fatalf("%s: unknown model type", key)
+		}
+		f := fieldByJSONName(s, field)
+		if !f.IsValid() {
+			t.Fatalf("%s: field does not exist in model", key)
+		}
+
+		switch {
+		case c["pattern"] == nonBlankPattern:
+			f.SetString("   ")
+		case c["pattern"] == "@":
+			f.SetString("invalid")
+		case c["exclusiveMinimum"] == 0:
+			f.SetInt(0)
+		case c["minItems"] == 1:
+			f.Set(reflect.Zero(f.Type()))
+		case c["enum"] != nil:
+			f.SetInt(0)
+		default:
+			continue
+		}
+
+		if err := o.Validate(); err == nil {
+			t.Errorf("%s: schema constraint %v is not enforced by Validate", key, c)
+		}
+	}
+}
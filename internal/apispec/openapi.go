@@ -0,0 +1,465 @@
+package apispec
+
+func errorResponse(description string) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"text/plain": map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+}
+
+func jsonResponse(description string, schema map[string]any) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schema},
+		},
+	}
+}
+
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+var (
+	tooManyRequests = map[string]any{
+		"description": "Rate limit exceeded.",
+		"headers": map[string]any{
+			"Retry-After": map[string]any{
+				"description": "Seconds to wait before retrying.",
+				"schema":      map[string]any{"type": "integer"},
+			},
+		},
+	}
+	overloaded = errorResponse("Service overloaded, request shed.")
+
+	orderParams = []any{
+		map[string]any{
+			"name":     "id",
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		},
+		map[string]any{
+			"name":     "X-API-Key",
+			"in":       "header",
+			"required": false,
+			"schema":   map[string]any{"type": "string"},
+		},
+	}
+)
+
+// OpenAPI returns the OpenAPI 3.1 document of the HTTP API.
+func OpenAPI() map[string]any {
+	schemas := componentSchemas("#/components/schemas/")
+	schemas["CheckResult"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"status":     map[string]any{"type": "string", "enum": []string{"up", "down"}},
+			"error":      map[string]any{"type": "string"},
+			"latency_ms": map[string]any{"type": "integer"},
+			"details":    map[string]any{"type": "object"},
+		},
+		"required": []string{"status", "latency_ms"},
+	}
+	schemas["ReadinessReport"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"ready":         map[string]any{"type": "boolean"},
+			"shutting_down": map[string]any{"type": "boolean"},
+			"checks": map[string]any{
+				"type":                 "object",
+				"additionalProperties": ref("CheckResult"),
+			},
+		},
+		"required": []string{"ready", "checks"},
+	}
+
+	schemas["StatusUpdate"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"status": map[string]any{
+				"description": "Status name or code.",
+				"oneOf": []any{
+					map[string]any{"type": "string", "enum": []string{"accepted", "assembled", "shipped", "delivered", "received", "returned", "cancelled"}},
+					map[string]any{"type": "integer", "enum": itemStatusCodes()},
+				},
+			},
+			"at": map[string]any{"type": "string", "format": "date-time", "description": "When the change happened; defaults to now."},
+		},
+		"required": []string{"status"},
+	}
+
+	schemas["AuditEntry"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id":        map[string]any{"type": "integer", "format": "int64"},
+			"order_uid": map[string]any{"type": "string"},
+			"action": map[string]any{
+				"type": "string",
+				"enum": []string{"created", "updated", "deleted", "erased", "archived", "exported"},
+			},
+			"source": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"kind":       map[string]any{"type": "string", "enum": []string{"kafka", "http", "system"}},
+					"principal":  map[string]any{"type": "string"},
+					"request_id": map[string]any{"type": "string"},
+					"topic":      map[string]any{"type": "string"},
+					"partition":  map[string]any{"type": "integer"},
+					"offset":     map[string]any{"type": "integer", "format": "int64"},
+				},
+				"required": []string{"kind"},
+			},
+			"changes": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path":   map[string]any{"type": "string"},
+						"before": map[string]any{},
+						"after":  map[string]any{},
+					},
+					"required": []string{"path"},
+				},
+			},
+			"at": map[string]any{"type": "string", "format": "date-time"},
+		},
+		"required": []string{"id", "order_uid", "action", "source", "changes", "at"},
+	}
+
+	schemas["OrderReport"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"group_by": map[string]any{"type": "string", "enum": []string{"day", "brand", "delivery_service", "region"}},
+			"from":     map[string]any{"type": "string", "format": "date-time"},
+			"to":       map[string]any{"type": "string", "format": "date-time"},
+			"source":   map[string]any{"type": "string", "enum": []string{"live", "views"}},
+			"rows": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"key":      map[string]any{"type": "string", "description": "Day (YYYY-MM-DD, UTC), brand, delivery service or region."},
+						"currency": map[string]any{"type": "string"},
+						"orders":   map[string]any{"type": "integer", "format": "int64"},
+						"items":    map[string]any{"type": "integer", "format": "int64"},
+						"revenue":  map[string]any{"type": "integer", "format": "int64", "description": "Sum of payment amounts; of item total_price when grouped by brand."},
+					},
+					"required": []string{"key", "currency", "orders", "items", "revenue"},
+				},
+			},
+		},
+		"required": []string{"group_by", "source", "rows"},
+	}
+
+	schemas["Erasure"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"customer_id":   map[string]any{"type": "string"},
+			"orders_erased": map[string]any{"type": "integer"},
+			"erased_at":     map[string]any{"type": "string", "format": "date-time"},
+		},
+		"required": []string{"customer_id", "orders_erased", "erased_at"},
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "Order service",
+			"version": "1.0.0",
+		},
+		"paths": map[string]any{
+			"/orders/{id}": map[string]any{
+				"get": map[string]any{
+					"operationId": "getOrder",
+					"summary":     "Get an order by its order_uid",
+					"parameters":  orderParams,
+					"responses": map[string]any{
+						"200": jsonResponse("The order.", ref("Order")),
+						"404": errorResponse("Order not found."),
+						"429": tooManyRequests,
+						"503": overloaded,
+					},
+				},
+				"delete": map[string]any{
+					"operationId": "deleteOrder",
+					"summary":     "Soft delete an order; later messages for it are dropped (needs ADMIN_TOKEN)",
+					"security":    []any{map[string]any{"adminToken": []string{}}},
+					"parameters":  orderParams,
+					"responses": map[string]any{
+						"204": map[string]any{"description": "Deleted."},
+						"401": errorResponse("Missing or wrong admin token."),
+						"404": errorResponse("Order not found or already deleted."),
+						"429": tooManyRequests,
+						"500": errorResponse("Delete failed."),
+						"503": overloaded,
+					},
+				},
+			},
+			"/orders/{id}/history": map[string]any{
+				"get": map[string]any{
+					"operationId": "getOrderHistory",
+					"summary":     "Audit log of an order, oldest first; personal delivery data is redacted",
+					"parameters":  orderParams,
+					"responses": map[string]any{
+						"200": jsonResponse("Audit entries.", map[string]any{"type": "array", "items": ref("AuditEntry")}),
+						"404": errorResponse("No history for this order."),
+						"429": tooManyRequests,
+						"500": errorResponse("Reading the history failed."),
+						"503": overloaded,
+					},
+				},
+			},
+			"/orders/{id}/items/{rid}/status": map[string]any{
+				"patch": map[string]any{
+					"operationId": "updateItemStatus",
+					"summary":     "Move an item along its lifecycle: accepted, assembled, shipped, delivered, received; returned and cancelled are final (needs ADMIN_TOKEN)",
+					"security":    []any{map[string]any{"adminToken": []string{}}},
+					"parameters": append([]any{
+						map[string]any{
+							"name":     "rid",
+							"in":       "path",
+							"required": true,
+							"schema":   map[string]any{"type": "string"},
+						},
+					}, orderParams...),
+					"requestBody": map[string]any{
+						"required": true,
+						"content": map[string]any{
+							"application/json": map[string]any{"schema": ref("StatusUpdate")},
+						},
+					},
+					"responses": map[string]any{
+						"200": jsonResponse("The updated order. Repeating the current status is a no-op.", ref("Order")),
+						"400": errorResponse("Unreadable body or unknown status."),
+						"401": errorResponse("Missing or wrong admin token."),
+						"404": errorResponse("Order or item not found."),
+						"409": errorResponse("The transition is not allowed."),
+						"429": tooManyRequests,
+						"500": errorResponse("Update failed."),
+						"503": overloaded,
+					},
+				},
+			},
+			"/reports/orders": map[string]any{
+				"get": map[string]any{
+					"operationId": "getOrderReport",
+					"summary":     "Order counts, items and revenue per currency, grouped by day, brand, delivery service or region",
+					"parameters": []any{
+						map[string]any{
+							"name":   "group_by",
+							"in":     "query",
+							"schema": map[string]any{"type": "string", "enum": []string{"day", "brand", "delivery_service", "region"}, "default": "day"},
+						},
+						map[string]any{
+							"name":        "from",
+							"in":          "query",
+							"schema":      map[string]any{"type": "string"},
+							"description": "Inclusive; YYYY-MM-DD (midnight UTC) or RFC 3339.",
+						},
+						map[string]any{
+							"name":        "to",
+							"in":          "query",
+							"schema":      map[string]any{"type": "string"},
+							"description": "Exclusive; YYYY-MM-DD (midnight UTC) or RFC 3339.",
+						},
+						map[string]any{
+							"name":        "limit",
+							"in":          "query",
+							"schema":      map[string]any{"type": "integer", "minimum": 0},
+							"description": "Maximum number of rows; rows other than days are sorted by revenue, descending.",
+						},
+						map[string]any{
+							"name":   "format",
+							"in":     "query",
+							"schema": map[string]any{"type": "string", "enum": []string{"json", "csv"}},
+						},
+						orderParams[1],
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The report. X-Report-Source tells whether it was read from the live tables or the materialized views.",
+							"content": map[string]any{
+								"application/json": map[string]any{"schema": ref("OrderReport")},
+								"text/csv":         map[string]any{"schema": map[string]any{"type": "string"}},
+							},
+						},
+						"400": errorResponse("Invalid parameters, or a grouping by an encrypted column."),
+						"429": tooManyRequests,
+						"500": errorResponse("Building the report failed."),
+						"503": overloaded,
+					},
+				},
+			},
+			"/ingest/orders": map[string]any{
+				"post": map[string]any{
+					"operationId": "ingestOrder",
+					"summary":     "Queue an order for processing (dev mode only, INGEST_BACKEND=http)",
+					"parameters": []any{
+						map[string]any{
+							"name":        "X-Schema-Version",
+							"in":          "header",
+							"required":    false,
+							"schema":      map[string]any{"type": "integer"},
+							"description": "Payload schema version, same as the schema-version Kafka header.",
+						},
+					},
+					"requestBody": map[string]any{
+						"required": true,
+						"content": map[string]any{
+							"application/json":     map[string]any{"schema": ref("Order")},
+							"application/protobuf": map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}},
+						},
+					},
+					"responses": map[string]any{
+						"202": map[string]any{"description": "Accepted; decoding and validation happen asynchronously."},
+						"400": errorResponse("Empty or unreadable body."),
+						"413": errorResponse("Payload too large."),
+						"429": tooManyRequests,
+						"503": overloaded,
+					},
+				},
+			},
+			"/admin/customers/{id}/erase": map[string]any{
+				"post": map[string]any{
+					"operationId": "eraseCustomer",
+					"summary":     "Anonymize the delivery data of all orders of a customer (needs ADMIN_TOKEN)",
+					"security":    []any{map[string]any{"adminToken": []string{}}},
+					"parameters": []any{
+						map[string]any{
+							"name":     "id",
+							"in":       "path",
+							"required": true,
+							"schema":   map[string]any{"type": "string"},
+						},
+					},
+					"responses": map[string]any{
+						"200": jsonResponse("Erasure recorded; orders of the customer dated before it are rejected on ingest.", ref("Erasure")),
+						"401": errorResponse("Missing or wrong admin token."),
+						"429": tooManyRequests,
+						"500": errorResponse("Erasure failed."),
+						"503": overloaded,
+					},
+				},
+			},
+			"/admin/orders": map[string]any{
+				"get": map[string]any{
+					"operationId": "findOrdersByContact",
+					"summary":     "Find live orders by exact delivery email and phone (needs ADMIN_TOKEN)",
+					"security":    []any{map[string]any{"adminToken": []string{}}},
+					"parameters": []any{
+						map[string]any{
+							"name":        "email",
+							"in":          "query",
+							"required":    false,
+							"schema":      map[string]any{"type": "string"},
+							"description": "Compared case-insensitively.",
+						},
+						map[string]any{
+							"name":        "phone",
+							"in":          "query",
+							"required":    false,
+							"schema":      map[string]any{"type": "string"},
+							"description": "Compared by digits only.",
+						},
+					},
+					"responses": map[string]any{
+						"200": jsonResponse("Matching orders, oldest first.", map[string]any{"type": "array", "items": ref("Order")}),
+						"400": errorResponse("Neither email nor phone given."),
+						"401": errorResponse("Missing or wrong admin token."),
+						"429": tooManyRequests,
+						"500": errorResponse("Lookup failed."),
+						"503": overloaded,
+					},
+				},
+			},
+			"/admin/orders/export": map[string]any{
+				"get": map[string]any{
+					"operationId": "exportOrders",
+					"summary":     "Stream live orders created in [from, to), oldest first (needs ADMIN_TOKEN)",
+					"security":    []any{map[string]any{"adminToken": []string{}}},
+					"parameters": []any{
+						map[string]any{
+							"name":        "format",
+							"in":          "query",
+							"schema":      map[string]any{"type": "string", "enum": []string{"csv", "jsonl", "parquet"}, "default": "csv"},
+							"description": "CSV and Parquet have one row per item with the order, delivery and payment columns; JSONL has one order per line.",
+						},
+						map[string]any{
+							"name":        "from",
+							"in":          "query",
+							"schema":      map[string]any{"type": "string"},
+							"description": "Inclusive; YYYY-MM-DD (midnight UTC) or RFC 3339.",
+						},
+						map[string]any{
+							"name":        "to",
+							"in":          "query",
+							"schema":      map[string]any{"type": "string"},
+							"description": "Exclusive; YYYY-MM-DD (midnight UTC) or RFC 3339.",
+						},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The export, streamed. A failure after the first bytes aborts the connection.",
+							"content": map[string]any{
+								"text/csv":                       map[string]any{"schema": map[string]any{"type": "string"}},
+								"application/x-ndjson":           map[string]any{"schema": map[string]any{"type": "string"}},
+								"application/vnd.apache.parquet": map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}},
+							},
+						},
+						"400": errorResponse("Unknown format or invalid range."),
+						"401": errorResponse("Missing or wrong admin token."),
+						"429": tooManyRequests,
+						"500": errorResponse("Export failed before any data was sent."),
+						"503": overloaded,
+					},
+				},
+			},
+			"/healthz": map[string]any{
+				"get": map[string]any{
+					"operationId": "healthz",
+					"summary":     "Liveness probe",
+					"responses": map[string]any{
+						"200": jsonResponse("Process is alive.", map[string]any{"type": "object"}),
+					},
+				},
+			},
+			"/readyz": map[string]any{
+				"get": map[string]any{
+					"operationId": "readyz",
+					"summary":     "Readiness probe with per-dependency breakdown",
+					"responses": map[string]any{
+						"200": jsonResponse("Ready to serve traffic.", ref("ReadinessReport")),
+						"503": jsonResponse("Not ready.", ref("ReadinessReport")),
+					},
+				},
+			},
+			"/schema/order.json": map[string]any{
+				"get": map[string]any{
+					"operationId": "getOrderSchema",
+					"summary":     "JSON Schema of the order payload",
+					"responses": map[string]any{
+						"200": jsonResponse("JSON Schema document.", map[string]any{"type": "object"}),
+					},
+				},
+			},
+			"/openapi.json": map[string]any{
+				"get": map[string]any{
+					"operationId": "getOpenAPI",
+					"summary":     "This document",
+					"responses": map[string]any{
+						"200": jsonResponse("OpenAPI document.", map[string]any{"type": "object"}),
+					},
+				},
+			},
+		},
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"adminToken": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
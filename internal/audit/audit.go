@@ -0,0 +1,155 @@
+// Package audit carries the source of a change through the context and
+// computes the diffs stored in the order audit log.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"orderservice/internal/model"
+)
+
+// Redacted replaces personal delivery data in diffs: the audit log records
+// that it changed, not what it was, so erasure does not have to rewrite it.
+const Redacted = "[redacted]"
+
+var redactedPaths = map[string]bool{
+	"delivery.name":    true,
+	"delivery.phone":   true,
+	"delivery.zip":     true,
+	"delivery.city":    true,
+	"delivery.address": true,
+	"delivery.region":  true,
+	"delivery.email":   true,
+}
+
+// Message headers carrying the source of orders queued by the in-process
+// HTTP ingest. Kafka producers may set RequestIDHeader too.
+const (
+	RequestIDHeader = "x-request-id"
+	PrincipalHeader = "x-principal"
+)
+
+type sourceKey struct{}
+
+func WithSource(ctx context.Context, src model.AuditSource) context.Context {
+	return context.WithValue(ctx, sourceKey{}, src)
+}
+
+// SourceFrom returns the source set by WithSource, or a system source for
+// changes made outside any request or message.
+func SourceFrom(ctx context.Context) model.AuditSource {
+	if src, ok := ctx.Value(sourceKey{}).(model.AuditSource); ok {
+		return src
+	}
+	return model.AuditSource{Kind: model.SourceSystem}
+}
+
+// Diff lists the leaves that differ between before and after; either may be
+// nil. Items are compared by position; their status history is left out, it
+// only repeats the status changes.
+func Diff(before, after *model.Order) ([]model.AuditChange, error) {
+	b, err := toJSON(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := toJSON(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := []model.AuditChange{}
+	diff("", b, a, &changes)
+	return changes, nil
+}
+
+// ErasedChanges is the diff recorded for Delivery.Erase.
+func ErasedChanges() []model.AuditChange {
+	var erased model.Order
+	erased.Delivery.Erase()
+	changes, _ := Diff(&model.Order{}, &erased)
+	return changes
+}
+
+func toJSON(ord *model.Order) (any, error) {
+	if ord == nil {
+		return nil, nil
+	}
+	// Postgres keeps microseconds and returns local time, so the stored and
+	// the incoming date would otherwise always differ.
+	c := *ord
+	c.DateCreated = c.DateCreated.UTC().Truncate(time.Microsecond)
+	data, err := json.Marshal(&c)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	return v, nil
+}
+
+func diff(path string, before, after any, out *[]model.AuditChange) {
+	bm, bIsMap := before.(map[string]any)
+	am, aIsMap := after.(map[string]any)
+	if (bIsMap || before == nil) && (aIsMap || after == nil) && (bIsMap || aIsMap) {
+		keys := make([]string, 0, len(bm)+len(am))
+		for k := range bm {
+			keys = append(keys, k)
+		}
+		for k := range am {
+			if _, ok := bm[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if k == "status_history" {
+				continue
+			}
+			diff(join(path, k), bm[k], am[k], out)
+		}
+		return
+	}
+
+	bs, bIsSlice := before.([]any)
+	as, aIsSlice := after.([]any)
+	if (bIsSlice || before == nil) && (aIsSlice || after == nil) && (bIsSlice || aIsSlice) {
+		for i := 0; i < max(len(bs), len(as)); i++ {
+			var b, a any
+			if i < len(bs) {
+				b = bs[i]
+			}
+			if i < len(as) {
+				a = as[i]
+			}
+			diff(fmt.Sprintf("%s[%d]", path, i), b, a, out)
+		}
+		return
+	}
+
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+	c := model.AuditChange{Path: path, Before: before, After: after}
+	if redactedPaths[path] {
+		if before != nil {
+			c.Before = Redacted
+		}
+		if after != nil {
+			c.After = Redacted
+		}
+	}
+	*out = append(*out, c)
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"orderservice/internal/model"
+)
+
+func testOrder() *model.Order {
+	return &model.Order{
+		OrderUID:    "o1",
+		TrackNumber: "T1",
+		Delivery:    model.Delivery{Name: "Test Testov", Email: "test@example.com"},
+		Payment:     model.Payment{Amount: 100},
+		Items:       []model.Item{{ChrtID: 1, Price: 100}},
+		DateCreated: time.Date(2025, 1, 2, 3, 4, 5, 6789, time.UTC),
+	}
+}
+
+func changesByPath(changes []model.AuditChange) map[string]model.AuditChange {
+	m := map[string]model.AuditChange{}
+	for _, c := range changes {
+		m[c.Path] = c
+	}
+	return m
+}
+
+func TestDiff_Created(t *testing.T) {
+	changes, err := Diff(nil, testOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := changesByPath(changes)
+
+	if c := got["track_number"]; c.Before != nil || c.After != "T1" {
+		t.Fatalf("unexpected track_number change %+v", c)
+	}
+	if c := got["items[0].price"]; c.After != float64(100) {
+		t.Fatalf("unexpected items[0].price change %+v", c)
+	}
+	if c := got["delivery.email"]; c.After != Redacted {
+		t.Fatalf("expected delivery.email to be redacted, got %+v", c)
+	}
+}
+
+func TestDiff_Updated(t *testing.T) {
+	before, after := testOrder(), testOrder()
+	// Same instant as read back from Postgres: microseconds, local zone.
+	after.DateCreated = before.DateCreated.Truncate(time.Microsecond).In(time.FixedZone("MSK", 3*3600))
+	if changes, err := Diff(before, after); err != nil || len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v, %v", changes, err)
+	}
+
+	after.Payment.Amount = 150
+	after.Delivery.Email = "new@example.com"
+	after.Items = append(after.Items, model.Item{ChrtID: 2})
+	changes, err := Diff(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := changesByPath(changes)
+
+	if c := got["payment.amount"]; c.Before != float64(100) || c.After != float64(150) {
+		t.Fatalf("unexpected payment.amount change %+v", c)
+	}
+	if c := got["delivery.email"]; c.Before != Redacted || c.After != Redacted {
+		t.Fatalf("expected a redacted delivery.email change, got %+v", c)
+	}
+	if c := got["items[1].chrt_id"]; c.Before != nil || c.After != float64(2) {
+		t.Fatalf("unexpected items[1].chrt_id change %+v", c)
+	}
+	if _, ok := got["items[0].price"]; ok {
+		t.Fatal("unchanged item reported as changed")
+	}
+}
+
+func TestErasedChanges(t *testing.T) {
+	got := changesByPath(ErasedChanges())
+	for _, path := range []string{"delivery.name", "delivery.phone", "delivery.address", "delivery.email"} {
+		if c, ok := got[path]; !ok || c.After != Redacted {
+			t.Errorf("expected a redacted change of %s, got %+v", path, c)
+		}
+	}
+	if len(got) != 4 {
+		t.Errorf("expected 4 changes, got %d", len(got))
+	}
+}
+
+func TestSourceFrom(t *testing.T) {
+	if src := SourceFrom(context.Background()); src.Kind != model.SourceSystem {
+		t.Fatalf("expected a system source by default, got %+v", src)
+	}
+	ctx := WithSource(context.Background(), model.AuditSource{Kind: model.SourceHTTP, RequestID: "r1"})
+	if src := SourceFrom(ctx); src.RequestID != "r1" {
+		t.Fatalf("unexpected source %+v", src)
+	}
+}
@@ -0,0 +1,133 @@
+package codec
+
+import (
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"orderservice/internal/model"
+	"orderservice/pkg/schemaregistry"
+
+	"github.com/hamba/avro/v2"
+)
+
+//go:embed order.avsc
+var OrderAvroSchema string
+
+// Confluent wire format: magic byte 0, 4-byte big-endian schema ID, payload.
+const (
+	wireMagic      = 0
+	wireHeaderSize = 5
+)
+
+// avroAPI maps Avro field names onto the existing json tags of model structs.
+var avroAPI = avro.Config{TagKey: "json"}.Freeze()
+
+type avroCodec struct {
+	registry schemaregistry.Client
+	subject  string
+
+	mu       sync.RWMutex
+	schemas  map[int]avro.Schema
+	writeID  int
+	writeSch avro.Schema
+}
+
+// NewAvro returns a codec that resolves writer schemas by ID against registry
+// and registers the bundled Order schema under subject when encoding.
+func NewAvro(registry schemaregistry.Client, subject string) Codec {
+	return &avroCodec{
+		registry: registry,
+		subject:  subject,
+		schemas:  make(map[int]avro.Schema),
+	}
+}
+
+func (c *avroCodec) ContentType() string {
+	return ContentTypeAvro
+}
+
+func (c *avroCodec) Encode(ctx context.Context, ord *model.Order) ([]byte, error) {
+	id, schema, err := c.writerSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := avroAPI.Marshal(schema, ord)
+	if err != nil {
+		return nil, fmt.Errorf("codec: avro: %w", err)
+	}
+
+	buf := make([]byte, wireHeaderSize, wireHeaderSize+len(payload))
+	buf[0] = wireMagic
+	binary.BigEndian.PutUint32(buf[1:], uint32(id))
+	return append(buf, payload...), nil
+}
+
+func (c *avroCodec) Decode(ctx context.Context, data []byte, headers map[string]string) (*model.Order, error) {
+	if len(data) < wireHeaderSize || data[0] != wireMagic {
+		return nil, errors.New("codec: avro: missing confluent wire-format header")
+	}
+	id := int(binary.BigEndian.Uint32(data[1:wireHeaderSize]))
+
+	schema, err := c.schemaByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var ord model.Order
+	if err := avroAPI.Unmarshal(schema, data[wireHeaderSize:], &ord); err != nil {
+		return nil, fmt.Errorf("codec: avro: schema %d: %w", id, err)
+	}
+	return &ord, nil
+}
+
+func (c *avroCodec) schemaByID(ctx context.Context, id int) (avro.Schema, error) {
+	c.mu.RLock()
+	schema, ok := c.schemas[id]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	raw, err := c.registry.GetSchema(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("codec: avro: resolve schema %d: %w", id, err)
+	}
+	schema, err = avro.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("codec: avro: parse schema %d: %w", id, err)
+	}
+
+	c.mu.Lock()
+	c.schemas[id] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
+
+func (c *avroCodec) writerSchema(ctx context.Context) (int, avro.Schema, error) {
+	c.mu.RLock()
+	id, schema := c.writeID, c.writeSch
+	c.mu.RUnlock()
+	if schema != nil {
+		return id, schema, nil
+	}
+
+	schema, err := avro.Parse(OrderAvroSchema)
+	if err != nil {
+		return 0, nil, fmt.Errorf("codec: avro: parse order schema: %w", err)
+	}
+	id, err = c.registry.Register(ctx, c.subject, OrderAvroSchema)
+	if err != nil {
+		return 0, nil, fmt.Errorf("codec: avro: register schema: %w", err)
+	}
+
+	c.mu.Lock()
+	c.writeID, c.writeSch = id, schema
+	c.schemas[id] = schema
+	c.mu.Unlock()
+	return id, schema, nil
+}
@@ -0,0 +1,57 @@
+package codec
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+
+	"orderservice/internal/model"
+)
+
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeAvro     = "application/vnd.apache.avro+binary"
+)
+
+type Codec interface {
+	ContentType() string
+	Encode(ctx context.Context, ord *model.Order) ([]byte, error)
+	Decode(ctx context.Context, data []byte, headers map[string]string) (*model.Order, error)
+}
+
+// Registry selects a codec by the message content-type. Messages without a
+// content-type are decoded with the default codec.
+type Registry struct {
+	def    Codec
+	codecs map[string]Codec
+}
+
+func NewRegistry(def Codec) *Registry {
+	r := &Registry{def: def, codecs: make(map[string]Codec)}
+	r.Register(def)
+	return r
+}
+
+func (r *Registry) Register(c Codec, aliases ...string) {
+	r.codecs[c.ContentType()] = c
+	for _, a := range aliases {
+		r.codecs[strings.ToLower(a)] = c
+	}
+}
+
+func (r *Registry) Lookup(contentType string) (Codec, error) {
+	if strings.TrimSpace(contentType) == "" {
+		return r.def, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("codec: invalid content-type %q: %w", contentType, err)
+	}
+	c, ok := r.codecs[mediaType]
+	if !ok {
+		return nil, fmt.Errorf("codec: unsupported content-type %q", mediaType)
+	}
+	return c, nil
+}
@@ -0,0 +1,149 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"orderservice/internal/model"
+	"orderservice/pkg/schemaregistry"
+)
+
+func sampleOrder() *model.Order {
+	return &model.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay",
+			Amount: 1817, PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []model.Item{{
+			ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, RID: "ab4219087a764ae0btest",
+			Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NMID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SMID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OOFShard:        "1",
+	}
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	reg, err := schemaregistry.NewFileRegistry(t.TempDir())
+	if err != nil {
+		t.Fatalf("file registry: %v", err)
+	}
+
+	for _, c := range []Codec{NewJSON(), NewProtobuf(), NewAvro(reg, "orders-value")} {
+		t.Run(c.ContentType(), func(t *testing.T) {
+			want := sampleOrder()
+			data, err := c.Encode(ctx, want)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			got, err := c.Decode(ctx, data, nil)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			got.DateCreated = got.DateCreated.UTC()
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestRegistry_Lookup(t *testing.T) {
+	r := NewRegistry(NewJSON())
+	r.Register(NewProtobuf(), "application/protobuf")
+
+	if c, err := r.Lookup(""); err != nil || c.ContentType() != ContentTypeJSON {
+		t.Fatalf("expected json default, got %v, %v", c, err)
+	}
+	if c, err := r.Lookup("application/protobuf; proto=orderservice.order.v1.Order"); err != nil || c.ContentType() != ContentTypeProtobuf {
+		t.Fatalf("expected protobuf, got %v, %v", c, err)
+	}
+	if _, err := r.Lookup("text/csv"); err == nil {
+		t.Fatalf("expected error for unsupported content-type")
+	}
+}
+
+func TestStrictJSON_RejectsDrift(t *testing.T) {
+	ctx := context.Background()
+	strict := NewStrictJSON()
+
+	data, err := strict.Encode(ctx, sampleOrder())
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if _, err := strict.Decode(ctx, data, nil); err != nil {
+		t.Fatalf("valid payload rejected: %v", err)
+	}
+
+	unknown := []byte(`{"order_uid":"x","surprise":1}`)
+	if _, err := strict.Decode(ctx, unknown, map[string]string{SchemaVersionHeader: "2"}); err == nil {
+		t.Fatalf("expected error for missing fields")
+	}
+
+	var doc map[string]any
+	_ = json.Unmarshal(data, &doc)
+	doc["surprise"] = true
+	withUnknown, _ := json.Marshal(doc)
+	if _, err := strict.Decode(ctx, withUnknown, nil); err == nil {
+		t.Fatalf("expected error for unknown field")
+	}
+	if _, err := NewJSON().Decode(ctx, withUnknown, nil); err != nil {
+		t.Fatalf("lenient codec must ignore unknown fields: %v", err)
+	}
+}
+
+func TestStrictJSON_UpgradesV1(t *testing.T) {
+	ctx := context.Background()
+	data, _ := json.Marshal(sampleOrder())
+
+	var doc map[string]any
+	_ = json.Unmarshal(data, &doc)
+	delete(doc, "internal_signature")
+	delete(doc["items"].([]any)[0].(map[string]any), "track_number")
+	v1, _ := json.Marshal(doc)
+
+	got, err := NewStrictJSON().Decode(ctx, v1, nil)
+	if err != nil {
+		t.Fatalf("v1 payload rejected: %v", err)
+	}
+	if got.Items[0].TrackNumber != got.TrackNumber {
+		t.Fatalf("expected item track_number to default to order's, got %q", got.Items[0].TrackNumber)
+	}
+
+	doc["schema_version"] = 2
+	v2, _ := json.Marshal(doc)
+	if _, err := NewStrictJSON().Decode(ctx, v2, nil); err == nil {
+		t.Fatalf("expected v2 payload with missing fields to be rejected")
+	}
+
+	if _, err := NewStrictJSON().Decode(ctx, data, map[string]string{SchemaVersionHeader: "99"}); err == nil {
+		t.Fatalf("expected unsupported version error")
+	}
+}
+
+func TestJSON_RejectsNonObjects(t *testing.T) {
+	ctx := context.Background()
+	for _, payload := range []string{`null`, `[]`, `42`, `"order"`} {
+		for _, c := range []Codec{NewJSON(), NewStrictJSON()} {
+			if _, err := c.Decode(ctx, []byte(payload), nil); err == nil {
+				t.Fatalf("expected %s to be rejected", payload)
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"orderservice/internal/model"
+)
+
+type jsonCodec struct {
+	strict bool
+}
+
+// NewJSON returns the lenient JSON codec: unknown fields are ignored and
+// missing ones are left zero.
+func NewJSON() Codec {
+	return jsonCodec{}
+}
+
+// NewStrictJSON rejects payloads with unknown fields or with any field of the
+// current schema missing after version upgrades.
+func NewStrictJSON() Codec {
+	return jsonCodec{strict: true}
+}
+
+func (jsonCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (jsonCodec) Encode(ctx context.Context, ord *model.Order) ([]byte, error) {
+	return json.Marshal(ord)
+}
+
+func (c jsonCodec) Decode(ctx context.Context, data []byte, headers map[string]string) (*model.Order, error) {
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("codec: json: %w", err)
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("codec: json: payload is null, want an object")
+	}
+
+	version, err := schemaVersion(doc, headers)
+	if err != nil {
+		return nil, err
+	}
+	delete(doc, schemaVersionField)
+
+	if err := upgrade(doc, version); err != nil {
+		return nil, err
+	}
+
+	if c.strict {
+		if err := checkRequired(doc, orderType, ""); err != nil {
+			return nil, fmt.Errorf("codec: json: schema v%d: %w", version, err)
+		}
+	}
+
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("codec: json: %w", err)
+	}
+
+	var ord model.Order
+	dec = json.NewDecoder(bytes.NewReader(upgraded))
+	if c.strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&ord); err != nil {
+		return nil, fmt.Errorf("codec: json: schema v%d: %w", version, err)
+	}
+	return &ord, nil
+}
@@ -0,0 +1,145 @@
+package codec
+
+import (
+	"context"
+	"fmt"
+
+	"orderservice/internal/codec/orderpb"
+	"orderservice/internal/model"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type protobufCodec struct{}
+
+func NewProtobuf() Codec {
+	return protobufCodec{}
+}
+
+func (protobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (protobufCodec) Encode(ctx context.Context, ord *model.Order) ([]byte, error) {
+	return proto.Marshal(toProto(ord))
+}
+
+func (protobufCodec) Decode(ctx context.Context, data []byte, headers map[string]string) (*model.Order, error) {
+	var pb orderpb.Order
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return nil, fmt.Errorf("codec: protobuf: %w", err)
+	}
+	return fromProto(&pb), nil
+}
+
+func toProto(o *model.Order) *orderpb.Order {
+	pb := &orderpb.Order{
+		OrderUid:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: &orderpb.Delivery{
+			Name:    o.Delivery.Name,
+			Phone:   o.Delivery.Phone,
+			Zip:     o.Delivery.Zip,
+			City:    o.Delivery.City,
+			Address: o.Delivery.Address,
+			Region:  o.Delivery.Region,
+			Email:   o.Delivery.Email,
+		},
+		Payment: &orderpb.Payment{
+			Transaction:  o.Payment.Transaction,
+			RequestId:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       o.Payment.Amount,
+			PaymentDt:    o.Payment.PaymentDt,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: o.Payment.DeliveryCost,
+			GoodsTotal:   o.Payment.GoodsTotal,
+			CustomFee:    o.Payment.CustomFee,
+		},
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.ShardKey,
+		SmId:              int64(o.SMID),
+		OofShard:          o.OOFShard,
+	}
+	if !o.DateCreated.IsZero() {
+		pb.DateCreated = timestamppb.New(o.DateCreated)
+	}
+	for _, it := range o.Items {
+		pb.Items = append(pb.Items, &orderpb.Item{
+			ChrtId:      it.ChrtID,
+			TrackNumber: it.TrackNumber,
+			Price:       it.Price,
+			Rid:         it.RID,
+			Name:        it.Name,
+			Sale:        it.Sale,
+			Size:        it.Size,
+			TotalPrice:  it.TotalPrice,
+			NmId:        it.NMID,
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		})
+	}
+	return pb
+}
+
+func fromProto(pb *orderpb.Order) *model.Order {
+	d, p := pb.GetDelivery(), pb.GetPayment()
+	o := &model.Order{
+		OrderUID:    pb.GetOrderUid(),
+		TrackNumber: pb.GetTrackNumber(),
+		Entry:       pb.GetEntry(),
+		Delivery: model.Delivery{
+			Name:    d.GetName(),
+			Phone:   d.GetPhone(),
+			Zip:     d.GetZip(),
+			City:    d.GetCity(),
+			Address: d.GetAddress(),
+			Region:  d.GetRegion(),
+			Email:   d.GetEmail(),
+		},
+		Payment: model.Payment{
+			Transaction:  p.GetTransaction(),
+			RequestID:    p.GetRequestId(),
+			Currency:     p.GetCurrency(),
+			Provider:     p.GetProvider(),
+			Amount:       p.GetAmount(),
+			PaymentDt:    p.GetPaymentDt(),
+			Bank:         p.GetBank(),
+			DeliveryCost: p.GetDeliveryCost(),
+			GoodsTotal:   p.GetGoodsTotal(),
+			CustomFee:    p.GetCustomFee(),
+		},
+		Locale:            pb.GetLocale(),
+		InternalSignature: pb.GetInternalSignature(),
+		CustomerID:        pb.GetCustomerId(),
+		DeliveryService:   pb.GetDeliveryService(),
+		ShardKey:          pb.GetShardkey(),
+		SMID:              int(pb.GetSmId()),
+		OOFShard:          pb.GetOofShard(),
+	}
+	if pb.GetDateCreated() != nil {
+		o.DateCreated = pb.GetDateCreated().AsTime()
+	}
+	for _, it := range pb.GetItems() {
+		o.Items = append(o.Items, model.Item{
+			ChrtID:      it.GetChrtId(),
+			TrackNumber: it.GetTrackNumber(),
+			Price:       it.GetPrice(),
+			RID:         it.GetRid(),
+			Name:        it.GetName(),
+			Sale:        it.GetSale(),
+			Size:        it.GetSize(),
+			TotalPrice:  it.GetTotalPrice(),
+			NMID:        it.GetNmId(),
+			Brand:       it.GetBrand(),
+			Status:      model.ItemStatus(it.GetStatus()),
+		})
+	}
+	return o
+}
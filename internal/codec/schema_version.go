@@ -0,0 +1,152 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"orderservice/internal/model"
+)
+
+// CurrentSchemaVersion is the version of the JSON payload that maps 1:1 onto
+// model.Order. Payloads without a version are treated as version 1.
+const CurrentSchemaVersion = 2
+
+const (
+	schemaVersionField  = "schema_version"
+	SchemaVersionHeader = "schema-version"
+)
+
+// adapters[n] upgrades a version n document to version n+1 in place.
+var adapters = map[int]func(doc map[string]any) error{
+	1: upgradeV1,
+}
+
+// upgradeV1 fills in fields that version 1 producers omitted when empty:
+// internal_signature, shardkey, oof_shard and items[].track_number, which
+// defaulted to the order's track_number.
+func upgradeV1(doc map[string]any) error {
+	for _, key := range []string{"internal_signature", "shardkey", "oof_shard"} {
+		if _, ok := doc[key]; !ok {
+			doc[key] = ""
+		}
+	}
+
+	items, _ := doc["items"].([]any)
+	for _, it := range items {
+		item, ok := it.(map[string]any)
+		if !ok {
+			continue
+		}
+		if _, ok := item["track_number"]; !ok {
+			item["track_number"] = doc["track_number"]
+		}
+	}
+	return nil
+}
+
+func upgrade(doc map[string]any, from int) error {
+	for v := from; v < CurrentSchemaVersion; v++ {
+		adapt, ok := adapters[v]
+		if !ok {
+			return fmt.Errorf("codec: no adapter from schema v%d", v)
+		}
+		if err := adapt(doc); err != nil {
+			return fmt.Errorf("codec: upgrade schema v%d: %w", v, err)
+		}
+	}
+	return nil
+}
+
+// schemaVersion takes the version from the header first, then from the
+// schema_version payload field.
+func schemaVersion(doc map[string]any, headers map[string]string) (int, error) {
+	raw := ""
+	for k, v := range headers {
+		if strings.EqualFold(k, SchemaVersionHeader) {
+			raw = v
+			break
+		}
+	}
+	if raw == "" {
+		if v, ok := doc[schemaVersionField]; ok {
+			switch n := v.(type) {
+			case json.Number:
+				raw = n.String()
+			case string:
+				raw = n
+			default:
+				return 0, fmt.Errorf("codec: invalid %s %v", schemaVersionField, v)
+			}
+		}
+	}
+	if raw == "" {
+		return 1, nil
+	}
+
+	version, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(raw), "v"))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("codec: invalid schema version %q", raw)
+	}
+	if version > CurrentSchemaVersion {
+		return 0, fmt.Errorf("codec: unsupported schema version %d (current is %d)", version, CurrentSchemaVersion)
+	}
+	return version, nil
+}
+
+var (
+	orderType = reflect.TypeOf(model.Order{})
+	timeType  = reflect.TypeOf(time.Time{})
+)
+
+// checkRequired reports the first field of t that is missing in doc. Every
+// json-tagged field without omitempty is required.
+func checkRequired(doc map[string]any, t reflect.Type, path string) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" || strings.Contains(opts, "omitempty") {
+			continue
+		}
+
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+
+		v, ok := doc[name]
+		if !ok || v == nil {
+			return fmt.Errorf("missing required field %q", fieldPath)
+		}
+
+		ft := f.Type
+		switch {
+		case ft.Kind() == reflect.Struct && ft != timeType:
+			sub, ok := v.(map[string]any)
+			if !ok {
+				return fmt.Errorf("field %q must be an object", fieldPath)
+			}
+			if err := checkRequired(sub, ft, fieldPath); err != nil {
+				return err
+			}
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct:
+			list, ok := v.([]any)
+			if !ok {
+				return fmt.Errorf("field %q must be an array", fieldPath)
+			}
+			for j, el := range list {
+				sub, ok := el.(map[string]any)
+				if !ok {
+					return fmt.Errorf("field %s[%d] must be an object", fieldPath, j)
+				}
+				if err := checkRequired(sub, ft.Elem(), fmt.Sprintf("%s[%d]", fieldPath, j)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
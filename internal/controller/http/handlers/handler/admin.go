@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"orderservice/internal/controller/http/middleware"
+	"orderservice/internal/export"
+	"orderservice/internal/model"
+	"orderservice/internal/usecase"
+)
+
+type AdminHandler struct {
+	uc     usecase.OrderUsecase
+	logger *zap.Logger
+}
+
+func NewAdminHandler(u usecase.OrderUsecase, logger *zap.Logger) *AdminHandler {
+	return &AdminHandler{uc: u, logger: logger}
+}
+
+type erasureResponse struct {
+	CustomerID   string    `json:"customer_id"`
+	OrdersErased int       `json:"orders_erased"`
+	ErasedAt     time.Time `json:"erased_at"`
+}
+
+// EraseCustomer anonymizes the delivery data of all orders of a customer.
+// Repeating the request is safe.
+func (h *AdminHandler) EraseCustomer(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetRequestID(r.Context())
+	customerID := chi.URLParam(r, "id")
+	if strings.TrimSpace(customerID) == "" {
+		http.Error(w, "empty customer id", http.StatusBadRequest)
+		return
+	}
+
+	e, err := h.uc.EraseCustomer(r.Context(), customerID, reqID)
+	if err != nil {
+		h.logger.Error("failed to erase customer data",
+			zap.String("request_id", reqID),
+			zap.Error(err),
+		)
+		http.Error(w, "failed to erase customer data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-ID", reqID)
+	json.NewEncoder(w).Encode(erasureResponse{
+		CustomerID:   e.CustomerID,
+		OrdersErased: len(e.OrderUIDs),
+		ErasedAt:     e.ErasedAt.UTC(),
+	})
+
+	// The customer id is logged on purpose: it is the audit key, not PII.
+	h.logger.Info("customer data erased",
+		zap.String("request_id", reqID),
+		zap.String("customer_id", customerID),
+		zap.Int("orders", len(e.OrderUIDs)),
+	)
+}
+
+// FindOrders looks orders up by delivery email and phone. Both are compared
+// through blind indexes when PII is encrypted, so only exact matches work.
+func (h *AdminHandler) FindOrders(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetRequestID(r.Context())
+	email := strings.TrimSpace(r.URL.Query().Get("email"))
+	phone := strings.TrimSpace(r.URL.Query().Get("phone"))
+	if email == "" && phone == "" {
+		http.Error(w, "email or phone is required", http.StatusBadRequest)
+		return
+	}
+
+	orders, err := h.uc.FindOrdersByContact(r.Context(), email, phone)
+	if err != nil {
+		h.logger.Error("failed to find orders by contact",
+			zap.String("request_id", reqID),
+			zap.Error(err),
+		)
+		http.Error(w, "failed to find orders", http.StatusInternalServerError)
+		return
+	}
+	if orders == nil {
+		orders = []*model.Order{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-ID", reqID)
+	json.NewEncoder(w).Encode(orders)
+}
+
+// ExportOrders streams the live orders created in [from, to) as CSV, JSONL or
+// Parquet. Once data has been sent an error can no longer change the status,
+// so the connection is aborted and the client sees a truncated transfer.
+func (h *AdminHandler) ExportOrders(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetRequestID(r.Context())
+	w.Header().Set("X-Request-ID", reqID)
+
+	format := export.FormatCSV
+	if f := r.URL.Query().Get("format"); f != "" {
+		var err error
+		if format, err = export.ParseFormat(f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	from, to, err := parseTimeRange(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders.%s"`, format.Extension()))
+	cw := &countingWriter{w: w}
+	ew, err := export.NewWriter(cw, format)
+	if err == nil {
+		n := 0
+		err = h.uc.ExportOrders(r.Context(), from, to, func(o *model.Order) error {
+			n++
+			return ew.Write(o)
+		})
+		if err == nil {
+			err = ew.Close()
+		}
+		if err == nil {
+			h.logger.Info("orders exported",
+				zap.String("request_id", reqID),
+				zap.String("format", string(format)),
+				zap.Int("orders", n),
+			)
+			return
+		}
+	}
+
+	h.logger.Error("failed to export orders",
+		zap.String("request_id", reqID),
+		zap.Int64("bytes_sent", cw.n),
+		zap.Error(err),
+	)
+	if cw.n > 0 {
+		panic(http.ErrAbortHandler)
+	}
+	w.Header().Del("Content-Disposition")
+	http.Error(w, "failed to export orders", http.StatusInternalServerError)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
 
@@ -9,6 +10,7 @@ import (
 	"go.uber.org/zap"
 
 	"orderservice/internal/controller/http/middleware"
+	"orderservice/internal/model"
 	"orderservice/internal/usecase"
 )
 
@@ -70,3 +72,109 @@ func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
 		zap.String("order_id", orderID),
 	)
 }
+
+func (h *Handler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetRequestID(r.Context())
+	orderID := chi.URLParam(r, "id")
+
+	w.Header().Set("X-Request-ID", reqID)
+	if err := h.uc.DeleteOrder(r.Context(), orderID); err != nil {
+		if errors.Is(err, usecase.ErrOrderNotFound) {
+			http.Error(w, "order not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to delete order",
+			zap.String("request_id", reqID),
+			zap.String("order_id", orderID),
+			zap.Error(err),
+		)
+		http.Error(w, "failed to delete order", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	h.logger.Info("order deleted",
+		zap.String("request_id", reqID),
+		zap.String("order_id", orderID),
+	)
+}
+
+func (h *Handler) OrderHistory(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetRequestID(r.Context())
+	orderID := chi.URLParam(r, "id")
+
+	w.Header().Set("X-Request-ID", reqID)
+	entries, err := h.uc.OrderHistory(r.Context(), orderID)
+	if err != nil {
+		if errors.Is(err, usecase.ErrOrderNotFound) {
+			http.Error(w, "order not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to read order history",
+			zap.String("request_id", reqID),
+			zap.String("order_id", orderID),
+			zap.Error(err),
+		)
+		http.Error(w, "failed to read order history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		h.logger.Error("failed to encode response",
+			zap.String("request_id", reqID),
+			zap.Error(err),
+		)
+	}
+}
+
+// UpdateItemStatus moves an item to the status in the body, given by name
+// or code.
+func (h *Handler) UpdateItemStatus(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetRequestID(r.Context())
+	orderID := chi.URLParam(r, "id")
+	w.Header().Set("X-Request-ID", reqID)
+
+	var upd model.StatusUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&upd); err != nil {
+		http.Error(w, "invalid status: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	upd.OrderUID = orderID
+	upd.RID = chi.URLParam(r, "rid")
+
+	order, err := h.uc.UpdateItemStatus(r.Context(), upd)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrOrderNotFound):
+			http.Error(w, "order not found", http.StatusNotFound)
+		case errors.Is(err, usecase.ErrItemNotFound):
+			http.Error(w, "item not found", http.StatusNotFound)
+		case errors.Is(err, model.ErrInvalidTransition):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			h.logger.Error("failed to update item status",
+				zap.String("request_id", reqID),
+				zap.String("order_id", orderID),
+				zap.Error(err),
+			)
+			http.Error(w, "failed to update item status", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(order); err != nil {
+		h.logger.Error("failed to encode response",
+			zap.String("request_id", reqID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	h.logger.Info("item status updated",
+		zap.String("request_id", reqID),
+		zap.String("order_id", orderID),
+		zap.String("status", upd.Status.String()),
+	)
+}
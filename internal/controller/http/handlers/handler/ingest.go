@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/codec"
+	"orderservice/internal/controller/http/middleware"
+	"orderservice/pkg/consumer"
+)
+
+const maxIngestBodySize = 1 << 20
+
+type Publisher interface {
+	Publish(ctx context.Context, msg consumer.Message) error
+}
+
+// IngestHandler accepts order payloads over HTTP and hands them to the same
+// pipeline that consumes Kafka messages. Processing is asynchronous.
+type IngestHandler struct {
+	pub    Publisher
+	logger *zap.Logger
+}
+
+func NewIngestHandler(pub Publisher, logger *zap.Logger) *IngestHandler {
+	return &IngestHandler{pub: pub, logger: logger}
+}
+
+func (h *IngestHandler) Ingest(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetRequestID(r.Context())
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if len(body) == 0 {
+		http.Error(w, "empty body", http.StatusBadRequest)
+		return
+	}
+
+	headers := map[string]string{}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		headers["content-type"] = ct
+	}
+	if v := r.Header.Get("X-Schema-Version"); v != "" {
+		headers[codec.SchemaVersionHeader] = v
+	}
+	headers[audit.RequestIDHeader] = reqID
+	headers[audit.PrincipalHeader] = audit.SourceFrom(r.Context()).Principal
+
+	msg := consumer.Message{
+		Key:     []byte(r.Header.Get("X-Message-Key")),
+		Value:   body,
+		Headers: headers,
+	}
+	if err := h.pub.Publish(r.Context(), msg); err != nil {
+		h.logger.Error("failed to enqueue order",
+			zap.String("request_id", reqID),
+			zap.Error(err),
+		)
+		http.Error(w, "ingest unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("X-Request-ID", reqID)
+	w.WriteHeader(http.StatusAccepted)
+
+	h.logger.Info("order accepted for ingest", zap.String("request_id", reqID))
+}
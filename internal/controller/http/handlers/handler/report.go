@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"orderservice/internal/controller/http/middleware"
+	"orderservice/internal/reporting"
+)
+
+type ReportHandler struct {
+	src    reporting.Source
+	logger *zap.Logger
+}
+
+func NewReportHandler(src reporting.Source, logger *zap.Logger) *ReportHandler {
+	return &ReportHandler{src: src, logger: logger}
+}
+
+type reportResponse struct {
+	GroupBy reporting.GroupBy `json:"group_by"`
+	From    *time.Time        `json:"from,omitempty"`
+	To      *time.Time        `json:"to,omitempty"`
+	Source  string            `json:"source"`
+	Rows    []reporting.Row   `json:"rows"`
+}
+
+// Orders aggregates orders created in [from, to) by day, brand, delivery
+// service or region. The response is JSON unless format=csv is given or
+// text/csv is accepted.
+func (h *ReportHandler) Orders(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetRequestID(r.Context())
+	w.Header().Set("X-Request-ID", reqID)
+
+	q, err := parseReportQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	asCSV := r.URL.Query().Get("format") == "csv" ||
+		(r.URL.Query().Get("format") == "" && strings.Contains(r.Header.Get("Accept"), "text/csv"))
+
+	rep, err := h.src.OrderReport(r.Context(), q)
+	if err != nil {
+		if errors.Is(err, reporting.ErrUnavailable) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.logger.Error("failed to build order report",
+			zap.String("request_id", reqID),
+			zap.String("group_by", string(q.GroupBy)),
+			zap.Error(err),
+		)
+		http.Error(w, "failed to build report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-Report-Source", rep.Source)
+	if asCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders-by-%s.csv"`, q.GroupBy))
+		err = reporting.WriteCSV(w, q.GroupBy, rep.Rows)
+	} else {
+		rows := rep.Rows
+		if rows == nil {
+			rows = []reporting.Row{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(reportResponse{
+			GroupBy: q.GroupBy, From: q.From, To: q.To, Source: rep.Source, Rows: rows,
+		})
+	}
+	if err != nil {
+		h.logger.Error("failed to encode response",
+			zap.String("request_id", reqID),
+			zap.Error(err),
+		)
+	}
+}
+
+func parseReportQuery(v url.Values) (reporting.Query, error) {
+	var (
+		q   reporting.Query
+		err error
+	)
+	q.GroupBy = reporting.GroupByDay
+	if g := v.Get("group_by"); g != "" {
+		if q.GroupBy, err = reporting.ParseGroupBy(g); err != nil {
+			return q, err
+		}
+	}
+	if q.From, q.To, err = parseTimeRange(v); err != nil {
+		return q, err
+	}
+	if l := v.Get("limit"); l != "" {
+		if q.Limit, err = strconv.Atoi(l); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("invalid limit %q", l)
+		}
+	}
+	return q, nil
+}
+
+// parseTimeRange reads the from and to parameters; either may be missing.
+func parseTimeRange(v url.Values) (from, to *time.Time, err error) {
+	if from, err = parseTime(v, "from"); err != nil {
+		return nil, nil, err
+	}
+	if to, err = parseTime(v, "to"); err != nil {
+		return nil, nil, err
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, nil, errors.New("from must be before to")
+	}
+	return from, to, nil
+}
+
+// parseTime accepts a date, which means midnight UTC, or an RFC 3339
+// timestamp.
+func parseTime(v url.Values, name string) (*time.Time, error) {
+	s := v.Get(name)
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, s); err != nil {
+			return nil, fmt.Errorf("invalid %s %q, want YYYY-MM-DD or RFC 3339", name, s)
+		}
+	}
+	t = t.UTC()
+	return &t, nil
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+
+	"orderservice/api"
+)
+
+type SpecHandler struct{}
+
+func NewSpecHandler() *SpecHandler {
+	return &SpecHandler{}
+}
+
+func (h *SpecHandler) OrderSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(api.OrderSchema)
+}
+
+func (h *SpecHandler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(api.OpenAPI)
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"orderservice/internal/controller/http/handlers/handler"
+	"orderservice/internal/health"
+	"orderservice/internal/reporting"
 	"orderservice/internal/usecase"
 
 	"go.uber.org/zap"
@@ -9,12 +11,29 @@ import (
 
 type Handlers struct {
 	Order  *handler.Handler
+	Health *handler.HealthHandler
+	Spec   *handler.SpecHandler
+	Ingest *handler.IngestHandler
+	Admin  *handler.AdminHandler
+	Report *handler.ReportHandler
 	logger *zap.Logger
 }
 
-func NewHandlers(logger *zap.Logger, u usecase.OrderUsecase) *Handlers {
-	return &Handlers{
+// NewHandlers builds the route handlers. Ingest is nil unless pub is set,
+// Report unless reports is.
+func NewHandlers(logger *zap.Logger, u usecase.OrderUsecase, checker *health.Checker, pub handler.Publisher, reports reporting.Source) *Handlers {
+	h := &Handlers{
 		Order:  handler.NewHandler(u, logger),
+		Health: handler.NewHealthHandler(checker, logger),
+		Spec:   handler.NewSpecHandler(),
+		Admin:  handler.NewAdminHandler(u, logger),
 		logger: logger,
 	}
+	if pub != nil {
+		h.Ingest = handler.NewIngestHandler(pub, logger)
+	}
+	if reports != nil {
+		h.Report = handler.NewReportHandler(reports, logger)
+	}
+	return h
 }
@@ -3,31 +3,114 @@ package http
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 
 	"orderservice/internal/controller/http/handlers"
+	"orderservice/internal/controller/http/handlers/handler"
 	"orderservice/internal/controller/http/middleware"
+	"orderservice/internal/health"
+	"orderservice/internal/reporting"
 	"orderservice/internal/usecase"
 
 	"go.uber.org/zap"
 )
 
-func NewRouter(logger *zap.Logger, u usecase.OrderUsecase) http.Handler {
-	h := handlers.NewHandlers(logger, u)
+type RouterConfig struct {
+	RateLimiter *middleware.RateLimiter
+	MaxInFlight int
+	Saturated   func() bool
+	// Cached reports whether an order is in the cache; GET /orders/{id} for
+	// such orders is served even when the DB pool is saturated.
+	Cached func(orderUID string) bool
+	Health *health.Checker
+
+	// Ingest enables POST /ingest/orders, which feeds orders to the
+	// consumer pipeline without Kafka (dev mode).
+	Ingest handler.Publisher
+
+	// Reports enables GET /reports/orders.
+	Reports reporting.Source
+
+	// AdminToken enables the /admin routes and the routes that change orders,
+	// which require it as a bearer token.
+	AdminToken string
+}
+
+func NewRouter(logger *zap.Logger, u usecase.OrderUsecase, rc RouterConfig) http.Handler {
+	checker := rc.Health
+	if checker == nil {
+		checker = health.NewChecker(0)
+	}
+	h := handlers.NewHandlers(logger, u, checker, rc.Ingest, rc.Reports)
+
+	rl := rc.RateLimiter
+	if rl == nil {
+		rl = middleware.NewRateLimiter(logger, middleware.Limit{}, nil, nil)
+	}
 
 	r := chi.NewRouter()
 	r.Use(middleware.RequestLogger(logger))
+	r.Use(middleware.AuditSource)
+
+	r.Get("/healthz", h.Health.Healthz)
+	r.Get("/readyz", h.Health.Readyz)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.MaxInFlight(logger, rc.MaxInFlight, rc.Saturated, cachedOrder(rc.Cached)))
+
+		r.With(rl.Limit("/")).Get("/", h.Order.Root)
+		r.With(rl.Limit("/orders/{id}")).Get("/orders/{id}", h.Order.GetOrder)
+		r.With(rl.Limit("/orders/{id}/history")).Get("/orders/{id}/history", h.Order.OrderHistory)
+		r.With(rl.Limit("/schema/order.json")).Get("/schema/order.json", h.Spec.OrderSchema)
+		r.With(rl.Limit("/openapi.json")).Get("/openapi.json", h.Spec.OpenAPI)
 
-	r.Get("/", h.Order.Root)
-	r.Get("/orders/{id}", h.Order.GetOrder)
+		// Routes that change orders need the admin token; without one they
+		// always answer 401.
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.AdminAuth(logger, rc.AdminToken))
+			r.With(rl.Limit("/orders/{id}")).Delete("/orders/{id}", h.Order.DeleteOrder)
+			r.With(rl.Limit("/orders/{id}/items/{rid}/status")).Patch("/orders/{id}/items/{rid}/status", h.Order.UpdateItemStatus)
+		})
+
+		if h.Ingest != nil {
+			r.With(rl.Limit("/ingest/orders")).Post("/ingest/orders", h.Ingest.Ingest)
+		}
+		if h.Report != nil {
+			r.With(rl.Limit("/reports/orders")).Get("/reports/orders", h.Report.Orders)
+		}
+
+		if rc.AdminToken != "" {
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(middleware.AdminAuth(logger, rc.AdminToken))
+				r.With(rl.Limit("/admin/customers/{id}/erase")).Post("/customers/{id}/erase", h.Admin.EraseCustomer)
+				r.With(rl.Limit("/admin/orders")).Get("/orders", h.Admin.FindOrders)
+				r.With(rl.Limit("/admin/orders/export")).Get("/orders/export", h.Admin.ExportOrders)
+			})
+		}
+	})
 
 	return r
 }
 
+// cachedOrder matches GET /orders/{id} for orders in the cache. It runs after
+// routing, so the route pattern and URL parameters are known.
+func cachedOrder(cached func(string) bool) func(*http.Request) bool {
+	if cached == nil {
+		return nil
+	}
+	return func(r *http.Request) bool {
+		rctx := chi.RouteContext(r.Context())
+		return r.Method == http.MethodGet && rctx != nil && rctx.RoutePattern() == "/orders/{id}" &&
+			cached(chi.URLParam(r, "id"))
+	}
+}
+
 type Server interface {
-	Start()
+	Start() error
 	Shutdown(ctx context.Context) error
 }
 
@@ -44,13 +127,21 @@ func NewServer(logger *zap.Logger, handler http.Handler, addr string) Server {
 	return &serverImpl{srv: s, logger: logger}
 }
 
-func (s *serverImpl) Start() {
+// Start binds the listener synchronously so that address errors are returned
+// to the caller, then serves in the background.
+func (s *serverImpl) Start() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return fmt.Errorf("http: listen %s: %w", s.srv.Addr, err)
+	}
+
 	go func() {
 		s.logger.Info("HTTP server started", zap.String("address", s.srv.Addr))
-		if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			s.logger.Fatal("HTTP server error", zap.Error(err))
 		}
 	}()
+	return nil
 }
 
 func (s *serverImpl) Shutdown(ctx context.Context) error {
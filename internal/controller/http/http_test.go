@@ -0,0 +1,106 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"orderservice/internal/infrastructure/cache"
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+	"orderservice/internal/usecase"
+	"orderservice/pkg/generator"
+
+	"go.uber.org/zap"
+)
+
+func newTestRouter(t *testing.T, token string) (http.Handler, *model.Order) {
+	t.Helper()
+	gen, err := generator.New(generator.DefaultOptions())
+	if err != nil {
+		t.Fatalf("generator: %v", err)
+	}
+	ord := gen.Order()
+	u := usecase.NewOrderUsecase(repo.NewMemoryRepo(), cache.NewCache())
+	if err := u.CreateOrder(context.Background(), ord); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	return NewRouter(zap.NewNop(), u, RouterConfig{AdminToken: token}), ord
+}
+
+func serve(h http.Handler, method, path, auth string) int {
+	return serveBody(h, method, path, auth, "")
+}
+
+func serveBody(h http.Handler, method, path, auth, body string) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRouter_DeleteOrderNeedsAdminToken(t *testing.T) {
+	h, ord := newTestRouter(t, "s3cret")
+	path := "/orders/" + ord.OrderUID
+
+	for _, auth := range []string{"", "Bearer wrong"} {
+		if code := serve(h, http.MethodDelete, path, auth); code != http.StatusUnauthorized {
+			t.Fatalf("Authorization %q: expected 401, got %d", auth, code)
+		}
+	}
+	if code := serve(h, http.MethodGet, path, ""); code != http.StatusOK {
+		t.Fatalf("expected the order to survive unauthenticated deletes, got %d", code)
+	}
+	if code := serve(h, http.MethodDelete, path, "Bearer s3cret"); code != http.StatusNoContent {
+		t.Fatalf("expected an authorized delete to succeed, got %d", code)
+	}
+
+	// Without a configured token nobody can delete.
+	h, ord = newTestRouter(t, "")
+	if code := serve(h, http.MethodDelete, "/orders/"+ord.OrderUID, "Bearer "); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a configured token, got %d", code)
+	}
+}
+
+func TestRouter_UpdateItemStatusNeedsAdminToken(t *testing.T) {
+	h, ord := newTestRouter(t, "s3cret")
+	path := "/orders/" + ord.OrderUID + "/items/" + ord.Items[0].RID + "/status"
+	body := `{"status":"cancelled"}`
+
+	if code := serveBody(h, http.MethodPatch, path, "", body); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", code)
+	}
+	if code := serveBody(h, http.MethodPatch, path, "Bearer s3cret", body); code != http.StatusOK {
+		t.Fatalf("expected an authorized update to succeed, got %d", code)
+	}
+}
+
+func TestRouter_SaturatedPoolServesCachedOrders(t *testing.T) {
+	gen, err := generator.New(generator.DefaultOptions())
+	if err != nil {
+		t.Fatalf("generator: %v", err)
+	}
+	ord := gen.Order()
+	u := usecase.NewOrderUsecase(repo.NewMemoryRepo(), cache.NewCache())
+	if err := u.CreateOrder(context.Background(), ord); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	h := NewRouter(zap.NewNop(), u, RouterConfig{
+		Saturated: func() bool { return true },
+		Cached:    func(id string) bool { return id == ord.OrderUID },
+	})
+
+	if code := serve(h, http.MethodGet, "/orders/"+ord.OrderUID, ""); code != http.StatusOK {
+		t.Fatalf("expected the cached order to be served, got %d", code)
+	}
+	for _, path := range []string{"/orders/missing", "/orders/" + ord.OrderUID + "/history"} {
+		if code := serve(h, http.MethodGet, path, ""); code != http.StatusServiceUnavailable {
+			t.Fatalf("%s: expected 503, got %d", path, code)
+		}
+	}
+}
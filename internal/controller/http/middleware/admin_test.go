@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/model"
+
+	"go.uber.org/zap"
+)
+
+func TestAdminAuth(t *testing.T) {
+	h := AdminAuth(zap.NewNop(), "s3cret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tc := range []struct {
+		header string
+		want   int
+	}{
+		{"Bearer s3cret", http.StatusOK},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"s3cret", http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/customers/c1/erase", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("Authorization %q: expected %d, got %d", tc.header, tc.want, rec.Code)
+		}
+	}
+}
+
+func TestAuditSource_Principal(t *testing.T) {
+	var got model.AuditSource
+	h := AuditSource(AdminAuth(zap.NewNop(), "s3cret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = audit.SourceFrom(r.Context())
+	})))
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/customers/c1/erase", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got.Kind != model.SourceHTTP || got.Principal != "admin" {
+		t.Fatalf("unexpected admin source %+v", got)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/orders/o1", nil)
+	req.Header.Set(apiKeyHeader, "secret-key")
+	if p := principal(req); !strings.HasPrefix(p, "key:") || strings.Contains(p, "secret-key") {
+		t.Fatalf("expected a hashed API key, got %q", p)
+	}
+}
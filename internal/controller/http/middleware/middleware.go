@@ -4,6 +4,9 @@ import (
 	"context"
 	"net/http"
 
+	"orderservice/internal/audit"
+	"orderservice/internal/model"
+
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
@@ -43,3 +46,25 @@ func GetRequestID(ctx context.Context) string {
 	}
 	return val
 }
+
+// AuditSource attributes changes made while serving the request to its
+// client: a hash of the X-API-Key header, or the remote IP without one.
+func AuditSource(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := audit.WithSource(r.Context(), model.AuditSource{
+			Kind:      model.SourceHTTP,
+			Principal: principal(r),
+			RequestID: GetRequestID(r.Context()),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// principal is a hash of the API key, which must not end up in the audit
+// log in clear text, or the client IP.
+func principal(r *http.Request) string {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return "key:" + hashKey(key)
+	}
+	return clientIP(r)
+}
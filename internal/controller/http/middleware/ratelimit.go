@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// maxBuckets caps the number of client buckets; once it is reached and no
+// idle bucket can be dropped, new clients share one bucket per route.
+const maxBuckets = 100_000
+
+type Limit struct {
+	RPS   float64
+	Burst int
+}
+
+// ParseRouteLimits parses per-route overrides in the "rps/burst" form,
+// e.g. {"/orders/{id}": "10/20"}.
+func ParseRouteLimits(raw map[string]string) (map[string]Limit, error) {
+	limits := make(map[string]Limit, len(raw))
+	for route, v := range raw {
+		rpsStr, burstStr, ok := strings.Cut(v, "/")
+		if !ok {
+			return nil, fmt.Errorf("rate limit for %q: expected rps/burst, got %q", route, v)
+		}
+		rps, err := strconv.ParseFloat(strings.TrimSpace(rpsStr), 64)
+		if err != nil || rps <= 0 {
+			return nil, fmt.Errorf("rate limit for %q: invalid rps %q", route, rpsStr)
+		}
+		burst, err := strconv.Atoi(strings.TrimSpace(burstStr))
+		if err != nil || burst <= 0 {
+			return nil, fmt.Errorf("rate limit for %q: invalid burst %q", route, burstStr)
+		}
+		limits[route] = Limit{RPS: rps, Burst: burst}
+	}
+	return limits, nil
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type RateLimiter struct {
+	mu         sync.Mutex
+	def        Limit
+	routes     map[string]Limit
+	apiKeys    map[string]struct{}
+	buckets    map[string]*bucket
+	maxBuckets int
+	idleTTL    time.Duration
+	lastGC     time.Time
+	logger     *zap.Logger
+}
+
+// NewRateLimiter keys buckets by the X-API-Key header only for the given
+// apiKeys; requests with any other key, or none, are limited by client IP.
+func NewRateLimiter(logger *zap.Logger, def Limit, routes map[string]Limit, apiKeys []string) *RateLimiter {
+	keys := make(map[string]struct{}, len(apiKeys))
+	for _, k := range apiKeys {
+		if k != "" {
+			keys[hashKey(k)] = struct{}{}
+		}
+	}
+	return &RateLimiter{
+		def:        def,
+		routes:     routes,
+		apiKeys:    keys,
+		buckets:    make(map[string]*bucket),
+		maxBuckets: maxBuckets,
+		idleTTL:    10 * time.Minute,
+		lastGC:     time.Now(),
+		logger:     logger,
+	}
+}
+
+// Limit returns a middleware that applies the limit configured for route,
+// falling back to the default one. Buckets are kept per route and client.
+func (rl *RateLimiter) Limit(route string) func(next http.Handler) http.Handler {
+	lim, ok := rl.routes[route]
+	if !ok {
+		lim = rl.def
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if lim.RPS <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := rl.clientKey(r)
+			res := rl.limiter(route, client, lim).Reserve()
+			if delay := res.Delay(); delay > 0 {
+				res.Cancel()
+				retryAfter := int(math.Ceil(delay.Seconds()))
+				rl.logger.Warn("rate limit exceeded",
+					zap.String("request_id", GetRequestID(r.Context())),
+					zap.String("route", route),
+					zap.String("client", client),
+				)
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (rl *RateLimiter) limiter(route, client string, lim Limit) *rate.Limiter {
+	now := time.Now()
+	key := route + "|" + client
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if now.Sub(rl.lastGC) > rl.idleTTL {
+		rl.dropIdle(now)
+	}
+
+	b, ok := rl.buckets[key]
+	if !ok && len(rl.buckets) >= rl.maxBuckets {
+		rl.dropIdle(now)
+		if len(rl.buckets) >= rl.maxBuckets {
+			key = route + "|overflow"
+			b, ok = rl.buckets[key]
+		}
+	}
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(lim.RPS), lim.Burst)}
+		rl.buckets[key] = b
+	}
+	b.lastSeen = now
+	return b.limiter
+}
+
+func (rl *RateLimiter) dropIdle(now time.Time) {
+	for k, b := range rl.buckets {
+		if now.Sub(b.lastSeen) > rl.idleTTL {
+			delete(rl.buckets, k)
+		}
+	}
+	rl.lastGC = now
+}
+
+// clientKey is the hashed API key when it is one of the configured keys and
+// the client IP otherwise, so made-up keys neither get a fresh bucket nor
+// grow the map.
+func (rl *RateLimiter) clientKey(r *http.Request) string {
+	if key := r.Header.Get(apiKeyHeader); key != "" && len(rl.apiKeys) > 0 {
+		h := hashKey(key)
+		if _, ok := rl.apiKeys[h]; ok {
+			return "key:" + h
+		}
+	}
+	return clientIP(r)
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// MaxInFlight sheds requests with 503 once limit requests are already being
+// served or saturated reports that the downstream pool has no free capacity.
+// Requests for which cached reports true skip the saturation check, as they
+// are answered without the pool; they still count towards limit.
+func MaxInFlight(logger *zap.Logger, limit int, saturated func() bool, cached func(*http.Request) bool) func(next http.Handler) http.Handler {
+	var sem chan struct{}
+	if limit > 0 {
+		sem = make(chan struct{}, limit)
+	}
+
+	shed := func(w http.ResponseWriter, r *http.Request, reason string) {
+		logger.Warn("shedding request",
+			zap.String("request_id", GetRequestID(r.Context())),
+			zap.String("path", r.URL.Path),
+			zap.String("reason", reason),
+		)
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "service overloaded", http.StatusServiceUnavailable)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if saturated != nil && saturated() && (cached == nil || !cached(r)) {
+				shed(w, r, "db pool saturated")
+				return
+			}
+
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				default:
+					shed(w, r, "too many in-flight requests")
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
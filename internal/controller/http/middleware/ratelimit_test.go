@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRateLimiter_Returns429WithRetryAfter(t *testing.T) {
+	rl := NewRateLimiter(zap.NewNop(), Limit{RPS: 1, Burst: 1}, nil, []string{"k1", "k2"})
+	h := rl.Limit("/orders/{id}")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+	req.Header.Set(apiKeyHeader, "k1")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first request: expected 200, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected Retry-After header")
+	}
+
+	other := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+	other.Header.Set(apiKeyHeader, "k2")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, other)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("other client: expected 200, got %d", rec.Code)
+	}
+}
+
+func TestRateLimiter_UnknownAPIKeysShareTheClientIPBucket(t *testing.T) {
+	rl := NewRateLimiter(zap.NewNop(), Limit{RPS: 1, Burst: 1}, nil, []string{"k1"})
+	h := rl.Limit("/orders/{id}")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i, key := range []string{"made-up-1", "made-up-2"} {
+		req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+		req.Header.Set(apiKeyHeader, key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		want := http.StatusOK
+		if i > 0 {
+			want = http.StatusTooManyRequests
+		}
+		if rec.Code != want {
+			t.Fatalf("request with key %q: expected %d, got %d", key, want, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+	req.Header.Set(apiKeyHeader, "k1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("configured key: expected 200, got %d", rec.Code)
+	}
+	if len(rl.buckets) != 2 {
+		t.Fatalf("expected 2 buckets, got %d", len(rl.buckets))
+	}
+}
+
+func TestRateLimiter_CapsBuckets(t *testing.T) {
+	rl := NewRateLimiter(zap.NewNop(), Limit{RPS: 1, Burst: 1}, nil, nil)
+	rl.maxBuckets = 2
+	h := rl.Limit("/orders/{id}")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	codes := make([]int, 0, 4)
+	for _, addr := range []string{"10.0.0.1:1", "10.0.0.2:1", "10.0.0.3:1", "10.0.0.4:1"} {
+		req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+	}
+	if len(rl.buckets) != 3 {
+		t.Fatalf("expected 2 client buckets and 1 overflow bucket, got %d", len(rl.buckets))
+	}
+	if codes[2] != http.StatusOK || codes[3] != http.StatusTooManyRequests {
+		t.Fatalf("expected clients over the cap to share a bucket, got %v", codes)
+	}
+}
+
+func TestMaxInFlight_ShedsWhenSaturated(t *testing.T) {
+	h := MaxInFlight(zap.NewNop(), 10, func() bool { return true }, nil)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders/1", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+}
+
+func TestParseRouteLimits(t *testing.T) {
+	limits, err := ParseRouteLimits(map[string]string{"/orders/{id}": "10/20"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if l := limits["/orders/{id}"]; l.RPS != 10 || l.Burst != 20 {
+		t.Fatalf("unexpected limit: %+v", l)
+	}
+
+	if _, err := ParseRouteLimits(map[string]string{"/": "10"}); err == nil {
+		t.Fatalf("expected error for malformed limit")
+	}
+}
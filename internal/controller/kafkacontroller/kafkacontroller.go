@@ -3,68 +3,198 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"time"
 
+	"orderservice/internal/audit"
+	"orderservice/internal/codec"
+	"orderservice/internal/infrastructure/repo"
 	"orderservice/internal/model"
 	"orderservice/internal/usecase"
 	"orderservice/pkg/consumer"
 )
 
+const (
+	contentTypeHeader = "content-type"
+	// messageTypeHeader set to messageTypeItemStatus marks item status events.
+	// They share the order topic and key, so they stay ordered after the
+	// order they refer to.
+	messageTypeHeader     = "message-type"
+	messageTypeItemStatus = "item-status"
+)
+
 type KafkaController interface {
 	Start(ctx context.Context) error
 	Stop(ctx context.Context) error
-	handleMessage(ctx context.Context, key, value []byte) error
+	handleMessage(ctx context.Context, msg consumer.Message) error
+}
+
+// MessageConsumer is satisfied by the Kafka consumer and by the in-process
+// channel consumer used in dev mode.
+type MessageConsumer interface {
+	Consume(ctx context.Context, handler consumer.Handler) error
+	Close() error
 }
 
 type kafkaController struct {
 	uc       usecase.OrderUsecase
-	consumer *consumer.Consumer
+	consumer MessageConsumer
+	codecs   *codec.Registry
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	// aborted is cancelled when Stop gives up waiting; it cancels the
+	// in-flight message, whose context otherwise ignores the shutdown.
+	aborted context.Context
+	abort   context.CancelFunc
 }
 
-func NewKafkaController(uc usecase.OrderUsecase, cons *consumer.Consumer) KafkaController {
+func NewKafkaController(uc usecase.OrderUsecase, cons MessageConsumer, codecs *codec.Registry) KafkaController {
 	return &kafkaController{
 		uc:       uc,
 		consumer: cons,
+		codecs:   codecs,
 	}
 }
 
+// Start runs the consumer loop in the background until Stop is called;
+// cancelling ctx does not stop it.
 func (kc *kafkaController) Start(ctx context.Context) error {
 	log.Println("kafka controller: starting consumer loop")
 
-	err := kc.consumer.Consume(ctx, func(ctx context.Context, key, value []byte) error {
-		return kc.handleMessage(ctx, key, value)
-	})
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	kc.cancel = cancel
+	kc.aborted, kc.abort = context.WithCancel(context.WithoutCancel(ctx))
+	kc.done = make(chan struct{})
 
-	if err != nil {
-		log.Printf("kafka controller: consumer stopped with error: %v", err)
-		return err
-	}
+	go func() {
+		defer close(kc.done)
+		err := kc.consumer.Consume(runCtx, kc.process)
+		if err != nil {
+			log.Printf("kafka controller: consumer stopped with error: %v", err)
+		}
+	}()
 	return nil
 }
 
+// Stop stops fetching, waits for the in-flight message to be processed and
+// committed, then closes the reader. If ctx ends first, the in-flight message
+// is cancelled and the reader is closed anyway.
 func (kc *kafkaController) Stop(ctx context.Context) error {
 	log.Println("kafka controller: stopping...")
-	if err := kc.consumer.Close(); err != nil {
-		log.Printf("kafka controller: close error: %v", err)
-		return err
+	var err error
+	if kc.cancel != nil {
+		kc.cancel()
+		select {
+		case <-kc.done:
+		case <-ctx.Done():
+			err = fmt.Errorf("kafka controller: in-flight message not drained, cancelled: %w", ctx.Err())
+		}
+		kc.abort()
 	}
-	return nil
+
+	if cerr := kc.consumer.Close(); cerr != nil {
+		log.Printf("kafka controller: close error: %v", cerr)
+		err = errors.Join(err, cerr)
+	}
+	return err
 }
 
-func (kc *kafkaController) handleMessage(ctx context.Context, key, value []byte) error {
-	var ord model.Order
-	if err := json.Unmarshal(value, &ord); err != nil {
-		return err
+// process runs handleMessage under a context that Stop cancels when its
+// deadline passes.
+func (kc *kafkaController) process(ctx context.Context, msg consumer.Message) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(kc.aborted, cancel)()
+	return kc.handleMessage(ctx, msg)
+}
+
+func (kc *kafkaController) handleMessage(ctx context.Context, msg consumer.Message) error {
+	if msg.Header(messageTypeHeader) == messageTypeItemStatus {
+		return kc.handleItemStatus(ctx, msg)
+	}
+
+	c, err := kc.codecs.Lookup(msg.Header(contentTypeHeader))
+	if err != nil {
+		return consumer.Permanent(err)
 	}
 
-	processCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ord, err := c.Decode(ctx, msg.Value, msg.Headers)
+	if err != nil {
+		return consumer.Permanent(err)
+	}
+
+	processCtx, cancel := context.WithTimeout(audit.WithSource(ctx, messageSource(msg)), 10*time.Second)
 	defer cancel()
 
-	if err := kc.uc.CreateOrder(processCtx, &ord); err != nil {
+	if err := kc.uc.CreateOrder(processCtx, ord); err != nil {
+		switch {
+		case errors.Is(err, repo.ErrOrderDeleted):
+			log.Printf("kafka controller: order %s is deleted, message dropped", ord.OrderUID)
+			return nil
+		case errors.Is(err, repo.ErrOrderArchived):
+			log.Printf("kafka controller: order %s is archived, message dropped", ord.OrderUID)
+			return nil
+		case errors.Is(err, repo.ErrCustomerErased):
+			log.Printf("kafka controller: order %s belongs to an erased customer, message dropped", ord.OrderUID)
+			return nil
+		case errors.Is(err, repo.ErrReservedPrefix):
+			return consumer.Permanent(err)
+		}
 		return err
 	}
 
 	log.Printf("kafka controller: order %s processed successfully", ord.OrderUID)
 	return nil
 }
+
+// handleItemStatus applies a JSON model.StatusUpdate. Forbidden transitions go
+// to the DLQ; unknown orders and items go to the retry topic, which the
+// service consumes after KAFKA_RETRY_DELAY, since the order may still be on
+// its way.
+func (kc *kafkaController) handleItemStatus(ctx context.Context, msg consumer.Message) error {
+	var upd model.StatusUpdate
+	if err := json.Unmarshal(msg.Value, &upd); err != nil {
+		return consumer.Permanent(fmt.Errorf("item status event: %w", err))
+	}
+	if upd.OrderUID == "" || upd.RID == "" {
+		return consumer.Permanent(errors.New("item status event: order_uid and rid are required"))
+	}
+
+	processCtx, cancel := context.WithTimeout(audit.WithSource(ctx, messageSource(msg)), 10*time.Second)
+	defer cancel()
+
+	if _, err := kc.uc.UpdateItemStatus(processCtx, upd); err != nil {
+		switch {
+		case errors.Is(err, repo.ErrOrderDeleted):
+			log.Printf("kafka controller: order %s is deleted, status event dropped", upd.OrderUID)
+			return nil
+		case errors.Is(err, model.ErrInvalidTransition):
+			return consumer.Permanent(fmt.Errorf("order %s item %s: %w", upd.OrderUID, upd.RID, err))
+		}
+		return fmt.Errorf("order %s item %s: %w", upd.OrderUID, upd.RID, err)
+	}
+
+	log.Printf("kafka controller: order %s item %s is %s", upd.OrderUID, upd.RID, upd.Status)
+	return nil
+}
+
+func messageSource(msg consumer.Message) model.AuditSource {
+	if msg.Topic == "" {
+		// Queued by the in-process HTTP ingest, which passes its request on.
+		return model.AuditSource{
+			Kind:      model.SourceHTTP,
+			Principal: msg.Header(audit.PrincipalHeader),
+			RequestID: msg.Header(audit.RequestIDHeader),
+		}
+	}
+	return model.AuditSource{
+		Kind:      model.SourceKafka,
+		RequestID: msg.Header(audit.RequestIDHeader),
+		Topic:     msg.Topic,
+		Partition: &msg.Partition,
+		Offset:    &msg.Offset,
+	}
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"orderservice/internal/codec"
+	"orderservice/internal/model"
+	"orderservice/internal/usecase"
+	"orderservice/pkg/consumer"
+	"orderservice/pkg/generator"
+)
+
+// blockingUsecase holds CreateOrder until its context is cancelled.
+type blockingUsecase struct {
+	usecase.OrderUsecase
+	started chan struct{}
+	result  chan error
+}
+
+func (u *blockingUsecase) CreateOrder(ctx context.Context, ord *model.Order) error {
+	close(u.started)
+	<-ctx.Done()
+	u.result <- ctx.Err()
+	return ctx.Err()
+}
+
+// oneMessageConsumer hands a single message to the handler the way the
+// Kafka consumer does, with a context that ignores cancellation.
+type oneMessageConsumer struct {
+	msg    consumer.Message
+	closed bool
+}
+
+func (c *oneMessageConsumer) Consume(ctx context.Context, handler consumer.Handler) error {
+	_ = handler(context.WithoutCancel(ctx), c.msg)
+	<-ctx.Done()
+	return nil
+}
+
+func (c *oneMessageConsumer) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestStop_CancelsInFlightMessageAfterDeadline(t *testing.T) {
+	gen, err := generator.New(generator.DefaultOptions())
+	if err != nil {
+		t.Fatalf("generator: %v", err)
+	}
+	payload, _ := json.Marshal(gen.Order())
+
+	uc := &blockingUsecase{started: make(chan struct{}), result: make(chan error, 1)}
+	cons := &oneMessageConsumer{msg: consumer.Message{Value: payload}}
+	kc := NewKafkaController(uc, cons, codec.NewRegistry(codec.NewJSON()))
+	if err := kc.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	<-uc.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := kc.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a drain timeout, got %v", err)
+	}
+	if !cons.closed {
+		t.Fatal("expected the consumer to be closed after a timeout")
+	}
+	select {
+	case err := <-uc.result:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the in-flight message to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the in-flight message was not cancelled")
+	}
+}
@@ -2,82 +2,403 @@ package di
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"orderservice/config"
+	"orderservice/internal/codec"
 	ctrlhttp "orderservice/internal/controller/http"
+	"orderservice/internal/controller/http/middleware"
 	ctrlkafka "orderservice/internal/controller/kafkacontroller"
+	"orderservice/internal/health"
 	"orderservice/internal/infrastructure/cache"
+	"orderservice/internal/infrastructure/fieldcrypt"
+	"orderservice/internal/infrastructure/migrate"
+	"orderservice/internal/infrastructure/outbox"
 	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/infrastructure/retention"
+	"orderservice/internal/reporting"
 	"orderservice/internal/usecase"
 	"orderservice/pkg/connectors"
 	"orderservice/pkg/consumer"
+	"orderservice/pkg/generator"
+	"orderservice/pkg/schemaregistry"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/segmentio/kafka-go"
+	"github.com/twmb/franz-go/pkg/kgo"
 	"go.uber.org/zap"
 )
 
 type Container struct {
-	Config   *config.Config
-	DB       *pgxpool.Pool
-	Repo     repo.Repo
-	Cache    cache.Cache
-	Usecase  usecase.OrderUsecase
-	Consumer *consumer.Consumer
-	Kafka    ctrlkafka.KafkaController
-	Router   http.Handler
-	logger   *zap.Logger
+	Config    *config.Config
+	DB        *pgxpool.Pool // nil with the memory repo backend
+	Repo      repo.Repo
+	Cache     cache.Cache
+	Usecase   usecase.OrderUsecase
+	Consumer  *consumer.Consumer        // nil with the http ingest backend
+	Ingest    *consumer.ChannelConsumer // nil with the kafka ingest backend
+	Events    *kafka.Writer             // nil with the memory repo backend
+	Outbox    *outbox.Relay             // nil with the memory repo backend
+	Retention *retention.Job            // nil unless RETENTION_MAX_AGE is set with the postgres backend
+	Reports   *reporting.Refresher      // nil unless REPORT_VIEWS is set with the postgres backend
+	Kafka     ctrlkafka.KafkaController
+	Router    http.Handler
+	Health    *health.Checker
+	logger    *zap.Logger
 }
 
 func New(logger *zap.Logger, ctx context.Context, cfg *config.Config) (*Container, error) {
-	db, err := connectors.ConnectPostgres(ctx, cfg)
+	checker := health.NewChecker(2 * time.Second)
+
+	var (
+		db  *pgxpool.Pool
+		r   repo.Repo
+		err error
+	)
+	piiOpts, err := PIIOptions(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("app: connect postgres: %w", err)
+		return nil, fmt.Errorf("app: %w", err)
+	}
+	switch cfg.RepoBackend {
+	case config.RepoBackendPostgres:
+		db, err = connectors.ConnectPostgres(ctx, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("app: connect postgres: %w", err)
+		}
+		if cfg.AutoMigrate {
+			if err := autoMigrate(ctx, db); err != nil {
+				db.Close()
+				return nil, fmt.Errorf("app: %w", err)
+			}
+		}
+		r = repo.NewRepo(db, piiOpts...)
+
+		checker.Register("postgres", func(ctx context.Context) (map[string]any, error) {
+			stat := db.Stat()
+			details := map[string]any{
+				"acquired_conns": stat.AcquiredConns(),
+				"total_conns":    stat.TotalConns(),
+				"max_conns":      stat.MaxConns(),
+			}
+			return details, db.Ping(ctx)
+		})
+	case config.RepoBackendMemory:
+		r = repo.NewMemoryRepo()
+		if err := seedOrders(ctx, r, cfg.DevSeedOrders); err != nil {
+			return nil, fmt.Errorf("app: seed memory repo: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("app: unknown repo backend %q", cfg.RepoBackend)
+	}
+
+	// cleanup releases what New has set up so far; every later error return
+	// must call it.
+	cleanup := func() {
+		if db != nil {
+			db.Close()
+		}
 	}
 
-	r := repo.NewRepo(db)
 	c := cache.NewCacheWithTTL(cfg.CacheTTL)
 	u := usecase.NewOrderUsecase(r, c)
 
-	loadCtx, loadCancel := context.WithTimeout(ctx, 30*time.Second)
-	defer loadCancel()
-	orders, err := r.GetAllOrders(loadCtx)
-	if err != nil {
+	cacheWarm := health.NewFlag("cache warm-up")
+	checker.Register("cache", cacheWarm.Check)
+
+	if err := preloadCache(ctx, r, c); err != nil {
+		// Serve from the database meanwhile; /readyz reports the cache as not
+		// ready until a retry succeeds.
 		log.Printf("di: failed to preload cache, starting with empty cache: %v", err)
+		go retryPreload(ctx, r, c, cacheWarm)
 	} else {
-		c.SetupCache(orders)
-		log.Printf("di: preloaded cache with %d orders", len(orders))
+		cacheWarm.Set()
+	}
+
+	var (
+		cons   *consumer.Consumer
+		ingest *consumer.ChannelConsumer
+		source ctrlkafka.MessageConsumer
+	)
+	switch cfg.IngestBackend {
+	case config.IngestBackendKafka:
+		src, sink, err := newKafkaClients(cfg)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("app: %w", err)
+		}
+		closeDB := cleanup
+		cleanup = func() {
+			src.Close()
+			sink.Close()
+			closeDB()
+		}
+		ordering, err := consumer.ParseOrdering(cfg.KafkaOrdering)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("app: %w", err)
+		}
+		cons = consumer.NewConsumer(src, sink,
+			consumer.WithTopics(cfg.KafkaRetryTopic, cfg.KafkaDLQTopic),
+			consumer.WithWorkers(cfg.KafkaWorkers, ordering),
+			consumer.WithRetryDelay(cfg.KafkaRetryDelay),
+		)
+		source = cons
+
+		checker.Register("kafka", func(ctx context.Context) (map[string]any, error) {
+			lag := cons.Lag()
+			details := map[string]any{"lag": lag}
+			if err := cons.Ping(ctx); err != nil {
+				return details, err
+			}
+			if cfg.ReadyKafkaMaxLag > 0 && lag > cfg.ReadyKafkaMaxLag {
+				return details, fmt.Errorf("consumer lag %d exceeds %d", lag, cfg.ReadyKafkaMaxLag)
+			}
+			return details, nil
+		})
+	case config.IngestBackendHTTP:
+		ingest = consumer.NewChannelConsumer(64)
+		source = ingest
+	default:
+		cleanup()
+		return nil, fmt.Errorf("app: unknown ingest backend %q", cfg.IngestBackend)
+	}
+
+	routeLimits, err := middleware.ParseRouteLimits(cfg.RateLimitRoutes)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("app: rate limits: %w", err)
 	}
+	limiter := middleware.NewRateLimiter(logger, middleware.Limit{
+		RPS:   cfg.RateLimitRPS,
+		Burst: cfg.RateLimitBurst,
+	}, routeLimits, cfg.RateLimitAPIKeys)
 
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: []string{cfg.KafkaBrokers},
-		Topic:   cfg.KafkaOrderTopic,
-		GroupID: cfg.KafkaGroupID,
-	})
+	var (
+		events    *kafka.Writer
+		relay     *outbox.Relay
+		retJob    *retention.Job
+		refresher *reporting.Refresher
+		reports   reporting.Source = reporting.NewOrders(r.GetAllOrders)
+		saturated func() bool
+	)
+	if db != nil && cfg.RetentionMaxAge > 0 {
+		retJob, err = retention.NewJob(repo.NewArchiver(db, piiOpts...), c, retention.Options{
+			MaxAge:    cfg.RetentionMaxAge,
+			Interval:  cfg.RetentionInterval,
+			BatchSize: cfg.RetentionBatchSize,
+			Mode:      cfg.RetentionMode,
+			ExportDir: cfg.RetentionExportDir,
+		})
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("app: %w", err)
+		}
+	}
+	if db != nil {
+		events = &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers()...),
+			Topic:        cfg.KafkaEventsTopic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		}
+		closeRest := cleanup
+		cleanup = func() {
+			events.Close()
+			closeRest()
+		}
+		relay = outbox.NewRelay(db, events, cfg.OutboxBatchSize, cfg.OutboxPollInterval, cfg.OutboxRetention)
+		reportOpts := reporting.PostgresOptions{Views: cfg.ReportViews}
+		if len(piiOpts) > 0 {
+			reportOpts.Encrypted = cfg.PIIFields
+		}
+		reports = reporting.NewPostgres(db, reportOpts)
+		if cfg.ReportViews {
+			refresher = reporting.NewRefresher(db, cfg.ReportRefreshInterval)
+		}
+		saturated = func() bool {
+			stat := db.Stat()
+			return stat.AcquiredConns() >= stat.MaxConns()
+		}
+	}
 
-	writer := kafka.NewWriter(kafka.WriterConfig{
-		Brokers:  []string{cfg.KafkaBrokers},
-		Topic:    cfg.KafkaRetryTopic,
-		Balancer: &kafka.LeastBytes{},
-	})
-	cons := consumer.NewConsumer(reader, writer)
+	rc := ctrlhttp.RouterConfig{
+		RateLimiter: limiter,
+		MaxInFlight: cfg.HTTPMaxInFlight,
+		Saturated:   saturated,
+		Cached: func(id string) bool {
+			_, ok := c.Get(id)
+			return ok
+		},
+		Health:     checker,
+		AdminToken: cfg.AdminToken,
+		Reports:    reports,
+	}
+	if ingest != nil {
+		rc.Ingest = ingest
+	}
+	router := ctrlhttp.NewRouter(logger, u, rc)
 
-	router := ctrlhttp.NewRouter(logger, u)
+	jsonCodec := codec.NewJSON()
+	if cfg.KafkaStrictDecode {
+		jsonCodec = codec.NewStrictJSON()
+	}
+	codecs := codec.NewRegistry(jsonCodec)
+	codecs.Register(codec.NewProtobuf(), "application/protobuf", "application/vnd.google.protobuf")
+	if cfg.SchemaRegistryURL != "" {
+		sr, err := schemaregistry.New(cfg.SchemaRegistryURL)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("app: schema registry: %w", err)
+		}
+		codecs.Register(codec.NewAvro(sr, cfg.KafkaOrderTopic+"-value"), "avro/binary", "application/avro")
+	}
 
-	kctrl := ctrlkafka.NewKafkaController(u, cons)
+	kctrl := ctrlkafka.NewKafkaController(u, source, codecs)
 
 	return &Container{
-		Config:   cfg,
-		DB:       db,
-		Repo:     r,
-		Cache:    c,
-		Usecase:  u,
-		Consumer: cons,
-		Kafka:    kctrl,
-		Router:   router,
+		Config:    cfg,
+		DB:        db,
+		Repo:      r,
+		Cache:     c,
+		Usecase:   u,
+		Consumer:  cons,
+		Ingest:    ingest,
+		Events:    events,
+		Outbox:    relay,
+		Retention: retJob,
+		Reports:   refresher,
+		Kafka:     kctrl,
+		Router:    router,
+		Health:    checker,
 	}, nil
 }
+
+// autoMigrate applies pending migrations on start. Replicas starting together
+// wait on the migrator's advisory lock instead of applying them twice.
+func autoMigrate(ctx context.Context, db *pgxpool.Pool) error {
+	m, err := migrate.FromPool(db)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return m.Up(ctx, log.Writer())
+}
+
+// PIIOptions returns the repo options for delivery PII encryption, or none if
+// no master keys are configured.
+func PIIOptions(cfg *config.Config) ([]repo.Option, error) {
+	keys, active, err := fieldcrypt.LoadKeys(cfg.PIIKeys, cfg.PIIKeyFile, cfg.PIIActiveKey)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		return nil, nil
+	}
+	indexKey, err := base64.StdEncoding.DecodeString(cfg.PIIIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("PII_INDEX_KEY: %w", err)
+	}
+	c, err := fieldcrypt.New(keys, active, indexKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.CheckPIIFields(cfg.PIIFields); err != nil {
+		return nil, err
+	}
+	return []repo.Option{repo.WithEncryption(c, cfg.PIIFields)}, nil
+}
+
+// seedOrders fills r with n generated orders that pass validation.
+func seedOrders(ctx context.Context, r repo.Repo, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	gen, err := generator.New(generator.DefaultOptions())
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if _, err := r.CreateOrder(ctx, gen.Order()); err != nil {
+			return err
+		}
+	}
+	log.Printf("di: seeded %d generated orders", n)
+	return nil
+}
+
+// newKafkaClients builds the order source and the retry/DLQ sink with the
+// client library selected by KAFKA_CLIENT. The source reads the retry topic
+// too, so retried messages go through the same handlers.
+func newKafkaClients(cfg *config.Config) (consumer.MessageSource, consumer.MessageSink, error) {
+	switch cfg.KafkaClient {
+	case config.KafkaClientSegmentio:
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:     cfg.Brokers(),
+			GroupTopics: []string{cfg.KafkaOrderTopic, cfg.KafkaRetryTopic},
+			GroupID:     cfg.KafkaGroupID,
+		})
+		// The consumer commits the source offset only after a retry or DLQ
+		// write returns, so it must be acknowledged by every replica.
+		writer := &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers()...),
+			Balancer:     &kafka.LeastBytes{},
+			RequiredAcks: kafka.RequireAll,
+		}
+		return consumer.NewSegmentioSource(reader), consumer.NewSegmentioSink(writer), nil
+	case config.KafkaClientFranz:
+		brokers := cfg.Brokers()
+		src, err := consumer.NewFranzSource(
+			kgo.SeedBrokers(brokers...),
+			kgo.ConsumerGroup(cfg.KafkaGroupID),
+			kgo.ConsumeTopics(cfg.KafkaOrderTopic, cfg.KafkaRetryTopic),
+			kgo.DisableAutoCommit(),
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("franz-go consumer: %w", err)
+		}
+		writer, err := kgo.NewClient(kgo.SeedBrokers(brokers...))
+		if err != nil {
+			src.Close()
+			return nil, nil, fmt.Errorf("franz-go producer: %w", err)
+		}
+		return src, consumer.NewFranzSink(writer), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown kafka client %q", cfg.KafkaClient)
+	}
+}
+
+func preloadCache(ctx context.Context, r repo.Repo, c cache.Cache) error {
+	loadCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	orders, err := r.GetAllOrders(loadCtx)
+	if err != nil {
+		return err
+	}
+	c.SetupCache(orders)
+	log.Printf("di: preloaded cache with %d orders", len(orders))
+	return nil
+}
+
+// retryPreload repeats a failed preload with backoff until it succeeds or ctx
+// is done.
+func retryPreload(ctx context.Context, r repo.Repo, c cache.Cache, warm *health.Flag) {
+	delay := time.Second
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		err := preloadCache(ctx, r, c)
+		if err == nil {
+			warm.Set()
+			return
+		}
+		log.Printf("di: cache preload retry failed: %v", err)
+		delay = min(2*delay, time.Minute)
+	}
+}
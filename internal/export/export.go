@@ -0,0 +1,427 @@
+// Package export writes orders one at a time as CSV, JSONL or Parquet, so
+// bulk extracts can be streamed without holding them in memory.
+//
+// CSV and Parquet are flat: every item is a row carrying the columns of its
+// order, delivery and payment. An order without items gives one row with
+// empty item columns.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"orderservice/internal/model"
+)
+
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatJSONL   Format = "jsonl"
+	FormatParquet Format = "parquet"
+)
+
+func ParseFormat(v string) (Format, error) {
+	switch f := Format(v); f {
+	case FormatCSV, FormatJSONL, FormatParquet:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q, want csv, jsonl or parquet", v)
+	}
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// Writer writes orders to an underlying io.Writer. Close flushes buffered
+// data (and the Parquet footer) but does not close the io.Writer.
+type Writer interface {
+	Write(ord *model.Order) error
+	Close() error
+}
+
+func NewWriter(w io.Writer, f Format) (Writer, error) {
+	switch f {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatJSONL:
+		bw := bufio.NewWriter(w)
+		return &jsonlWriter{buf: bw, enc: json.NewEncoder(bw)}, nil
+	case FormatParquet:
+		return newParquetWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", f)
+	}
+}
+
+type jsonlWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w *jsonlWriter) Write(ord *model.Order) error {
+	return w.enc.Encode(ord)
+}
+
+func (w *jsonlWriter) Close() error {
+	return w.buf.Flush()
+}
+
+// Row is a flattened item. The parquet tags name the columns of both flat
+// formats.
+type Row struct {
+	OrderUID          string    `parquet:"order_uid"`
+	TrackNumber       string    `parquet:"track_number"`
+	Entry             string    `parquet:"entry"`
+	Locale            string    `parquet:"locale"`
+	InternalSignature string    `parquet:"internal_signature"`
+	CustomerID        string    `parquet:"customer_id"`
+	DeliveryService   string    `parquet:"delivery_service"`
+	ShardKey          string    `parquet:"shardkey"`
+	SMID              int64     `parquet:"sm_id"`
+	DateCreated       time.Time `parquet:"date_created,timestamp(microsecond:utc)"`
+	OOFShard          string    `parquet:"oof_shard"`
+
+	DeliveryName    string `parquet:"delivery_name"`
+	DeliveryPhone   string `parquet:"delivery_phone"`
+	DeliveryZip     string `parquet:"delivery_zip"`
+	DeliveryCity    string `parquet:"delivery_city"`
+	DeliveryAddress string `parquet:"delivery_address"`
+	DeliveryRegion  string `parquet:"delivery_region"`
+	DeliveryEmail   string `parquet:"delivery_email"`
+
+	PaymentTransaction  string `parquet:"payment_transaction"`
+	PaymentRequestID    string `parquet:"payment_request_id"`
+	PaymentCurrency     string `parquet:"payment_currency"`
+	PaymentProvider     string `parquet:"payment_provider"`
+	PaymentAmount       int64  `parquet:"payment_amount"`
+	PaymentDt           int64  `parquet:"payment_dt"`
+	PaymentBank         string `parquet:"payment_bank"`
+	PaymentDeliveryCost int64  `parquet:"payment_delivery_cost"`
+	PaymentGoodsTotal   int64  `parquet:"payment_goods_total"`
+	PaymentCustomFee    int64  `parquet:"payment_custom_fee"`
+
+	ItemChrtID      *int64  `parquet:"item_chrt_id,optional"`
+	ItemTrackNumber *string `parquet:"item_track_number,optional"`
+	ItemPrice       *int64  `parquet:"item_price,optional"`
+	ItemRID         *string `parquet:"item_rid,optional"`
+	ItemName        *string `parquet:"item_name,optional"`
+	ItemSale        *int64  `parquet:"item_sale,optional"`
+	ItemSize        *string `parquet:"item_size,optional"`
+	ItemTotalPrice  *int64  `parquet:"item_total_price,optional"`
+	ItemNMID        *int64  `parquet:"item_nm_id,optional"`
+	ItemBrand       *string `parquet:"item_brand,optional"`
+	ItemStatus      *string `parquet:"item_status,optional"`
+}
+
+// Flatten returns the rows of ord, one per item.
+func Flatten(ord *model.Order) []Row {
+	base := Row{
+		OrderUID:          ord.OrderUID,
+		TrackNumber:       ord.TrackNumber,
+		Entry:             ord.Entry,
+		Locale:            ord.Locale,
+		InternalSignature: ord.InternalSignature,
+		CustomerID:        ord.CustomerID,
+		DeliveryService:   ord.DeliveryService,
+		ShardKey:          ord.ShardKey,
+		SMID:              int64(ord.SMID),
+		DateCreated:       ord.DateCreated.UTC(),
+		OOFShard:          ord.OOFShard,
+
+		DeliveryName:    ord.Delivery.Name,
+		DeliveryPhone:   ord.Delivery.Phone,
+		DeliveryZip:     ord.Delivery.Zip,
+		DeliveryCity:    ord.Delivery.City,
+		DeliveryAddress: ord.Delivery.Address,
+		DeliveryRegion:  ord.Delivery.Region,
+		DeliveryEmail:   ord.Delivery.Email,
+
+		PaymentTransaction:  ord.Payment.Transaction,
+		PaymentRequestID:    ord.Payment.RequestID,
+		PaymentCurrency:     ord.Payment.Currency,
+		PaymentProvider:     ord.Payment.Provider,
+		PaymentAmount:       ord.Payment.Amount,
+		PaymentDt:           ord.Payment.PaymentDt,
+		PaymentBank:         ord.Payment.Bank,
+		PaymentDeliveryCost: ord.Payment.DeliveryCost,
+		PaymentGoodsTotal:   ord.Payment.GoodsTotal,
+		PaymentCustomFee:    ord.Payment.CustomFee,
+	}
+	if len(ord.Items) == 0 {
+		return []Row{base}
+	}
+	rows := make([]Row, len(ord.Items))
+	for i, it := range ord.Items {
+		r := base
+		status := it.Status.String()
+		r.ItemChrtID = &it.ChrtID
+		r.ItemTrackNumber = &it.TrackNumber
+		r.ItemPrice = &it.Price
+		r.ItemRID = &it.RID
+		r.ItemName = &it.Name
+		r.ItemSale = &it.Sale
+		r.ItemSize = &it.Size
+		r.ItemTotalPrice = &it.TotalPrice
+		r.ItemNMID = &it.NMID
+		r.ItemBrand = &it.Brand
+		r.ItemStatus = &status
+		rows[i] = r
+	}
+	return rows
+}
+
+// Columns are the CSV header and the Parquet column names, in order.
+var Columns = []string{
+	"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id",
+	"delivery_service", "shardkey", "sm_id", "date_created", "oof_shard",
+	"delivery_name", "delivery_phone", "delivery_zip", "delivery_city", "delivery_address",
+	"delivery_region", "delivery_email",
+	"payment_transaction", "payment_request_id", "payment_currency", "payment_provider",
+	"payment_amount", "payment_dt", "payment_bank", "payment_delivery_cost",
+	"payment_goods_total", "payment_custom_fee",
+	"item_chrt_id", "item_track_number", "item_price", "item_rid", "item_name", "item_sale",
+	"item_size", "item_total_price", "item_nm_id", "item_brand", "item_status",
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Columns); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: cw}, nil
+}
+
+func (w *csvWriter) Write(ord *model.Order) error {
+	for _, r := range Flatten(ord) {
+		if err := w.w.Write(r.record()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (r Row) record() []string {
+	i64 := func(v int64) string { return strconv.FormatInt(v, 10) }
+	opt := func(v *string) string {
+		if v == nil {
+			return ""
+		}
+		return *v
+	}
+	optInt := func(v *int64) string {
+		if v == nil {
+			return ""
+		}
+		return i64(*v)
+	}
+	return []string{
+		r.OrderUID, r.TrackNumber, r.Entry, r.Locale, r.InternalSignature, r.CustomerID,
+		r.DeliveryService, r.ShardKey, i64(r.SMID), r.DateCreated.Format(time.RFC3339Nano), r.OOFShard,
+		r.DeliveryName, r.DeliveryPhone, r.DeliveryZip, r.DeliveryCity, r.DeliveryAddress,
+		r.DeliveryRegion, r.DeliveryEmail,
+		r.PaymentTransaction, r.PaymentRequestID, r.PaymentCurrency, r.PaymentProvider,
+		i64(r.PaymentAmount), i64(r.PaymentDt), r.PaymentBank, i64(r.PaymentDeliveryCost),
+		i64(r.PaymentGoodsTotal), i64(r.PaymentCustomFee),
+		optInt(r.ItemChrtID), opt(r.ItemTrackNumber), optInt(r.ItemPrice), opt(r.ItemRID),
+		opt(r.ItemName), optInt(r.ItemSale), opt(r.ItemSize), optInt(r.ItemTotalPrice),
+		optInt(r.ItemNMID), opt(r.ItemBrand), opt(r.ItemStatus),
+	}
+}
+
+// ParseRecord parses a CSV record written by the CSV writer, with the fields
+// in Columns order.
+func ParseRecord(rec []string) (Row, error) {
+	var (
+		r   Row
+		err error
+	)
+	if len(rec) != len(Columns) {
+		return r, fmt.Errorf("want %d fields, got %d", len(Columns), len(rec))
+	}
+	field := func(i int) string { return rec[i] }
+	i64 := func(i int) int64 {
+		if err != nil {
+			return 0
+		}
+		var v int64
+		if v, err = strconv.ParseInt(rec[i], 10, 64); err != nil {
+			err = fmt.Errorf("%s: %w", Columns[i], err)
+		}
+		return v
+	}
+	opt := func(i int) *string {
+		if rec[i] == "" {
+			return nil
+		}
+		return &rec[i]
+	}
+	optInt := func(i int) *int64 {
+		if rec[i] == "" {
+			return nil
+		}
+		v := i64(i)
+		return &v
+	}
+
+	r = Row{
+		OrderUID: field(0), TrackNumber: field(1), Entry: field(2), Locale: field(3),
+		InternalSignature: field(4), CustomerID: field(5), DeliveryService: field(6),
+		ShardKey: field(7), SMID: i64(8), OOFShard: field(10),
+
+		DeliveryName: field(11), DeliveryPhone: field(12), DeliveryZip: field(13),
+		DeliveryCity: field(14), DeliveryAddress: field(15), DeliveryRegion: field(16),
+		DeliveryEmail: field(17),
+
+		PaymentTransaction: field(18), PaymentRequestID: field(19), PaymentCurrency: field(20),
+		PaymentProvider: field(21), PaymentAmount: i64(22), PaymentDt: i64(23),
+		PaymentBank: field(24), PaymentDeliveryCost: i64(25), PaymentGoodsTotal: i64(26),
+		PaymentCustomFee: i64(27),
+
+		ItemChrtID: optInt(28), ItemTrackNumber: opt(29), ItemPrice: optInt(30),
+		ItemRID: opt(31), ItemName: opt(32), ItemSale: optInt(33), ItemSize: opt(34),
+		ItemTotalPrice: optInt(35), ItemNMID: optInt(36), ItemBrand: opt(37),
+		ItemStatus: opt(38),
+	}
+	if err != nil {
+		return r, err
+	}
+	if r.DateCreated, err = time.Parse(time.RFC3339Nano, rec[9]); err != nil {
+		return r, fmt.Errorf("date_created: %w", err)
+	}
+	return r, nil
+}
+
+// Unflatten rebuilds an order from its rows; the order columns are taken from
+// the first one. Rows without an item_rid carry no item.
+func Unflatten(rows []Row) (*model.Order, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no rows")
+	}
+	r := rows[0]
+	ord := &model.Order{
+		OrderUID:          r.OrderUID,
+		TrackNumber:       r.TrackNumber,
+		Entry:             r.Entry,
+		Locale:            r.Locale,
+		InternalSignature: r.InternalSignature,
+		CustomerID:        r.CustomerID,
+		DeliveryService:   r.DeliveryService,
+		ShardKey:          r.ShardKey,
+		SMID:              int(r.SMID),
+		DateCreated:       r.DateCreated,
+		OOFShard:          r.OOFShard,
+		Delivery: model.Delivery{
+			Name:    r.DeliveryName,
+			Phone:   r.DeliveryPhone,
+			Zip:     r.DeliveryZip,
+			City:    r.DeliveryCity,
+			Address: r.DeliveryAddress,
+			Region:  r.DeliveryRegion,
+			Email:   r.DeliveryEmail,
+		},
+		Payment: model.Payment{
+			Transaction:  r.PaymentTransaction,
+			RequestID:    r.PaymentRequestID,
+			Currency:     r.PaymentCurrency,
+			Provider:     r.PaymentProvider,
+			Amount:       r.PaymentAmount,
+			PaymentDt:    r.PaymentDt,
+			Bank:         r.PaymentBank,
+			DeliveryCost: r.PaymentDeliveryCost,
+			GoodsTotal:   r.PaymentGoodsTotal,
+			CustomFee:    r.PaymentCustomFee,
+		},
+	}
+	str := func(v *string) string {
+		if v == nil {
+			return ""
+		}
+		return *v
+	}
+	num := func(v *int64) int64 {
+		if v == nil {
+			return 0
+		}
+		return *v
+	}
+	for _, r := range rows {
+		if r.ItemRID == nil {
+			continue
+		}
+		it := model.Item{
+			ChrtID:      num(r.ItemChrtID),
+			TrackNumber: str(r.ItemTrackNumber),
+			Price:       num(r.ItemPrice),
+			RID:         *r.ItemRID,
+			Name:        str(r.ItemName),
+			Sale:        num(r.ItemSale),
+			Size:        str(r.ItemSize),
+			TotalPrice:  num(r.ItemTotalPrice),
+			NMID:        num(r.ItemNMID),
+			Brand:       str(r.ItemBrand),
+		}
+		if r.ItemStatus != nil {
+			s, err := model.ParseItemStatus(*r.ItemStatus)
+			if err != nil {
+				return nil, fmt.Errorf("item %s: %w", it.RID, err)
+			}
+			it.Status = s
+		}
+		ord.Items = append(ord.Items, it)
+	}
+	return ord, nil
+}
+
+// parquetRowGroupSize caps the rows buffered before a row group is written
+// out, which is what bounds the memory of a Parquet export.
+const parquetRowGroupSize = 10000
+
+type parquetWriter struct {
+	w *parquet.GenericWriter[Row]
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	return &parquetWriter{w: parquet.NewGenericWriter[Row](w,
+		parquet.MaxRowsPerRowGroup(parquetRowGroupSize),
+		parquet.Compression(&parquet.Snappy),
+		parquet.CreatedBy("orderservice", "", ""),
+	)}
+}
+
+func (w *parquetWriter) Write(ord *model.Order) error {
+	_, err := w.w.Write(Flatten(ord))
+	return err
+}
+
+func (w *parquetWriter) Close() error {
+	return w.w.Close()
+}
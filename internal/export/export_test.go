@@ -0,0 +1,159 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"orderservice/internal/model"
+)
+
+func testOrder(uid string, items int) *model.Order {
+	o := &model.Order{
+		OrderUID:        uid,
+		TrackNumber:     "TRACK",
+		CustomerID:      "customer",
+		DeliveryService: "cdek",
+		DateCreated:     time.Date(2025, 11, 1, 12, 0, 0, 0, time.FixedZone("MSK", 3*3600)),
+		Delivery:        model.Delivery{Name: "Test, Testov", City: "Moscow", Email: "t@example.com"},
+		Payment:         model.Payment{Transaction: uid, Currency: "RUB", Amount: 1500},
+	}
+	for i := 0; i < items; i++ {
+		o.Items = append(o.Items, model.Item{
+			ChrtID:     int64(i + 1),
+			RID:        uid + "-" + string(rune('a'+i)),
+			Brand:      "acme",
+			TotalPrice: 500,
+			Status:     model.ItemShipped,
+		})
+	}
+	return o
+}
+
+func writeAll(t *testing.T, f Format, orders ...*model.Order) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, f)
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	for _, o := range orders {
+		if err := w.Write(o); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVHeaderMatchesParquetSchema(t *testing.T) {
+	var names []string
+	for _, f := range parquet.SchemaOf(Row{}).Fields() {
+		names = append(names, f.Name())
+	}
+	if !reflect.DeepEqual(names, Columns) {
+		t.Fatalf("csv header and parquet columns differ:\n%v\n%v", Columns, names)
+	}
+}
+
+func TestCSV_FlattensItems(t *testing.T) {
+	data := writeAll(t, FormatCSV, testOrder("o1", 2), testOrder("o2", 0))
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected a header and 3 rows, got %d", len(records))
+	}
+	col := map[string]int{}
+	for i, name := range records[0] {
+		col[name] = i
+	}
+	first := records[1]
+	if first[col["order_uid"]] != "o1" || first[col["item_rid"]] != "o1-a" || first[col["item_status"]] != "shipped" {
+		t.Fatalf("unexpected first row %v", first)
+	}
+	if first[col["delivery_name"]] != "Test, Testov" {
+		t.Fatalf("expected the comma to be quoted, got %q", first[col["delivery_name"]])
+	}
+	if first[col["date_created"]] != "2025-11-01T09:00:00Z" {
+		t.Fatalf("expected the date in UTC, got %q", first[col["date_created"]])
+	}
+	if last := records[3]; last[col["order_uid"]] != "o2" || last[col["item_rid"]] != "" || last[col["item_price"]] != "" {
+		t.Fatalf("expected an order without items to have empty item columns, got %v", last)
+	}
+}
+
+func TestJSONL_OneOrderPerLine(t *testing.T) {
+	data := writeAll(t, FormatJSONL, testOrder("o1", 1), testOrder("o2", 2))
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	var uids []string
+	for sc.Scan() {
+		var o model.Order
+		if err := json.Unmarshal(sc.Bytes(), &o); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		uids = append(uids, o.OrderUID)
+	}
+	if !reflect.DeepEqual(uids, []string{"o1", "o2"}) {
+		t.Fatalf("expected o1, o2, got %v", uids)
+	}
+}
+
+func TestParquet_RoundTrip(t *testing.T) {
+	orders := []*model.Order{testOrder("o1", 2), testOrder("o2", 0)}
+	data := writeAll(t, FormatParquet, orders...)
+
+	rows, err := parquet.Read[Row](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("read parquet: %v", err)
+	}
+	want := append(Flatten(orders[0]), Flatten(orders[1])...)
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("expected %+v, got %+v", want, rows)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat("parquet"); err != nil || f != FormatParquet {
+		t.Fatalf("expected parquet, got %q, %v", f, err)
+	}
+	if _, err := ParseFormat("xlsx"); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
+
+func TestParseRecord_RoundTrip(t *testing.T) {
+	for _, want := range []*model.Order{testOrder("o1", 2), testOrder("o2", 0)} {
+		var rows []Row
+		for _, r := range Flatten(want) {
+			row, err := ParseRecord(r.record())
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			rows = append(rows, row)
+		}
+		got, err := Unflatten(rows)
+		if err != nil {
+			t.Fatalf("unflatten: %v", err)
+		}
+		if !reflect.DeepEqual(Flatten(got), Flatten(want)) {
+			t.Fatalf("expected %+v, got %+v", want, got)
+		}
+	}
+
+	rec := Flatten(testOrder("o1", 1))[0].record()
+	rec[22] = "1.5"
+	if _, err := ParseRecord(rec); err == nil || !strings.Contains(err.Error(), "payment_amount") {
+		t.Fatalf("expected a payment_amount error, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+type CheckFunc func(ctx context.Context) (map[string]any, error)
+
+type CheckResult struct {
+	Status    string         `json:"status"`
+	Error     string         `json:"error,omitempty"`
+	LatencyMS int64          `json:"latency_ms"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+type Report struct {
+	Ready        bool                   `json:"ready"`
+	ShuttingDown bool                   `json:"shutting_down,omitempty"`
+	Checks       map[string]CheckResult `json:"checks"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+type Checker struct {
+	mu           sync.RWMutex
+	checks       []check
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &Checker{timeout: timeout}
+}
+
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.mu.Lock()
+	c.checks = append(c.checks, check{name: name, fn: fn})
+	c.mu.Unlock()
+}
+
+// SetShuttingDown flips readiness to false regardless of dependency state so
+// that orchestrators stop routing traffic before the server drains.
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, ch := range checks {
+		wg.Add(1)
+		go func(i int, ch check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, ch.fn)
+		}(i, ch)
+	}
+	wg.Wait()
+
+	rep := Report{
+		Ready:        !c.shuttingDown.Load(),
+		ShuttingDown: c.shuttingDown.Load(),
+		Checks:       make(map[string]CheckResult, len(checks)),
+	}
+	for i, ch := range checks {
+		rep.Checks[ch.name] = results[i]
+		if results[i].Status != StatusUp {
+			rep.Ready = false
+		}
+	}
+	return rep
+}
+
+func (c *Checker) run(ctx context.Context, fn CheckFunc) CheckResult {
+	checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := fn(checkCtx)
+	res := CheckResult{
+		Status:    StatusUp,
+		LatencyMS: time.Since(start).Milliseconds(),
+		Details:   details,
+	}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// Flag is a one-way readiness gate, e.g. for cache warm-up completion.
+type Flag struct {
+	done atomic.Bool
+	name string
+}
+
+func NewFlag(name string) *Flag {
+	return &Flag{name: name}
+}
+
+func (f *Flag) Set() {
+	f.done.Store(true)
+}
+
+func (f *Flag) Check(ctx context.Context) (map[string]any, error) {
+	if !f.done.Load() {
+		return nil, fmt.Errorf("%s not completed", f.name)
+	}
+	return nil, nil
+}
@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestChecker_ReportsPerDependency(t *testing.T) {
+	c := NewChecker(0)
+	flag := NewFlag("cache warm-up")
+	c.Register("postgres", func(ctx context.Context) (map[string]any, error) { return nil, nil })
+	c.Register("kafka", func(ctx context.Context) (map[string]any, error) {
+		return map[string]any{"lag": 5}, errors.New("unreachable")
+	})
+	c.Register("cache", flag.Check)
+
+	rep := c.Check(context.Background())
+	if rep.Ready {
+		t.Fatalf("expected not ready")
+	}
+	if rep.Checks["postgres"].Status != StatusUp {
+		t.Fatalf("expected postgres up, got %+v", rep.Checks["postgres"])
+	}
+	if k := rep.Checks["kafka"]; k.Status != StatusDown || k.Error == "" || k.Details["lag"] != 5 {
+		t.Fatalf("unexpected kafka result: %+v", k)
+	}
+	if rep.Checks["cache"].Status != StatusDown {
+		t.Fatalf("expected cache down before warm-up")
+	}
+}
+
+func TestChecker_ShuttingDownIsNotReady(t *testing.T) {
+	c := NewChecker(0)
+	flag := NewFlag("cache warm-up")
+	flag.Set()
+	c.Register("cache", flag.Check)
+
+	if rep := c.Check(context.Background()); !rep.Ready {
+		t.Fatalf("expected ready, got %+v", rep)
+	}
+
+	c.SetShuttingDown()
+	if rep := c.Check(context.Background()); rep.Ready || !rep.ShuttingDown {
+		t.Fatalf("expected not ready while shutting down, got %+v", rep)
+	}
+}
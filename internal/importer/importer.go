@@ -0,0 +1,333 @@
+// Package importer loads orders from JSONL or CSV files in batches. Records
+// that cannot be read or fail validation are written to a reject file, and
+// progress is saved to a checkpoint after every batch so that an interrupted
+// import can be resumed.
+package importer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"orderservice/internal/export"
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+)
+
+// Store is implemented by repo.Importer.
+type Store interface {
+	ImportOrders(ctx context.Context, orders []*model.Order) (*repo.ImportResult, error)
+}
+
+type Options struct {
+	// Format is export.FormatJSONL or export.FormatCSV.
+	Format    export.Format
+	BatchSize int
+	// Rejects is the JSONL file rejected records are written to.
+	Rejects string
+	// Checkpoint is the file progress is saved to. A run that finds one for
+	// the same input resumes after the last imported batch, unless Restart
+	// is set. It is removed when the import completes.
+	Checkpoint string
+	Restart    bool
+}
+
+// Reject is a line of the reject file. Line is the first input line of the
+// record.
+type Reject struct {
+	Line     int    `json:"line"`
+	OrderUID string `json:"order_uid,omitempty"`
+	Error    string `json:"error"`
+}
+
+type Summary struct {
+	Imported int `json:"imported"`
+	Existing int `json:"existing"`
+	Rejected int `json:"rejected"`
+	// Lines is the last input line processed.
+	Lines   int  `json:"lines"`
+	Resumed bool `json:"-"`
+}
+
+type checkpoint struct {
+	Input     string `json:"input"`
+	InputSize int64  `json:"input_size"`
+	Offset    int64  `json:"offset"`
+	// RejectsSize is the size of the reject file at the checkpoint; a resumed
+	// run truncates it, dropping rejects of the batch that did not finish.
+	RejectsSize int64 `json:"rejects_size"`
+	Summary
+}
+
+// Run imports the orders in the file at input.
+func Run(ctx context.Context, store Store, input string, opts Options) (*Summary, error) {
+	if opts.Format != export.FormatJSONL && opts.Format != export.FormatCSV {
+		return nil, fmt.Errorf("importer: unsupported format %q, want jsonl or csv", opts.Format)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	if opts.Rejects == "" || opts.Checkpoint == "" {
+		return nil, errors.New("importer: reject and checkpoint files are required")
+	}
+
+	in, err := os.Open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	st, err := in.Stat()
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(input)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := checkpoint{Input: abs, InputSize: st.Size()}
+	if !opts.Restart {
+		saved, err := loadCheckpoint(opts.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		if saved != nil {
+			if saved.Input != cp.Input || saved.InputSize != cp.InputSize {
+				return nil, fmt.Errorf("importer: checkpoint %s is for %s (%d bytes), not this input; use -restart to start over",
+					opts.Checkpoint, saved.Input, saved.InputSize)
+			}
+			cp = *saved
+			cp.Resumed = true
+		}
+	}
+
+	var r reader
+	switch opts.Format {
+	case export.FormatJSONL:
+		if _, err := in.Seek(cp.Offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		r = newJSONLReader(in, cp.Offset, cp.Lines)
+	case export.FormatCSV:
+		headerEnd, err := readCSVHeader(in)
+		if err != nil {
+			return nil, fmt.Errorf("importer: %w", err)
+		}
+		if !cp.Resumed {
+			cp.Offset, cp.Lines = headerEnd, 1
+		}
+		if _, err := in.Seek(cp.Offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		r = newCSVReader(in, cp.Offset, cp.Lines)
+	}
+
+	rej, err := openRejects(opts.Rejects, cp.Resumed, cp.RejectsSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rej.close()
+
+	imp := &run{store: store, opts: opts, reader: r, rejects: rej, cp: cp}
+	if err := imp.loop(ctx); err != nil {
+		return &imp.cp.Summary, err
+	}
+	if err := rej.close(); err != nil {
+		return &imp.cp.Summary, err
+	}
+	if err := os.Remove(opts.Checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return &imp.cp.Summary, err
+	}
+	return &imp.cp.Summary, nil
+}
+
+type run struct {
+	store   Store
+	opts    Options
+	reader  reader
+	rejects *rejectFile
+	cp      checkpoint
+
+	batch []*model.Order
+	lines map[string]int
+	// end and endLine are where the last record read ends.
+	end     int64
+	endLine int
+}
+
+func (r *run) loop(ctx context.Context) error {
+	r.lines = map[string]int{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rec, err := r.reader.next()
+		if err == io.EOF {
+			return r.flush(ctx)
+		}
+		if err != nil {
+			return err
+		}
+		r.end, r.endLine = rec.end, rec.endLine
+
+		if rec.err == nil {
+			rec.err = rec.order.Validate()
+		}
+		if rec.err == nil {
+			rec.err = repo.CheckPlainDelivery(rec.order.Delivery)
+		}
+		switch {
+		case rec.err != nil:
+			err = r.reject(rec.line, rec.uid, rec.err)
+		case r.lines[rec.uid] != 0:
+			err = r.reject(rec.line, rec.uid, fmt.Errorf("duplicate of the order at line %d", r.lines[rec.uid]))
+		default:
+			r.batch = append(r.batch, rec.order)
+			r.lines[rec.uid] = rec.line
+		}
+		if err != nil {
+			return err
+		}
+		if len(r.batch) >= r.opts.BatchSize {
+			if err := r.flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// flush imports the batch and saves a checkpoint past it.
+func (r *run) flush(ctx context.Context) error {
+	if len(r.batch) > 0 {
+		res, err := r.store.ImportOrders(ctx, r.batch)
+		if err != nil {
+			return fmt.Errorf("importer: batch ending at line %d: %w", r.endLine, err)
+		}
+		r.cp.Imported += len(res.Inserted)
+		r.cp.Existing += len(res.Existing)
+		for _, id := range res.Erased {
+			if err := r.reject(r.lines[id], id, repo.ErrCustomerErased); err != nil {
+				return err
+			}
+		}
+	}
+	r.batch = r.batch[:0]
+	clear(r.lines)
+
+	if r.end == 0 {
+		return nil
+	}
+	size, err := r.rejects.sync()
+	if err != nil {
+		return err
+	}
+	r.cp.Offset, r.cp.Lines, r.cp.RejectsSize = r.end, r.endLine, size
+	return saveCheckpoint(r.opts.Checkpoint, &r.cp)
+}
+
+func (r *run) reject(line int, uid string, err error) error {
+	r.cp.Rejected++
+	return r.rejects.write(Reject{Line: line, OrderUID: uid, Error: err.Error()})
+}
+
+type rejectFile struct {
+	f   *os.File
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func openRejects(path string, resume bool, size int64) (*rejectFile, error) {
+	flags := os.O_RDWR | os.O_CREATE
+	if !resume {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if resume {
+		if err := f.Truncate(size); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return nil, err
+	}
+	buf := bufio.NewWriter(f)
+	return &rejectFile{f: f, buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+func (w *rejectFile) write(rej Reject) error {
+	return w.enc.Encode(rej)
+}
+
+// sync makes the rejects durable and returns the file size.
+func (w *rejectFile) sync() (int64, error) {
+	if err := w.buf.Flush(); err != nil {
+		return 0, err
+	}
+	if err := w.f.Sync(); err != nil {
+		return 0, err
+	}
+	return w.f.Seek(0, io.SeekCurrent)
+}
+
+func (w *rejectFile) close() error {
+	if w.f == nil {
+		return nil
+	}
+	err := w.buf.Flush()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	w.f = nil
+	return err
+}
+
+func loadCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("importer: checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// saveCheckpoint replaces the file atomically, so a crash leaves either the
+// previous checkpoint or the new one.
+func saveCheckpoint(path string, cp *checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,305 @@
+package importer
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"orderservice/internal/export"
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+	"orderservice/pkg/generator"
+)
+
+type fakeStore struct {
+	stored map[string]*model.Order
+	erased map[string]bool
+	calls  int
+	failAt int
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{stored: map[string]*model.Order{}, erased: map[string]bool{}}
+}
+
+func (s *fakeStore) ImportOrders(ctx context.Context, orders []*model.Order) (*repo.ImportResult, error) {
+	s.calls++
+	if s.calls == s.failAt {
+		return nil, errors.New("connection reset")
+	}
+	res := &repo.ImportResult{}
+	for _, o := range orders {
+		switch {
+		case s.erased[o.CustomerID]:
+			res.Erased = append(res.Erased, o.OrderUID)
+		case s.stored[o.OrderUID] != nil:
+			res.Existing = append(res.Existing, o.OrderUID)
+		default:
+			s.stored[o.OrderUID] = o
+			res.Inserted = append(res.Inserted, o.OrderUID)
+		}
+	}
+	return res, nil
+}
+
+func generate(t *testing.T, n int) []*model.Order {
+	t.Helper()
+	opts := generator.DefaultOptions()
+	opts.Seed = 7
+	gen, err := generator.New(opts)
+	if err != nil {
+		t.Fatalf("generator: %v", err)
+	}
+	orders := make([]*model.Order, n)
+	for i := range orders {
+		orders[i] = gen.Order()
+	}
+	return orders
+}
+
+func writeInput(t *testing.T, dir, name string, f export.Format, orders []*model.Order) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	w, err := export.NewWriter(file, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range orders {
+		if err := w.Write(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readRejects(t *testing.T, path string) []Reject {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var rejects []Reject
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r Reject
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("reject %q: %v", sc.Text(), err)
+		}
+		rejects = append(rejects, r)
+	}
+	return rejects
+}
+
+func testOptions(dir string, f export.Format) Options {
+	return Options{
+		Format:     f,
+		BatchSize:  2,
+		Rejects:    filepath.Join(dir, "rejects.jsonl"),
+		Checkpoint: filepath.Join(dir, "import.checkpoint"),
+	}
+}
+
+func TestRun_JSONLRejects(t *testing.T) {
+	dir := t.TempDir()
+	orders := generate(t, 3)
+	invalid := *orders[2]
+	invalid.TrackNumber = ""
+
+	var lines []string
+	for _, o := range []*model.Order{orders[0], orders[1]} {
+		b, _ := json.Marshal(o)
+		lines = append(lines, string(b))
+	}
+	b, _ := json.Marshal(&invalid)
+	dup, _ := json.Marshal(orders[0])
+	lines = append(lines, "", "{not json", string(b), string(dup))
+	path := filepath.Join(dir, "orders.jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := newFakeStore()
+	opts := testOptions(dir, export.FormatJSONL)
+	sum, err := Run(context.Background(), store, path, opts)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if sum.Imported != 2 || sum.Rejected != 2 || sum.Existing != 1 || sum.Lines != 6 {
+		t.Fatalf("unexpected summary %+v", sum)
+	}
+
+	rejects := readRejects(t, opts.Rejects)
+	if len(rejects) != 2 || rejects[0].Line != 4 || rejects[1].Line != 5 {
+		t.Fatalf("expected rejects for lines 4 and 5, got %+v", rejects)
+	}
+	if rejects[1].OrderUID != invalid.OrderUID || !strings.Contains(rejects[1].Error, "track_number") {
+		t.Fatalf("expected the validation error of %s, got %+v", invalid.OrderUID, rejects[1])
+	}
+	if _, err := os.Stat(opts.Checkpoint); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the checkpoint to be removed, got %v", err)
+	}
+}
+
+func TestRun_CSVRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	orders := generate(t, 5)
+	path := writeInput(t, dir, "orders.csv", export.FormatCSV, orders)
+
+	store := newFakeStore()
+	sum, err := Run(context.Background(), store, path, testOptions(dir, export.FormatCSV))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if sum.Imported != len(orders) || sum.Rejected != 0 {
+		t.Fatalf("unexpected summary %+v", sum)
+	}
+	for _, want := range orders {
+		got := store.stored[want.OrderUID]
+		if got == nil {
+			t.Fatalf("order %s was not imported", want.OrderUID)
+		}
+		if !reflect.DeepEqual(export.Flatten(got), export.Flatten(want)) {
+			t.Fatalf("order %s differs:\n%+v\n%+v", want.OrderUID, got, want)
+		}
+	}
+}
+
+func TestRun_CSVRejectsBadRows(t *testing.T) {
+	dir := t.TempDir()
+	orders := generate(t, 2)
+	path := writeInput(t, dir, "orders.csv", export.FormatCSV, orders)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(f).ReadAll()
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A bad amount in the second order, and a short row between the orders.
+	rows[len(rows)-1][slices.Index(export.Columns, "payment_amount")] = "x"
+	n := len(orders[0].Items) + 1
+	rows = slices.Insert(rows, n, []string{"a", "b", "c"})
+
+	f, err = os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := errors.Join(csv.NewWriter(f).WriteAll(rows), f.Close()); err != nil {
+		t.Fatal(err)
+	}
+
+	store := newFakeStore()
+	opts := testOptions(dir, export.FormatCSV)
+	sum, err := Run(context.Background(), store, path, opts)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if sum.Imported != 1 || sum.Rejected != 2 {
+		t.Fatalf("unexpected summary %+v", sum)
+	}
+	rejects := readRejects(t, opts.Rejects)
+	short := len(orders[0].Items) + 2
+	if len(rejects) != 2 || rejects[0].Line != short || rejects[1].OrderUID != orders[1].OrderUID {
+		t.Fatalf("expected the short row at line %d and order %s, got %+v", short, orders[1].OrderUID, rejects)
+	}
+}
+
+func TestRun_ResumesFromCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	orders := generate(t, 7)
+	orders[1].TrackNumber = ""
+	orders[5].CustomerID = "erased-customer"
+	path := writeInput(t, dir, "orders.jsonl", export.FormatJSONL, orders)
+	opts := testOptions(dir, export.FormatJSONL)
+
+	store := newFakeStore()
+	store.erased["erased-customer"] = true
+	store.failAt = 2
+	if _, err := Run(context.Background(), store, path, opts); err == nil {
+		t.Fatal("expected the second batch to fail")
+	}
+	cp, err := loadCheckpoint(opts.Checkpoint)
+	if err != nil || cp == nil {
+		t.Fatalf("expected a checkpoint, got %v, %v", cp, err)
+	}
+	// The first batch is orders 0 and 2, order 1 being rejected.
+	if cp.Lines != 3 || cp.Imported != 2 || cp.Rejected != 1 {
+		t.Fatalf("unexpected checkpoint %+v", cp)
+	}
+
+	store.failAt = 0
+	sum, err := Run(context.Background(), store, path, opts)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if !sum.Resumed || sum.Imported != 5 || sum.Rejected != 2 || sum.Existing != 0 {
+		t.Fatalf("unexpected summary %+v", sum)
+	}
+	rejects := readRejects(t, opts.Rejects)
+	if len(rejects) != 2 || rejects[0].Line != 2 || rejects[1].Line != 6 || rejects[1].Error != repo.ErrCustomerErased.Error() {
+		t.Fatalf("unexpected rejects %+v", rejects)
+	}
+
+	// A finished import starts over and finds everything stored.
+	sum, err = Run(context.Background(), store, path, opts)
+	if err != nil || sum.Resumed || sum.Existing != 5 {
+		t.Fatalf("expected a fresh run to skip the stored orders, got %+v, %v", sum, err)
+	}
+}
+
+func TestRun_CheckpointForOtherInput(t *testing.T) {
+	dir := t.TempDir()
+	path := writeInput(t, dir, "orders.jsonl", export.FormatJSONL, generate(t, 1))
+	opts := testOptions(dir, export.FormatJSONL)
+	if err := saveCheckpoint(opts.Checkpoint, &checkpoint{Input: "/elsewhere.jsonl", Offset: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Run(context.Background(), newFakeStore(), path, opts); err == nil {
+		t.Fatal("expected a checkpoint of another input to be refused")
+	}
+	opts.Restart = true
+	if sum, err := Run(context.Background(), newFakeStore(), path, opts); err != nil || sum.Imported != 1 {
+		t.Fatalf("expected a restart to import the order, got %+v, %v", sum, err)
+	}
+}
+
+func TestRun_RejectsReservedPrefix(t *testing.T) {
+	dir := t.TempDir()
+	orders := generate(t, 2)
+	orders[1].Delivery.Region = "enc:x"
+	path := writeInput(t, dir, "orders.jsonl", export.FormatJSONL, orders)
+
+	store := newFakeStore()
+	opts := testOptions(dir, export.FormatJSONL)
+	sum, err := Run(context.Background(), store, path, opts)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if sum.Imported != 1 || sum.Rejected != 1 {
+		t.Fatalf("unexpected summary %+v", sum)
+	}
+	rejects := readRejects(t, opts.Rejects)
+	if len(rejects) != 1 || rejects[0].OrderUID != orders[1].OrderUID || !strings.Contains(rejects[0].Error, "region") {
+		t.Fatalf("expected order %s to be rejected for its region, got %+v", orders[1].OrderUID, rejects)
+	}
+}
@@ -0,0 +1,180 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+
+	"orderservice/internal/codec"
+	"orderservice/internal/export"
+	"orderservice/internal/model"
+)
+
+// record is one order read from the input, or the error that kept it from
+// being read.
+type record struct {
+	line    int
+	endLine int
+	// end is the input offset just past the record, where reading resumes.
+	end   int64
+	uid   string
+	order *model.Order
+	err   error
+}
+
+type reader interface {
+	// next returns io.EOF at the end of the input.
+	next() (record, error)
+}
+
+// jsonlReader reads an order per line. Blank lines are skipped.
+type jsonlReader struct {
+	r      *bufio.Reader
+	codec  codec.Codec
+	offset int64
+	line   int
+}
+
+func newJSONLReader(r io.Reader, offset int64, line int) *jsonlReader {
+	return &jsonlReader{r: bufio.NewReaderSize(r, 1<<16), codec: codec.NewJSON(), offset: offset, line: line}
+}
+
+func (r *jsonlReader) next() (record, error) {
+	for {
+		b, err := r.r.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(b) == 0) {
+			return record{}, err
+		}
+		r.offset += int64(len(b))
+		r.line++
+		if len(bytes.TrimSpace(b)) == 0 {
+			continue
+		}
+		rec := record{line: r.line, endLine: r.line, end: r.offset}
+		rec.order, rec.err = r.codec.Decode(context.Background(), b, nil)
+		if rec.order != nil {
+			rec.uid = rec.order.OrderUID
+		}
+		return rec, nil
+	}
+}
+
+// csvReader reads the flat layout of export.Columns, a row per item. The rows
+// of an order must be consecutive, as the export writes them.
+type csvReader struct {
+	r    *csv.Reader
+	base int64
+	line int
+
+	pend   *pending
+	queued *record
+}
+
+type pending struct {
+	uid     string
+	line    int
+	endLine int
+	end     int64
+	rows    []export.Row
+	err     error
+}
+
+// readCSVHeader checks the header line and returns the offset past it.
+func readCSVHeader(r io.Reader) (int64, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return 0, fmt.Errorf("csv header: %w", err)
+	}
+	if len(header) != len(export.Columns) {
+		return 0, fmt.Errorf("csv header: want the %d export columns, got %d", len(export.Columns), len(header))
+	}
+	for i, name := range export.Columns {
+		if header[i] != name {
+			return 0, fmt.Errorf("csv header: column %d is %q, want %q", i+1, header[i], name)
+		}
+	}
+	return cr.InputOffset(), nil
+}
+
+// newCSVReader reads rows from r, which starts at offset and after line of
+// the input.
+func newCSVReader(r io.Reader, offset int64, line int) *csvReader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(export.Columns)
+	return &csvReader{r: cr, base: offset, line: line}
+}
+
+func (r *csvReader) next() (record, error) {
+	for {
+		if r.queued != nil {
+			rec := *r.queued
+			r.queued = nil
+			return rec, nil
+		}
+
+		fields, err := r.r.Read()
+		if err == io.EOF {
+			if r.pend != nil {
+				return r.flush(), nil
+			}
+			return record{}, io.EOF
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			rec := record{
+				line:    r.line + perr.StartLine,
+				endLine: r.line + perr.Line,
+				end:     r.base + r.r.InputOffset(),
+				err:     perr.Err,
+			}
+			if len(fields) > 0 {
+				rec.uid = fields[0]
+			}
+			if r.pend != nil {
+				out := r.flush()
+				r.queued = &rec
+				return out, nil
+			}
+			return rec, nil
+		}
+		if err != nil {
+			return record{}, err
+		}
+
+		start, _ := r.r.FieldPos(0)
+		last, _ := r.r.FieldPos(len(fields) - 1)
+		var out *record
+		if r.pend != nil && r.pend.uid != fields[0] {
+			rec := r.flush()
+			out = &rec
+		}
+		if r.pend == nil {
+			r.pend = &pending{uid: fields[0], line: r.line + start}
+		}
+		row, err := export.ParseRecord(fields)
+		if err != nil && r.pend.err == nil {
+			r.pend.err = fmt.Errorf("line %d: %w", r.line+start, err)
+		}
+		r.pend.rows = append(r.pend.rows, row)
+		r.pend.endLine = r.line + last
+		r.pend.end = r.base + r.r.InputOffset()
+		if out != nil {
+			return *out, nil
+		}
+	}
+}
+
+func (r *csvReader) flush() record {
+	p := r.pend
+	r.pend = nil
+	rec := record{line: p.line, endLine: p.endLine, end: p.end, uid: p.uid, err: p.err}
+	if rec.err == nil {
+		rec.order, rec.err = export.Unflatten(p.rows)
+	}
+	return rec
+}
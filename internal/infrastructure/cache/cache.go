@@ -11,6 +11,7 @@ import (
 type Cache interface {
 	Set(order *model.Order)
 	Get(orderUID string) (*model.Order, bool)
+	Delete(orderUIDs ...string)
 	SetupCache(orders []*model.Order)
 	Close()
 }
@@ -75,9 +76,22 @@ func (c *cache) Get(orderUID string) (*model.Order, bool) {
 	return e.order, true
 }
 
+func (c *cache) Delete(orderUIDs ...string) {
+	c.mu.Lock()
+	for _, id := range orderUIDs {
+		delete(c.data, id)
+	}
+	c.mu.Unlock()
+}
+
+// SetupCache fills the cache with preloaded orders. Orders already cached are
+// kept, as they were set after the preload read them.
 func (c *cache) SetupCache(orders []*model.Order) {
 	c.mu.Lock()
 	for _, o := range orders {
+		if _, ok := c.data[o.OrderUID]; ok {
+			continue
+		}
 		var exp time.Time
 		if c.ttl > 0 {
 			exp = time.Now().Add(c.ttl)
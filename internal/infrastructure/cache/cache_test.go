@@ -61,3 +61,19 @@ func TestMockCache_CloseDoesNotPanic(t *testing.T) {
 	// calling Close should satisfy expectation and not panic
 	mc.Close()
 }
+
+func TestCache_SetupCacheKeepsNewerEntries(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	newer := &model.Order{OrderUID: "a", TrackNumber: "new"}
+	c.Set(newer)
+	c.SetupCache([]*model.Order{{OrderUID: "a", TrackNumber: "old"}, {OrderUID: "b"}})
+
+	if got, _ := c.Get("a"); got != newer {
+		t.Fatalf("expected the preload to keep the newer order, got %+v", got)
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Fatal("expected the preloaded order to be cached")
+	}
+}
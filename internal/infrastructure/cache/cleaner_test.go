@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"orderservice/internal/model"
+)
+
+func (c *cache) size() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.data)
+}
+
+func TestCache_GetMissesAfterTTL(t *testing.T) {
+	c := NewCacheWithTTL(30 * time.Millisecond)
+	defer c.Close()
+
+	c.Set(&model.Order{OrderUID: "o1"})
+	if _, ok := c.Get("o1"); !ok {
+		t.Fatal("expected hit before TTL")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := c.Get("o1"); ok {
+		t.Fatal("expected miss after TTL")
+	}
+}
+
+func TestCache_SetRefreshesTTL(t *testing.T) {
+	c := NewCacheWithTTL(60 * time.Millisecond)
+	defer c.Close()
+
+	c.Set(&model.Order{OrderUID: "o1"})
+	time.Sleep(40 * time.Millisecond)
+	c.Set(&model.Order{OrderUID: "o1", TrackNumber: "v2"})
+	time.Sleep(40 * time.Millisecond)
+
+	o, ok := c.Get("o1")
+	if !ok || o.TrackNumber != "v2" {
+		t.Fatalf("expected refreshed entry, got %+v ok=%v", o, ok)
+	}
+}
+
+func TestCleaner_EvictsExpiredEntriesWithoutReads(t *testing.T) {
+	c := NewCacheWithTTL(20 * time.Millisecond).(*cache)
+	defer c.Close()
+
+	c.SetupCache([]*model.Order{{OrderUID: "a"}, {OrderUID: "b"}, {OrderUID: "c"}})
+	if n := c.size(); n != 3 {
+		t.Fatalf("expected 3 entries, got %d", n)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for c.size() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("cleaner left %d expired entries", c.size())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCache_ZeroTTLNeverExpires(t *testing.T) {
+	c := NewCache().(*cache)
+	defer c.Close()
+
+	c.Set(&model.Order{OrderUID: "o1"})
+	if e := c.data["o1"]; !e.expiresAt.IsZero() {
+		t.Fatalf("expected no expiry without TTL, got %v", e.expiresAt)
+	}
+	if _, ok := c.Get("o1"); !ok {
+		t.Fatal("expected hit")
+	}
+}
+
+func TestCache_CloseStopsCleaner(t *testing.T) {
+	c := NewCacheWithTTL(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not wait for the cleaner to stop")
+	}
+}
+
+func TestCache_Delete(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	c.SetupCache([]*model.Order{{OrderUID: "a"}, {OrderUID: "b"}, {OrderUID: "c"}})
+	c.Delete("a", "c", "missing")
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected a to be deleted")
+	}
+	if _, ok := c.Get("c"); ok {
+		t.Fatal("expected c to be deleted")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Fatal("expected b to stay cached")
+	}
+}
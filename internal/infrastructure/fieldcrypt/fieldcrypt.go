@@ -0,0 +1,238 @@
+// Package fieldcrypt implements envelope encryption of single column values.
+//
+// Every row gets its own random data key. Values are sealed with AES-256-GCM
+// under that key, and the data key itself is stored next to them, sealed
+// under a master key that is named by a key ID. Rotating the master key only
+// re-wraps data keys; the values are left untouched.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const (
+	keySize = 32
+	// prefix marks sealed values, so rows written before encryption was
+	// enabled and erased placeholders are read as they are.
+	prefix = "enc:"
+)
+
+var ErrUnknownKey = errors.New("fieldcrypt: unknown master key")
+
+type Cipher struct {
+	masters  map[string]cipher.AEAD
+	activeID string
+	indexKey []byte
+}
+
+// New returns a Cipher that wraps new data keys with the master key activeID.
+// indexKey is the HMAC key of blind indexes; it must not change, or existing
+// indexes stop matching.
+func New(masterKeys map[string][]byte, activeID string, indexKey []byte) (*Cipher, error) {
+	if _, ok := masterKeys[activeID]; !ok {
+		return nil, fmt.Errorf("fieldcrypt: active key %q is not loaded", activeID)
+	}
+	if len(indexKey) < keySize {
+		return nil, fmt.Errorf("fieldcrypt: index key must be at least %d bytes", keySize)
+	}
+	c := &Cipher{masters: map[string]cipher.AEAD{}, activeID: activeID, indexKey: indexKey}
+	for id, key := range masterKeys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: master key %q: %w", id, err)
+		}
+		c.masters[id] = aead
+	}
+	return c, nil
+}
+
+func (c *Cipher) ActiveKeyID() string {
+	return c.activeID
+}
+
+// NewDataKey returns a fresh data key and its copy wrapped with the active
+// master key.
+func (c *Cipher) NewDataKey() (dek, wrapped []byte, err error) {
+	dek = make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, nil, err
+	}
+	wrapped, err = seal(c.masters[c.activeID], dek, []byte(c.activeID))
+	if err != nil {
+		return nil, nil, err
+	}
+	return dek, wrapped, nil
+}
+
+func (c *Cipher) UnwrapDataKey(keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := c.masters[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	dek, err := open(aead, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("fieldcrypt: unwrap data key: %w", err)
+	}
+	return dek, nil
+}
+
+// Rewrap re-encrypts a data key wrapped with keyID under the active master key.
+func (c *Cipher) Rewrap(keyID string, wrapped []byte) ([]byte, error) {
+	dek, err := c.UnwrapDataKey(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return seal(c.masters[c.activeID], dek, []byte(c.activeID))
+}
+
+// BlindIndex returns a keyed hash of the normalized value, so equal emails or
+// phone numbers can be looked up without decrypting them.
+func (c *Cipher) BlindIndex(field, value string) []byte {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(Normalize(field, value)))
+	return mac.Sum(nil)
+}
+
+// Normalize is what blind indexes and plaintext lookups compare: emails are
+// case-insensitive, phone numbers are reduced to their digits.
+func Normalize(field, value string) string {
+	switch field {
+	case "email":
+		return strings.ToLower(strings.TrimSpace(value))
+	case "phone":
+		return strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, value)
+	default:
+		return value
+	}
+}
+
+// Encrypt seals value with dek. aad binds the result to its row and column,
+// so sealed values cannot be swapped between them.
+func Encrypt(dek []byte, value, aad string) (string, error) {
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(aead, []byte(value), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt; any other value is returned as is.
+func Decrypt(dek []byte, value, aad string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(value[len(prefix):])
+	if err != nil {
+		return "", fmt.Errorf("fieldcrypt: decode: %w", err)
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(aead, sealed, []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("fieldcrypt: decrypt: %w", err)
+	}
+	return string(plain), nil
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// ParseKeys parses "id:base64key" pairs separated by commas or newlines.
+// Blank lines and lines starting with # are skipped.
+func ParseKeys(spec string) (keys map[string][]byte, order []string, err error) {
+	keys = map[string][]byte{}
+	for _, line := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, enc, ok := strings.Cut(line, ":")
+		if !ok || id == "" {
+			return nil, nil, fmt.Errorf("fieldcrypt: want id:base64key, got %q", line)
+		}
+		key, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fieldcrypt: key %q: %w", id, err)
+		}
+		if len(key) != keySize {
+			return nil, nil, fmt.Errorf("fieldcrypt: key %q must be %d bytes, got %d", id, keySize, len(key))
+		}
+		if _, dup := keys[id]; dup {
+			return nil, nil, fmt.Errorf("fieldcrypt: duplicate key %q", id)
+		}
+		keys[id] = key
+		order = append(order, id)
+	}
+	return keys, order, nil
+}
+
+// LoadKeys merges the keys of spec and of the file at path (either may be
+// empty). The active key defaults to the last one listed.
+func LoadKeys(spec, path, activeID string) (keys map[string][]byte, active string, err error) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("fieldcrypt: read keyfile: %w", err)
+		}
+		spec = string(data) + "\n" + spec
+	}
+	keys, order, err := ParseKeys(spec)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(keys) == 0 {
+		return nil, "", nil
+	}
+	if activeID == "" {
+		activeID = order[len(order)-1]
+	}
+	return keys, activeID, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plain, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ct := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ct, aad)
+}
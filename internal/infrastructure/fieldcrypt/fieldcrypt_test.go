@@ -0,0 +1,146 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, keySize)
+}
+
+func newTestCipher(t *testing.T, active string) *Cipher {
+	t.Helper()
+	c, err := New(map[string][]byte{"k1": testKey(1), "k2": testKey(2)}, active, testKey(9))
+	if err != nil {
+		t.Fatalf("new cipher: %v", err)
+	}
+	return c
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	c := newTestCipher(t, "k1")
+	dek, wrapped, err := c.NewDataKey()
+	if err != nil {
+		t.Fatalf("data key: %v", err)
+	}
+
+	sealed, err := Encrypt(dek, "+7 900 000-00-00", "uid/phone")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !IsEncrypted(sealed) {
+		t.Fatalf("expected %q to carry the prefix", sealed)
+	}
+
+	unwrapped, err := c.UnwrapDataKey("k1", wrapped)
+	if err != nil {
+		t.Fatalf("unwrap: %v", err)
+	}
+	got, err := Decrypt(unwrapped, sealed, "uid/phone")
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if got != "+7 900 000-00-00" {
+		t.Fatalf("got %q", got)
+	}
+
+	if _, err := Decrypt(unwrapped, sealed, "other/phone"); err == nil {
+		t.Fatal("expected a value moved to another row to fail")
+	}
+	if plain, err := Decrypt(unwrapped, "erased", "uid/phone"); err != nil || plain != "erased" {
+		t.Fatalf("expected plain values to pass through, got %q, %v", plain, err)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	old := newTestCipher(t, "k1")
+	dek, wrapped, err := old.NewDataKey()
+	if err != nil {
+		t.Fatalf("data key: %v", err)
+	}
+
+	c := newTestCipher(t, "k2")
+	rewrapped, err := c.Rewrap("k1", wrapped)
+	if err != nil {
+		t.Fatalf("rewrap: %v", err)
+	}
+	got, err := c.UnwrapDataKey("k2", rewrapped)
+	if err != nil {
+		t.Fatalf("unwrap: %v", err)
+	}
+	if !bytes.Equal(got, dek) {
+		t.Fatal("rewrapped data key differs")
+	}
+
+	if _, err := c.UnwrapDataKey("k1", rewrapped); err == nil {
+		t.Fatal("expected the key id to be bound to the wrapped key")
+	}
+	if _, err := c.UnwrapDataKey("k3", wrapped); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	c := newTestCipher(t, "k1")
+	if !bytes.Equal(c.BlindIndex("email", " Test@Example.com"), c.BlindIndex("email", "test@example.com")) {
+		t.Fatal("expected emails to match case-insensitively")
+	}
+	if !bytes.Equal(c.BlindIndex("phone", "+7 (900) 000-00-00"), c.BlindIndex("phone", "79000000000")) {
+		t.Fatal("expected phones to match by digits")
+	}
+	if bytes.Equal(c.BlindIndex("email", "79000000000"), c.BlindIndex("phone", "79000000000")) {
+		t.Fatal("expected indexes of different fields to differ")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(testKey(1))
+	k2 := base64.StdEncoding.EncodeToString(testKey(2))
+
+	keys, order, err := ParseKeys("# old\nk1:" + k1 + "\n\nk2:" + k2)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(keys) != 2 || len(order) != 2 || order[1] != "k2" {
+		t.Fatalf("unexpected keys %v", order)
+	}
+
+	for _, spec := range []string{
+		"k1",
+		":" + k1,
+		"k1:not-base64!",
+		"k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"k1:" + k1 + ",k1:" + k2,
+	} {
+		if _, _, err := ParseKeys(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(testKey(1))
+	k2 := base64.StdEncoding.EncodeToString(testKey(2))
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte("k1:"+k1+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, active, err := LoadKeys("k2:"+k2, path, "")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(keys) != 2 || active != "k2" {
+		t.Fatalf("expected 2 keys with k2 active, got %d, %q", len(keys), active)
+	}
+
+	keys, _, err = LoadKeys("", "", "")
+	if err != nil || keys != nil {
+		t.Fatalf("expected no keys, got %v, %v", keys, err)
+	}
+}
@@ -0,0 +1,120 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"orderservice/migrations"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// Migrator applies the embedded migrations. Every command holds a Postgres
+// advisory lock, so replicas migrating on start do not race each other.
+type Migrator struct {
+	p *goose.Provider
+}
+
+// New uses db for migrations; closing the Migrator closes db.
+func New(db *sql.DB) (*Migrator, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	p, err := goose.NewProvider(goose.DialectPostgres, db, migrations.FS,
+		goose.WithSessionLocker(locker),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	return &Migrator{p: p}, nil
+}
+
+// Open connects to dsn for the duration of the migration.
+func Open(dsn string) (*Migrator, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: open: %w", err)
+	}
+	m, err := New(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// FromPool shares pool with the service; closing the Migrator leaves pool open.
+func FromPool(pool *pgxpool.Pool) (*Migrator, error) {
+	return New(stdlib.OpenDBFromPool(pool))
+}
+
+func (m *Migrator) Up(ctx context.Context, w io.Writer) error {
+	results, err := m.p.Up(ctx)
+	for _, r := range results {
+		fmt.Fprintf(w, "applied %s (%s)\n", filepath.Base(r.Source.Path), r.Duration)
+	}
+	if err != nil {
+		return fmt.Errorf("migrate up: %w", err)
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(w, "no pending migrations")
+	}
+	return nil
+}
+
+// Down rolls back the latest applied migration.
+func (m *Migrator) Down(ctx context.Context, w io.Writer) error {
+	r, err := m.p.Down(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate down: %w", err)
+	}
+	fmt.Fprintf(w, "rolled back %s (%s)\n", filepath.Base(r.Source.Path), r.Duration)
+	return nil
+}
+
+// Redo rolls back the latest applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context, w io.Writer) error {
+	r, err := m.p.Down(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate redo: %w", err)
+	}
+	fmt.Fprintf(w, "rolled back %s (%s)\n", filepath.Base(r.Source.Path), r.Duration)
+
+	r, err = m.p.ApplyVersion(ctx, r.Source.Version, true)
+	if err != nil {
+		return fmt.Errorf("migrate redo: %w", err)
+	}
+	fmt.Fprintf(w, "applied %s (%s)\n", filepath.Base(r.Source.Path), r.Duration)
+	return nil
+}
+
+func (m *Migrator) Status(ctx context.Context, w io.Writer) error {
+	statuses, err := m.p.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate status: %w", err)
+	}
+	for _, s := range statuses {
+		appliedAt := "-"
+		if s.State == goose.StateApplied {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%-8s %-20s %s\n", s.State, appliedAt, filepath.Base(s.Source.Path))
+	}
+	return nil
+}
+
+// Version returns the latest applied migration version, 0 if none.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	return m.p.GetDBVersion(ctx)
+}
+
+func (m *Migrator) Close() error {
+	return m.p.Close()
+}
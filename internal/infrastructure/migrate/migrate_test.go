@@ -0,0 +1,43 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"orderservice/migrations"
+)
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	db, err := sql.Open("pgx", "postgres://localhost/unused")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	m, err := New(db)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer m.Close()
+
+	files, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	sources := m.p.ListSources()
+	if len(sources) != len(files) || len(files) == 0 {
+		t.Fatalf("expected %d embedded migrations, got %d", len(files), len(sources))
+	}
+	for i := 1; i < len(sources); i++ {
+		if sources[i].Version <= sources[i-1].Version {
+			t.Fatalf("migrations out of order: %d after %d", sources[i].Version, sources[i-1].Version)
+		}
+	}
+
+	for _, f := range files {
+		data, _ := fs.ReadFile(migrations.FS, f)
+		if !strings.Contains(string(data), "-- +goose Down") {
+			t.Fatalf("%s has no Down section", f)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"orderservice/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	EventOrderCreated = "order.created"
+	EventOrderUpdated = "order.updated"
+	EventOrderDeleted = "order.deleted"
+)
+
+type Event struct {
+	EventID    string     `json:"event_id"`
+	EventType  string     `json:"event_type"`
+	OrderUID   string     `json:"order_uid"`
+	OccurredAt time.Time  `json:"occurred_at"`
+	Order      *EventBody `json:"order,omitempty"`
+}
+
+// EventBody is an order without its delivery. The delivery holds personal
+// data, which is encrypted in the delivery tables and must not be copied
+// into the outbox in clear text.
+type EventBody struct {
+	*model.Order
+	// Delivery shadows the embedded field, so that it is never marshaled.
+	Delivery *struct{} `json:"delivery,omitempty"`
+}
+
+// Enqueue writes an event row using tx, so the event becomes visible to the
+// relay only if the surrounding transaction commits.
+func Enqueue(ctx context.Context, tx pgx.Tx, eventType string, ord *model.Order) error {
+	return enqueue(ctx, tx, eventType, ord.OrderUID, ord)
+}
+
+// EnqueueDeleted writes an order.deleted event, which carries no order body.
+func EnqueueDeleted(ctx context.Context, tx pgx.Tx, orderUID string) error {
+	return enqueue(ctx, tx, EventOrderDeleted, orderUID, nil)
+}
+
+func enqueue(ctx context.Context, tx pgx.Tx, eventType, orderUID string, ord *model.Order) error {
+	ev := Event{
+		EventID:    uuid.NewString(),
+		EventType:  eventType,
+		OrderUID:   orderUID,
+		OccurredAt: time.Now().UTC(),
+	}
+	if ord != nil {
+		ev.Order = &EventBody{Order: ord}
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("outbox: marshal event: %w", err)
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO outbox (event_id, aggregate_id, event_type, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		ev.EventID, ev.OrderUID, ev.EventType, payload, ev.OccurredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("outbox: insert event: %w", err)
+	}
+	return nil
+}
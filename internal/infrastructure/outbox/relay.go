@@ -0,0 +1,194 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/segmentio/kafka-go"
+)
+
+const cleanupInterval = time.Minute
+
+type Publisher interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Relay publishes undelivered outbox rows and marks them delivered only after
+// the broker acknowledged them, which gives at-least-once delivery.
+type Relay struct {
+	db        *pgxpool.Pool
+	pub       Publisher
+	batchSize int
+	interval  time.Duration
+	retention time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewRelay(db *pgxpool.Pool, pub Publisher, batchSize int, interval, retention time.Duration) *Relay {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &Relay{
+		db:        db,
+		pub:       pub,
+		batchSize: batchSize,
+		interval:  interval,
+		retention: retention,
+	}
+}
+
+func (r *Relay) Start(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	r.cancel = cancel
+	r.done = make(chan struct{})
+
+	go r.run(runCtx)
+	return nil
+}
+
+func (r *Relay) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("outbox: relay did not stop: %w", ctx.Err())
+	}
+}
+
+func (r *Relay) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(cleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := r.RelayOnce(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Printf("outbox: relay error: %v", err)
+					}
+					break
+				}
+				if n < r.batchSize {
+					break
+				}
+			}
+		case <-cleanup.C:
+			if r.retention <= 0 {
+				continue
+			}
+			n, err := r.Cleanup(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("outbox: cleanup error: %v", err)
+				}
+				continue
+			}
+			if n > 0 {
+				log.Printf("outbox: removed %d delivered events", n)
+			}
+		}
+	}
+}
+
+// RelayOnce publishes one batch of pending events and returns its size.
+// Rows are locked with SKIP LOCKED so several replicas can relay concurrently.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Printf("outbox: tx rollback error: %v", err)
+		}
+	}()
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, event_id, aggregate_id, event_type, payload
+		FROM outbox
+		WHERE delivered_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, r.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		ids  []int64
+		msgs []kafka.Message
+	)
+	for rows.Next() {
+		var (
+			id                           int64
+			eventID, aggregateID, evType string
+			payload                      []byte
+		)
+		if err := rows.Scan(&id, &eventID, &aggregateID, &evType, &payload); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		msgs = append(msgs, kafka.Message{
+			Key:   []byte(aggregateID),
+			Value: payload,
+			Headers: []kafka.Header{
+				{Key: "event-id", Value: []byte(eventID)},
+				{Key: "event-type", Value: []byte(evType)},
+			},
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+
+	if err := r.pub.WriteMessages(ctx, msgs...); err != nil {
+		return 0, fmt.Errorf("outbox: publish: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE outbox SET delivered_at = now() WHERE id = ANY($1)`, ids); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(msgs), nil
+}
+
+func (r *Relay) Cleanup(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx,
+		`DELETE FROM outbox WHERE delivered_at IS NOT NULL AND delivered_at < $1`,
+		time.Now().Add(-r.retention),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,177 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"orderservice/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ArchivedOrder is an order leaving the live tables. DeletedAt is set for
+// orders that were soft deleted before they expired.
+type ArchivedOrder struct {
+	Order     *model.Order `json:"order"`
+	DeletedAt *time.Time   `json:"deleted_at,omitempty"`
+}
+
+// Archiver removes expired orders from the live tables, either into the
+// *_archive tables or through a caller-supplied export. Only Postgres is
+// supported.
+type Archiver struct {
+	db  *pgxpool.Pool
+	enc *fieldEncryption
+}
+
+// NewArchiver takes the repo options so that exported orders are decrypted.
+func NewArchiver(db *pgxpool.Pool, opts ...Option) *Archiver {
+	return &Archiver{db: db, enc: applyOptions(opts).enc}
+}
+
+// ArchiveExpired moves up to limit orders created or soft deleted before
+// cutoff into the archive tables and returns their ids.
+func (a *Archiver) ArchiveExpired(ctx context.Context, cutoff time.Time, limit int) ([]string, error) {
+	return a.expire(ctx, cutoff, limit, model.AuditArchived, func(tx pgx.Tx, ids []string) error {
+		// Archive tables are created with LIKE, so their columns line up
+		// with the live ones; orders_archive adds archived_at at the end.
+		for _, q := range []string{
+			`DELETE FROM orders_archive WHERE order_uid = ANY($1)`,
+			`DELETE FROM deliveries_archive WHERE order_uid = ANY($1)`,
+			`DELETE FROM payments_archive WHERE order_uid = ANY($1)`,
+			`DELETE FROM items_archive WHERE order_uid = ANY($1)`,
+			`DELETE FROM item_status_history_archive WHERE order_uid = ANY($1)`,
+			`INSERT INTO orders_archive SELECT *, now() FROM orders WHERE order_uid = ANY($1)`,
+			`INSERT INTO deliveries_archive SELECT * FROM deliveries WHERE order_uid = ANY($1)`,
+			`INSERT INTO payments_archive SELECT * FROM payments WHERE order_uid = ANY($1)`,
+			`INSERT INTO items_archive SELECT * FROM items WHERE order_uid = ANY($1)`,
+			`INSERT INTO item_status_history_archive SELECT * FROM item_status_history WHERE order_uid = ANY($1)`,
+		} {
+			if _, err := tx.Exec(ctx, q, ids); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// checkNotArchived runs after the live row, if any, is locked, so an order
+// archived meanwhile is seen once the archiver commits.
+func checkNotArchived(ctx context.Context, tx pgx.Tx, orderUID string) error {
+	var archived bool
+	err := tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM orders_archive WHERE order_uid = $1)`, orderUID,
+	).Scan(&archived)
+	if err != nil {
+		return err
+	}
+	if archived {
+		return ErrOrderArchived
+	}
+	return nil
+}
+
+// ExportExpired passes up to limit orders created or soft deleted before
+// cutoff to export and deletes them once it returns nil. If the delete fails
+// after a successful export, the same orders are exported again next time.
+func (a *Archiver) ExportExpired(ctx context.Context, cutoff time.Time, limit int, export func([]ArchivedOrder) error) ([]string, error) {
+	return a.expire(ctx, cutoff, limit, model.AuditExported, func(tx pgx.Tx, ids []string) error {
+		rows, err := tx.Query(ctx, selectOrders+"WHERE o.order_uid = ANY($1) ORDER BY o.date_created", ids)
+		if err != nil {
+			return err
+		}
+		orders, err := a.enc.collectOrders(rows)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := deletedAt(ctx, tx, ids)
+		if err != nil {
+			return err
+		}
+		batch := make([]ArchivedOrder, len(orders))
+		for i, ord := range orders {
+			batch[i] = ArchivedOrder{Order: ord}
+			if t, ok := deleted[ord.OrderUID]; ok {
+				batch[i].DeletedAt = &t
+			}
+		}
+		return export(batch)
+	})
+}
+
+// expire locks a batch of expired orders, lets move copy them elsewhere and
+// deletes them from the live tables in the same transaction, recording action
+// in the audit log. Locked rows are skipped, so several replicas can run it at
+// once.
+func (a *Archiver) expire(ctx context.Context, cutoff time.Time, limit int, action string, move func(tx pgx.Tx, ids []string) error) ([]string, error) {
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Printf("repo: tx rollback error: %v", err)
+		}
+	}()
+
+	rows, err := tx.Query(ctx, `
+		SELECT order_uid
+		FROM orders
+		WHERE date_created < $1 OR deleted_at < $1
+		ORDER BY date_created
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	if err := move(tx, ids); err != nil {
+		return nil, err
+	}
+	if err := insertAudit(ctx, tx, ids, action, nil); err != nil {
+		return nil, err
+	}
+	// Deliveries, payments, items and their status history go with ON
+	// DELETE CASCADE; move has copied whatever is kept.
+	if _, err := tx.Exec(ctx, `DELETE FROM orders WHERE order_uid = ANY($1)`, ids); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func deletedAt(ctx context.Context, tx pgx.Tx, ids []string) (map[string]time.Time, error) {
+	rows, err := tx.Query(ctx,
+		`SELECT order_uid, deleted_at FROM orders WHERE order_uid = ANY($1) AND deleted_at IS NOT NULL`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deleted := map[string]time.Time{}
+	for rows.Next() {
+		var (
+			id string
+			t  time.Time
+		)
+		if err := rows.Scan(&id, &t); err != nil {
+			return nil, err
+		}
+		deleted[id] = t
+	}
+	return deleted, rows.Err()
+}
@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// lockedOrder returns the stored order, deleted or not, locking it until the
+// transaction ends, or nil if there is none.
+func (e *fieldEncryption) lockedOrder(ctx context.Context, tx pgx.Tx, id string) (*model.Order, error) {
+	ord, err := e.scanOrder(tx.QueryRow(ctx, selectOrders+"WHERE o.order_uid = $1 FOR UPDATE OF o", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return ord, nil
+}
+
+// auditChange records a created or updated order. Updates that change
+// nothing, such as replayed messages, are not recorded.
+func auditChange(ctx context.Context, tx pgx.Tx, before, after *model.Order) error {
+	changes, err := audit.Diff(before, after)
+	if err != nil {
+		return err
+	}
+	action := model.AuditCreated
+	if before != nil {
+		if len(changes) == 0 {
+			return nil
+		}
+		action = model.AuditUpdated
+	}
+	return insertAudit(ctx, tx, []string{after.OrderUID}, action, changes)
+}
+
+// insertAudit records the same change for every order in ids, attributed to
+// the source in ctx.
+func insertAudit(ctx context.Context, tx pgx.Tx, ids []string, action string, changes []model.AuditChange) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if changes == nil {
+		changes = []model.AuditChange{}
+	}
+	payload, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	src := audit.SourceFrom(ctx)
+	_, err = tx.Exec(ctx, `
+		INSERT INTO order_audit (
+			order_uid, action, source_kind, principal, request_id,
+			topic, kafka_partition, kafka_offset, changes
+		)
+		SELECT id, $2::text, $3::text, NULLIF($4::text, ''), NULLIF($5::text, ''), NULLIF($6::text, ''),
+			$7::int, $8::bigint, $9::jsonb
+		FROM unnest($1::text[]) AS id
+	`, ids, action, src.Kind, src.Principal, src.RequestID, src.Topic, src.Partition, src.Offset, payload)
+	return err
+}
+
+func (o repo) OrderHistory(ctx context.Context, id string) ([]model.AuditEntry, error) {
+	rows, err := o.db.Query(ctx, `
+		SELECT id, order_uid, action, source_kind, COALESCE(principal, ''), COALESCE(request_id, ''),
+			COALESCE(topic, ''), kafka_partition, kafka_offset, changes, created_at
+		FROM order_audit
+		WHERE order_uid = $1
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.AuditEntry, error) {
+		var e model.AuditEntry
+		err := row.Scan(&e.ID, &e.OrderUID, &e.Action, &e.Source.Kind, &e.Source.Principal, &e.Source.RequestID,
+			&e.Source.Topic, &e.Source.Partition, &e.Source.Offset, &e.Changes, &e.At)
+		return e, err
+	})
+}
@@ -0,0 +1,135 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/infrastructure/outbox"
+	"orderservice/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// The customer lock serializes an erasure with concurrent writes of the same
+// customer: writers take it shared, EraseCustomer exclusively.
+const (
+	lockCustomerShared = `SELECT pg_advisory_xact_lock_shared(hashtext('customer_erasure'), hashtext($1))`
+	lockCustomer       = `SELECT pg_advisory_xact_lock(hashtext('customer_erasure'), hashtext($1))`
+)
+
+func checkNotErased(ctx context.Context, tx pgx.Tx, ord *model.Order) error {
+	if _, err := tx.Exec(ctx, lockCustomerShared, ord.CustomerID); err != nil {
+		return err
+	}
+	var erasedAt *time.Time
+	err := tx.QueryRow(ctx,
+		`SELECT max(erased_at) FROM customer_erasures WHERE customer_id = $1`, ord.CustomerID,
+	).Scan(&erasedAt)
+	if err != nil {
+		return err
+	}
+	if erasedAt != nil && !ord.DateCreated.After(*erasedAt) {
+		return ErrCustomerErased
+	}
+	return nil
+}
+
+func (o repo) EraseCustomer(ctx context.Context, customerID, requestID string) (*model.Erasure, error) {
+	tx, err := o.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Printf("repo: tx rollback error: %v", err)
+		}
+	}()
+
+	if _, err := tx.Exec(ctx, lockCustomer, customerID); err != nil {
+		return nil, err
+	}
+
+	args := pgx.NamedArgs{
+		"customer_id": customerID,
+		"erased":      model.ErasedValue,
+		"email":       model.ErasedEmail,
+	}
+	rows, err := tx.Query(ctx, `
+		UPDATE deliveries d
+		SET name = @erased, phone = @erased, address = @erased, email = @email,
+			email_bidx = NULL, phone_bidx = NULL
+		FROM orders o
+		WHERE o.order_uid = d.order_uid AND o.customer_id = @customer_id
+		RETURNING d.order_uid
+	`, args)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(ctx, `
+		UPDATE deliveries_archive d
+		SET name = @erased, phone = @erased, address = @erased, email = @email,
+			email_bidx = NULL, phone_bidx = NULL
+		FROM orders_archive o
+		WHERE o.order_uid = d.order_uid AND o.customer_id = @customer_id
+		RETURNING d.order_uid
+	`, args)
+	if err != nil {
+		return nil, err
+	}
+	archived, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	// Events written before deliveries were left out of them carry the old
+	// delivery data, so every event of the customer goes, including those
+	// of orders no longer stored. Pending events are superseded by the
+	// order.updated events written below.
+	_, err = tx.Exec(ctx, `
+		DELETE FROM outbox
+		WHERE aggregate_id = ANY($1) OR payload->'order'->>'customer_id' = $2
+	`, append(archived, ids...), customerID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err = tx.Query(ctx, selectLiveOrders+"AND o.order_uid = ANY($1)", ids)
+	if err != nil {
+		return nil, err
+	}
+	live, err := o.enc.collectOrders(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, ord := range live {
+		if err := outbox.Enqueue(ctx, tx, outbox.EventOrderUpdated, ord); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := insertAudit(ctx, tx, append(ids, archived...), model.AuditErased, audit.ErasedChanges()); err != nil {
+		return nil, err
+	}
+
+	e := &model.Erasure{CustomerID: customerID, OrderUIDs: ids}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO customer_erasures (customer_id, request_id, orders_affected)
+		VALUES ($1, $2, $3)
+		RETURNING erased_at
+	`, customerID, requestID, len(ids)+len(archived)).Scan(&e.ErasedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"orderservice/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// exportBatchSize is how many orders a cursor fetch returns; it bounds the
+// memory ExportOrders needs.
+const exportBatchSize = 500
+
+// ExportOrders reads the orders through a server-side cursor in a read-only
+// repeatable read transaction, so the export is a consistent snapshot.
+func (o repo) ExportOrders(ctx context.Context, from, to *time.Time, fn func(*model.Order) error) error {
+	tx, err := o.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Printf("repo: tx rollback error: %v", err)
+		}
+	}()
+
+	_, err = tx.Exec(ctx, `DECLARE export_orders NO SCROLL CURSOR FOR `+selectLiveOrders+`
+		AND ($1::timestamptz IS NULL OR o.date_created >= $1)
+		AND ($2::timestamptz IS NULL OR o.date_created < $2)
+		ORDER BY o.date_created, o.order_uid`, from, to)
+	if err != nil {
+		return err
+	}
+
+	for {
+		rows, err := tx.Query(ctx, `FETCH FORWARD `+strconv.Itoa(exportBatchSize)+` FROM export_orders`)
+		if err != nil {
+			return err
+		}
+		n := 0
+		for rows.Next() {
+			ord, err := o.enc.scanOrder(rows)
+			if err == nil {
+				err = fn(ord)
+			}
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			n++
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if n < exportBatchSize {
+			return tx.Commit(ctx)
+		}
+	}
+}
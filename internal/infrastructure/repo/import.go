@@ -0,0 +1,294 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Importer bulk loads orders: each batch is copied into temporary staging
+// tables and moved into the live tables with a few set based statements.
+type Importer struct {
+	db  *pgxpool.Pool
+	enc *fieldEncryption
+}
+
+// NewImporter takes the repo options so that imported deliveries are
+// encrypted like written ones.
+func NewImporter(db *pgxpool.Pool, opts ...Option) *Importer {
+	return &Importer{db: db, enc: applyOptions(opts).enc}
+}
+
+// ImportResult splits the ids of a batch by outcome.
+type ImportResult struct {
+	Inserted []string
+	// Existing orders, live, soft deleted, archived or inserted by an
+	// earlier run, are left untouched, so a batch can be imported twice.
+	Existing []string
+	// Erased orders are dated before an erasure of their customer.
+	Erased []string
+}
+
+// ImportOrders inserts the orders of a batch in one transaction. Unlike
+// CreateOrder it never updates an order and publishes no outbox events; the
+// orders get a created audit entry. Order ids must be unique in the batch.
+func (i *Importer) ImportOrders(ctx context.Context, orders []*model.Order) (*ImportResult, error) {
+	res := &ImportResult{}
+	if len(orders) == 0 {
+		return res, nil
+	}
+
+	tx, err := i.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Printf("repo: tx rollback error: %v", err)
+		}
+	}()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	customers := map[string]bool{}
+	for _, ord := range orders {
+		customers[ord.CustomerID] = true
+		mergeItemStatus(nil, ord, now)
+	}
+	ids := make([]string, 0, len(customers))
+	for c := range customers {
+		ids = append(ids, c)
+	}
+	sort.Strings(ids)
+	if _, err := tx.Exec(ctx, `
+		SELECT pg_advisory_xact_lock_shared(hashtext('customer_erasure'), hashtext(c))
+		FROM unnest($1::text[]) AS c`, ids); err != nil {
+		return nil, err
+	}
+
+	if err := i.stage(ctx, tx, orders); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		DELETE FROM import_orders s
+		USING (
+			SELECT customer_id, max(erased_at) AS erased_at
+			FROM customer_erasures
+			GROUP BY customer_id
+		) e
+		WHERE e.customer_id = s.customer_id AND s.date_created <= e.erased_at
+		RETURNING s.order_uid`)
+	if err != nil {
+		return nil, err
+	}
+	if res.Erased, err = pgx.CollectRows(rows, pgx.RowTo[string]); err != nil {
+		return nil, err
+	}
+
+	// Archived orders count as existing; they must not come back as live.
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM import_orders s
+		USING orders_archive a
+		WHERE a.order_uid = s.order_uid`); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(ctx, `
+		INSERT INTO orders (
+			order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+		)
+		SELECT
+			order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+		FROM import_orders
+		ON CONFLICT (order_uid) DO NOTHING
+		RETURNING order_uid`)
+	if err != nil {
+		return nil, err
+	}
+	if res.Inserted, err = pgx.CollectRows(rows, pgx.RowTo[string]); err != nil {
+		return nil, err
+	}
+
+	done := make(map[string]bool, len(orders))
+	for _, id := range res.Erased {
+		done[id] = true
+	}
+	for _, id := range res.Inserted {
+		done[id] = true
+	}
+	for _, ord := range orders {
+		if !done[ord.OrderUID] {
+			res.Existing = append(res.Existing, ord.OrderUID)
+		}
+	}
+	if len(res.Inserted) == 0 {
+		return res, tx.Commit(ctx)
+	}
+
+	for _, q := range []struct {
+		sql  string
+		args []any
+	}{
+		{`INSERT INTO deliveries (
+			order_uid, name, phone, zip, city, address, region, email,
+			pii_key_id, pii_data_key, email_bidx, phone_bidx
+		)
+		SELECT
+			order_uid, name, phone, zip, city, address, region, email,
+			pii_key_id, pii_data_key, email_bidx, phone_bidx
+		FROM import_deliveries WHERE order_uid = ANY($1)`, []any{res.Inserted}},
+		{`INSERT INTO payments (
+			order_uid, transaction, request_id, currency, provider,
+			amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+		)
+		SELECT
+			order_uid, transaction, request_id, currency, provider,
+			amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+		FROM import_payments WHERE order_uid = ANY($1)`, []any{res.Inserted}},
+		{`INSERT INTO items (
+			order_uid, chrt_id, track_number, price, rid,
+			name, sale, size, total_price, nm_id, brand, status
+		)
+		SELECT
+			order_uid, chrt_id, track_number, price, rid,
+			name, sale, size, total_price, nm_id, brand, status
+		FROM import_items WHERE order_uid = ANY($1)
+		ORDER BY pos`, []any{res.Inserted}},
+		{`INSERT INTO item_status_history (order_uid, rid, status, changed_at)
+		SELECT order_uid, rid, status, $2::timestamptz
+		FROM import_items WHERE order_uid = ANY($1)
+		ORDER BY pos`, []any{res.Inserted, now}},
+	} {
+		if _, err := tx.Exec(ctx, q.sql, q.args...); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := auditImported(ctx, tx, orders, res.Inserted); err != nil {
+		return nil, err
+	}
+	return res, tx.Commit(ctx)
+}
+
+// stage copies the batch into temporary tables shaped like the live ones,
+// which are dropped when the transaction ends.
+func (i *Importer) stage(ctx context.Context, tx pgx.Tx, orders []*model.Order) error {
+	_, err := tx.Exec(ctx, `
+		CREATE TEMP TABLE import_orders ON COMMIT DROP AS
+		SELECT order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+		FROM orders WITH NO DATA;
+		CREATE TEMP TABLE import_deliveries ON COMMIT DROP AS
+		SELECT order_uid, name, phone, zip, city, address, region, email,
+			pii_key_id, pii_data_key, email_bidx, phone_bidx
+		FROM deliveries WITH NO DATA;
+		CREATE TEMP TABLE import_payments ON COMMIT DROP AS
+		SELECT order_uid, transaction, request_id, currency, provider,
+			amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+		FROM payments WITH NO DATA;
+		CREATE TEMP TABLE import_items ON COMMIT DROP AS
+		SELECT 0 AS pos, order_uid, chrt_id, track_number, price, rid,
+			name, sale, size, total_price, nm_id, brand, status
+		FROM items WITH NO DATA`)
+	if err != nil {
+		return err
+	}
+
+	var ords, deliveries, payments, items [][]any
+	for _, ord := range orders {
+		ords = append(ords, []any{
+			ord.OrderUID, ord.TrackNumber, ord.Entry, ord.Locale, ord.InternalSignature,
+			ord.CustomerID, ord.DeliveryService, ord.ShardKey, ord.SMID, ord.DateCreated, ord.OOFShard,
+		})
+		d, err := i.enc.seal(ord.OrderUID, ord.Delivery)
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, []any{
+			ord.OrderUID, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email,
+			d.KeyID, d.DataKey, d.EmailBidx, d.PhoneBidx,
+		})
+		p := ord.Payment
+		payments = append(payments, []any{
+			ord.OrderUID, p.Transaction, p.RequestID, p.Currency, p.Provider,
+			p.Amount, p.PaymentDt, p.Bank, p.DeliveryCost, p.GoodsTotal, p.CustomFee,
+		})
+		for _, it := range ord.Items {
+			items = append(items, []any{
+				len(items), ord.OrderUID, it.ChrtID, it.TrackNumber, it.Price, it.RID,
+				it.Name, it.Sale, it.Size, it.TotalPrice, it.NMID, it.Brand, int(it.Status),
+			})
+		}
+	}
+
+	for _, c := range []struct {
+		table string
+		cols  []string
+		rows  [][]any
+	}{
+		{"import_orders", []string{
+			"order_uid", "track_number", "entry", "locale", "internal_signature",
+			"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard",
+		}, ords},
+		{"import_deliveries", []string{
+			"order_uid", "name", "phone", "zip", "city", "address", "region", "email",
+			"pii_key_id", "pii_data_key", "email_bidx", "phone_bidx",
+		}, deliveries},
+		{"import_payments", []string{
+			"order_uid", "transaction", "request_id", "currency", "provider",
+			"amount", "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee",
+		}, payments},
+		{"import_items", []string{
+			"pos", "order_uid", "chrt_id", "track_number", "price", "rid",
+			"name", "sale", "size", "total_price", "nm_id", "brand", "status",
+		}, items},
+	} {
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{c.table}, c.cols, pgx.CopyFromRows(c.rows)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditImported records a created entry with the full order for every
+// inserted one, in a single statement.
+func auditImported(ctx context.Context, tx pgx.Tx, orders []*model.Order, inserted []string) error {
+	byID := make(map[string]*model.Order, len(orders))
+	for _, ord := range orders {
+		byID[ord.OrderUID] = ord
+	}
+	payloads := make([]string, len(inserted))
+	for n, id := range inserted {
+		changes, err := audit.Diff(nil, byID[id])
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(changes)
+		if err != nil {
+			return err
+		}
+		payloads[n] = string(b)
+	}
+	src := audit.SourceFrom(ctx)
+	_, err := tx.Exec(ctx, `
+		INSERT INTO order_audit (
+			order_uid, action, source_kind, principal, request_id,
+			topic, kafka_partition, kafka_offset, changes
+		)
+		SELECT id, $2::text, $3::text, NULLIF($4::text, ''), NULLIF($5::text, ''), NULLIF($6::text, ''),
+			$7::int, $8::bigint, changes
+		FROM unnest($1::text[], $9::jsonb[]) AS t(id, changes)
+	`, inserted, model.AuditCreated, src.Kind, src.Principal, src.RequestID, src.Topic, src.Partition, src.Offset, payloads)
+	return err
+}
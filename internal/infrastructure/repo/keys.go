@@ -0,0 +1,166 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"orderservice/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// deliveryTables are rewritten together: archived rows are encrypted with
+// the same keys as live ones.
+var deliveryTables = []string{"deliveries", "deliveries_archive"}
+
+// KeyMaintainer rewrites delivery rows after the PII settings changed. Each
+// call handles one batch per table and returns how many rows it changed, so
+// callers loop until it returns 0. Locked rows are skipped.
+type KeyMaintainer struct {
+	db  *pgxpool.Pool
+	enc *fieldEncryption
+}
+
+// NewKeyMaintainer needs WithEncryption, except for Decrypt, which only needs
+// the master keys of the rows.
+func NewKeyMaintainer(db *pgxpool.Pool, opts ...Option) *KeyMaintainer {
+	return &KeyMaintainer{db: db, enc: applyOptions(opts).enc}
+}
+
+// Rewrap re-wraps data keys made with other master keys under the active
+// one. Values are not re-encrypted.
+func (k *KeyMaintainer) Rewrap(ctx context.Context, limit int) (int, error) {
+	if k.enc == nil {
+		return 0, errors.New("repo: PII keys are not configured")
+	}
+	active := k.enc.cipher.ActiveKeyID()
+	return k.eachTable(ctx, func(tx pgx.Tx, table string) (int, error) {
+		rows, err := tx.Query(ctx, `
+			SELECT id, pii_key_id, pii_data_key FROM `+table+`
+			WHERE pii_key_id IS NOT NULL AND pii_key_id <> $1
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		`, active, limit)
+		if err != nil {
+			return 0, err
+		}
+		type row struct {
+			ID      int64
+			KeyID   string
+			DataKey []byte
+		}
+		batch, err := pgx.CollectRows(rows, pgx.RowToStructByPos[row])
+		if err != nil {
+			return 0, err
+		}
+		for _, r := range batch {
+			wrapped, err := k.enc.cipher.Rewrap(r.KeyID, r.DataKey)
+			if err != nil {
+				return 0, err
+			}
+			_, err = tx.Exec(ctx, `UPDATE `+table+` SET pii_key_id = $1, pii_data_key = $2 WHERE id = $3`,
+				active, wrapped, r.ID)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return len(batch), nil
+	})
+}
+
+// Encrypt encrypts rows that were written in clear text.
+func (k *KeyMaintainer) Encrypt(ctx context.Context, limit int) (int, error) {
+	if k.enc == nil {
+		return 0, errors.New("repo: PII keys are not configured")
+	}
+	return k.rewrite(ctx, `pii_key_id IS NULL`, limit, func(r storedDelivery) (sealedDelivery, error) {
+		return k.enc.seal(r.OrderUID, r.Delivery)
+	})
+}
+
+// Decrypt writes encrypted rows back in clear text, e.g. before rolling back
+// the encryption migration.
+func (k *KeyMaintainer) Decrypt(ctx context.Context, limit int) (int, error) {
+	return k.rewrite(ctx, `pii_key_id IS NOT NULL`, limit, func(r storedDelivery) (sealedDelivery, error) {
+		s := sealedDelivery{Delivery: r.Delivery}
+		err := k.enc.open(r.OrderUID, &s.Delivery, r.KeyID, r.DataKey)
+		return s, err
+	})
+}
+
+type storedDelivery struct {
+	ID       int64
+	OrderUID string
+	model.Delivery
+	KeyID   *string
+	DataKey []byte
+}
+
+func (k *KeyMaintainer) rewrite(ctx context.Context, where string, limit int, convert func(storedDelivery) (sealedDelivery, error)) (int, error) {
+	return k.eachTable(ctx, func(tx pgx.Tx, table string) (int, error) {
+		rows, err := tx.Query(ctx, `
+			SELECT id, order_uid, name, phone, zip, city, address, region, email, pii_key_id, pii_data_key
+			FROM `+table+`
+			WHERE `+where+`
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		`, limit)
+		if err != nil {
+			return 0, err
+		}
+		batch, err := pgx.CollectRows(rows, func(r pgx.CollectableRow) (storedDelivery, error) {
+			var v storedDelivery
+			err := r.Scan(&v.ID, &v.OrderUID, &v.Name, &v.Phone, &v.Zip, &v.City, &v.Address,
+				&v.Region, &v.Email, &v.KeyID, &v.DataKey)
+			return v, err
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		for _, r := range batch {
+			s, err := convert(r)
+			if err != nil {
+				return 0, err
+			}
+			_, err = tx.Exec(ctx, `
+				UPDATE `+table+` SET
+					name = $1, phone = $2, zip = $3, city = $4, address = $5, region = $6, email = $7,
+					pii_key_id = $8, pii_data_key = $9, email_bidx = $10, phone_bidx = $11
+				WHERE id = $12
+			`, s.Name, s.Phone, s.Zip, s.City, s.Address, s.Region, s.Email,
+				s.KeyID, s.DataKey, s.EmailBidx, s.PhoneBidx, r.ID)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return len(batch), nil
+	})
+}
+
+func (k *KeyMaintainer) eachTable(ctx context.Context, fn func(tx pgx.Tx, table string) (int, error)) (int, error) {
+	tx, err := k.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Printf("repo: tx rollback error: %v", err)
+		}
+	}()
+
+	total := 0
+	for _, table := range deliveryTables {
+		n, err := fn(tx, table)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
@@ -0,0 +1,220 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/infrastructure/fieldcrypt"
+	"orderservice/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// memoryRepo keeps orders in process memory. It is meant for local development
+// only: nothing is persisted and no outbox events are produced.
+type memoryRepo struct {
+	mu      sync.RWMutex
+	orders  map[string]*model.Order
+	deleted map[string]bool
+	erased  map[string]time.Time
+	history map[string][]model.AuditEntry
+	auditID int64
+}
+
+func NewMemoryRepo() Repo {
+	return &memoryRepo{
+		orders:  make(map[string]*model.Order),
+		deleted: make(map[string]bool),
+		erased:  make(map[string]time.Time),
+		history: make(map[string][]model.AuditEntry),
+	}
+}
+
+func (m *memoryRepo) CreateOrder(ctx context.Context, ord *model.Order) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.deleted[ord.OrderUID] {
+		return "", ErrOrderDeleted
+	}
+	if t, ok := m.erased[ord.CustomerID]; ok && !ord.DateCreated.After(t) {
+		return "", ErrCustomerErased
+	}
+	if err := CheckPlainDelivery(ord.Delivery); err != nil {
+		return "", err
+	}
+	mergeItemStatus(m.orders[ord.OrderUID], ord, time.Now().UTC())
+	changes, err := audit.Diff(m.orders[ord.OrderUID], ord)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case m.orders[ord.OrderUID] == nil:
+		m.record(ctx, ord.OrderUID, model.AuditCreated, changes)
+	case len(changes) > 0:
+		m.record(ctx, ord.OrderUID, model.AuditUpdated, changes)
+	}
+	m.orders[ord.OrderUID] = cloneOrder(ord)
+	return ord.OrderUID, nil
+}
+
+func (m *memoryRepo) GetOrderByID(ctx context.Context, id string) (*model.Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ord, ok := m.orders[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return cloneOrder(ord), nil
+}
+
+func (m *memoryRepo) GetAllOrders(ctx context.Context) ([]*model.Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orders := make([]*model.Order, 0, len(m.orders))
+	for _, ord := range m.orders {
+		orders = append(orders, cloneOrder(ord))
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].DateCreated.Before(orders[j].DateCreated)
+	})
+	return orders, nil
+}
+
+func (m *memoryRepo) ExportOrders(ctx context.Context, from, to *time.Time, fn func(*model.Order) error) error {
+	orders, err := m.GetAllOrders(ctx)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(orders, func(i, j int) bool {
+		a, b := orders[i], orders[j]
+		if !a.DateCreated.Equal(b.DateCreated) {
+			return a.DateCreated.Before(b.DateCreated)
+		}
+		return a.OrderUID < b.OrderUID
+	})
+	for _, ord := range orders {
+		if (from != nil && ord.DateCreated.Before(*from)) || (to != nil && !ord.DateCreated.Before(*to)) {
+			continue
+		}
+		if err := fn(ord); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryRepo) DeleteOrder(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.orders[id]; !ok {
+		return pgx.ErrNoRows
+	}
+	delete(m.orders, id)
+	m.deleted[id] = true
+	m.record(ctx, id, model.AuditDeleted, nil)
+	return nil
+}
+
+// EraseCustomer only reaches live orders: the memory repo forgets deleted
+// ones and has no archive.
+func (m *memoryRepo) EraseCustomer(ctx context.Context, customerID, requestID string) (*model.Erasure, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := &model.Erasure{CustomerID: customerID, ErasedAt: time.Now()}
+	for id, ord := range m.orders {
+		if ord.CustomerID == customerID {
+			ord.Delivery.Erase()
+			e.OrderUIDs = append(e.OrderUIDs, id)
+			m.record(ctx, id, model.AuditErased, audit.ErasedChanges())
+		}
+	}
+	sort.Strings(e.OrderUIDs)
+	m.erased[customerID] = e.ErasedAt
+	return e, nil
+}
+
+func (m *memoryRepo) FindOrdersByContact(ctx context.Context, email, phone string) ([]*model.Order, error) {
+	if email == "" && phone == "" {
+		return nil, nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var orders []*model.Order
+	for _, ord := range m.orders {
+		if email != "" && fieldcrypt.Normalize("email", ord.Delivery.Email) != fieldcrypt.Normalize("email", email) {
+			continue
+		}
+		if phone != "" && fieldcrypt.Normalize("phone", ord.Delivery.Phone) != fieldcrypt.Normalize("phone", phone) {
+			continue
+		}
+		orders = append(orders, cloneOrder(ord))
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].DateCreated.Before(orders[j].DateCreated)
+	})
+	return orders, nil
+}
+
+func (m *memoryRepo) SetItemStatus(ctx context.Context, upd model.StatusUpdate) (*model.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ord, ok := m.orders[upd.OrderUID]
+	if !ok {
+		if m.deleted[upd.OrderUID] {
+			return nil, ErrOrderDeleted
+		}
+		return nil, pgx.ErrNoRows
+	}
+	after, changed, err := applyStatus(ord, upd)
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		changes, err := audit.Diff(ord, after)
+		if err != nil {
+			return nil, err
+		}
+		m.record(ctx, upd.OrderUID, model.AuditUpdated, changes)
+		m.orders[upd.OrderUID] = after
+	}
+	return cloneOrder(after), nil
+}
+
+func (m *memoryRepo) OrderHistory(ctx context.Context, id string) ([]model.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]model.AuditEntry(nil), m.history[id]...), nil
+}
+
+// record must be called with m.mu held.
+func (m *memoryRepo) record(ctx context.Context, id, action string, changes []model.AuditChange) {
+	if changes == nil {
+		changes = []model.AuditChange{}
+	}
+	m.auditID++
+	m.history[id] = append(m.history[id], model.AuditEntry{
+		ID:       m.auditID,
+		OrderUID: id,
+		Action:   action,
+		Source:   audit.SourceFrom(ctx),
+		Changes:  changes,
+		At:       time.Now(),
+	})
+}
+
+func cloneOrder(ord *model.Order) *model.Order {
+	c := *ord
+	c.Items = append([]model.Item(nil), ord.Items...)
+	return &c
+}
@@ -0,0 +1,158 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+
+	"orderservice/internal/infrastructure/fieldcrypt"
+	"orderservice/internal/model"
+)
+
+// PIIFields are the delivery columns that can be encrypted.
+var PIIFields = []string{"name", "phone", "zip", "city", "address", "region", "email"}
+
+// ErrReservedPrefix is returned for a delivery value that looks encrypted in
+// clear text: reading the row back would try to decrypt it and fail.
+var ErrReservedPrefix = errors.New("repo: delivery value starts with the reserved encryption prefix")
+
+type Option func(*options)
+
+type options struct {
+	enc *fieldEncryption
+}
+
+// WithEncryption encrypts the given delivery columns of new and updated rows
+// with c and adds blind indexes of the email and phone. Rows written without
+// it are still read.
+func WithEncryption(c *fieldcrypt.Cipher, fields []string) Option {
+	return func(o *options) {
+		o.enc = &fieldEncryption{cipher: c, fields: fields}
+	}
+}
+
+func applyOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// CheckPIIFields reports fields that WithEncryption does not know.
+func CheckPIIFields(fields []string) error {
+	for _, f := range fields {
+		if deliveryField(&model.Delivery{}, f) == nil {
+			return fmt.Errorf("repo: unknown PII field %q, want one of %v", f, PIIFields)
+		}
+	}
+	return nil
+}
+
+// fieldEncryption is nil when encryption is off; its methods accept that.
+type fieldEncryption struct {
+	cipher *fieldcrypt.Cipher
+	fields []string
+}
+
+// sealedDelivery is a delivery row as stored.
+type sealedDelivery struct {
+	model.Delivery
+	KeyID     *string
+	DataKey   []byte
+	EmailBidx []byte
+	PhoneBidx []byte
+}
+
+// CheckPlainDelivery rejects values that open would take for ciphertext. All
+// fields are checked, with or without encryption, so that such rows can be
+// neither written nor encrypted later.
+func CheckPlainDelivery(d model.Delivery) error {
+	for _, f := range PIIFields {
+		if fieldcrypt.IsEncrypted(*deliveryField(&d, f)) {
+			return fmt.Errorf("%w: field %s", ErrReservedPrefix, f)
+		}
+	}
+	return nil
+}
+
+func (e *fieldEncryption) seal(orderUID string, d model.Delivery) (sealedDelivery, error) {
+	s := sealedDelivery{Delivery: d}
+	if err := CheckPlainDelivery(d); err != nil {
+		return s, fmt.Errorf("order %s: %w", orderUID, err)
+	}
+	if e == nil {
+		return s, nil
+	}
+
+	dek, wrapped, err := e.cipher.NewDataKey()
+	if err != nil {
+		return s, err
+	}
+	for _, f := range e.fields {
+		p := deliveryField(&s.Delivery, f)
+		if *p, err = fieldcrypt.Encrypt(dek, *p, aad(orderUID, f)); err != nil {
+			return s, err
+		}
+	}
+	keyID := e.cipher.ActiveKeyID()
+	s.KeyID = &keyID
+	s.DataKey = wrapped
+	s.EmailBidx = e.cipher.BlindIndex("email", d.Email)
+	s.PhoneBidx = e.cipher.BlindIndex("phone", d.Phone)
+	return s, nil
+}
+
+// open decrypts d in place. Every field is checked, not only the configured
+// ones, so changing the field list does not break reading older rows.
+func (e *fieldEncryption) open(orderUID string, d *model.Delivery, keyID *string, wrapped []byte) error {
+	if keyID == nil {
+		return nil
+	}
+	if e == nil {
+		return fmt.Errorf("repo: order %s has encrypted delivery data but no PII keys are configured", orderUID)
+	}
+	dek, err := e.cipher.UnwrapDataKey(*keyID, wrapped)
+	if err != nil {
+		return err
+	}
+	for _, f := range PIIFields {
+		p := deliveryField(d, f)
+		if *p, err = fieldcrypt.Decrypt(dek, *p, aad(orderUID, f)); err != nil {
+			return fmt.Errorf("repo: order %s, field %s: %w", orderUID, f, err)
+		}
+	}
+	return nil
+}
+
+// blindIndex returns nil when encryption is off, which matches no row.
+func (e *fieldEncryption) blindIndex(field, value string) []byte {
+	if e == nil || value == "" {
+		return nil
+	}
+	return e.cipher.BlindIndex(field, value)
+}
+
+func aad(orderUID, field string) string {
+	return orderUID + "/" + field
+}
+
+func deliveryField(d *model.Delivery, name string) *string {
+	switch name {
+	case "name":
+		return &d.Name
+	case "phone":
+		return &d.Phone
+	case "zip":
+		return &d.Zip
+	case "city":
+		return &d.City
+	case "address":
+		return &d.Address
+	case "region":
+		return &d.Region
+	case "email":
+		return &d.Email
+	default:
+		return nil
+	}
+}
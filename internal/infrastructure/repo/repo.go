@@ -5,25 +5,68 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"time"
 
+	"orderservice/internal/infrastructure/fieldcrypt"
+	"orderservice/internal/infrastructure/outbox"
 	"orderservice/internal/model"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ErrOrderDeleted is returned by CreateOrder for an order that was soft
+// deleted; redelivered messages must not bring it back.
+var ErrOrderDeleted = errors.New("repo: order is deleted")
+
+// ErrOrderArchived is returned by CreateOrder for an order moved to the
+// archive tables; a replayed message must not make it live again.
+var ErrOrderArchived = errors.New("repo: order is archived")
+
+// ErrCustomerErased is returned by CreateOrder for an order dated before its
+// customer's data was erased, e.g. a replayed Kafka message.
+var ErrCustomerErased = errors.New("repo: customer data is erased")
+
 type Repo interface {
+	// CreateOrder inserts or updates an order. Items already stored keep
+	// their status and history, which ord is updated with.
 	CreateOrder(ctx context.Context, order *model.Order) (string, error)
 	GetOrderByID(ctx context.Context, id string) (*model.Order, error)
 	GetAllOrders(ctx context.Context) ([]*model.Order, error)
+	// ExportOrders calls fn for every live order created in [from, to),
+	// oldest first; nil bounds are open. Orders are read in batches, so
+	// memory use does not grow with the table. It stops at the first error
+	// fn returns.
+	ExportOrders(ctx context.Context, from, to *time.Time, fn func(*model.Order) error) error
+	// DeleteOrder soft deletes an order; it returns pgx.ErrNoRows if there
+	// is no live order with that id.
+	DeleteOrder(ctx context.Context, id string) error
+	// EraseCustomer anonymizes the delivery data of every order of the
+	// customer, archived ones included, and records the erasure.
+	EraseCustomer(ctx context.Context, customerID, requestID string) (*model.Erasure, error)
+	// FindOrdersByContact returns the live orders whose delivery email and
+	// phone match; empty arguments are ignored. Emails match case
+	// insensitively, phones by their digits.
+	FindOrdersByContact(ctx context.Context, email, phone string) ([]*model.Order, error)
+	// OrderHistory returns the audit entries of an order, oldest first,
+	// including those of deleted and archived orders.
+	OrderHistory(ctx context.Context, id string) ([]model.AuditEntry, error)
+	// SetItemStatus moves an item of a live order along its lifecycle and
+	// returns the updated order. Unknown items give ErrItemNotFound and
+	// forbidden moves model.ErrInvalidTransition; repeating the current
+	// status changes nothing.
+	SetItemStatus(ctx context.Context, upd model.StatusUpdate) (*model.Order, error)
 }
 type repo struct {
-	db *pgxpool.Pool
+	db  *pgxpool.Pool
+	enc *fieldEncryption
 }
 
-func NewRepo(db *pgxpool.Pool) Repo {
+func NewRepo(db *pgxpool.Pool, opts ...Option) Repo {
+	o := applyOptions(opts)
 	return &repo{
-		db: db,
+		db:  db,
+		enc: o.enc,
 	}
 }
 
@@ -38,6 +81,19 @@ func (o repo) CreateOrder(ctx context.Context, ord *model.Order) (string, error)
 		}
 	}()
 
+	if err = checkNotErased(ctx, tx, ord); err != nil {
+		return "", err
+	}
+	before, err := o.enc.lockedOrder(ctx, tx, ord.OrderUID)
+	if err != nil {
+		return "", err
+	}
+	if before == nil {
+		if err = checkNotArchived(ctx, tx, ord.OrderUID); err != nil {
+			return "", err
+		}
+	}
+
 	query :=
 		`INSERT INTO orders (
 			order_uid, track_number, entry, locale, internal_signature,
@@ -45,7 +101,20 @@ func (o repo) CreateOrder(ctx context.Context, ord *model.Order) (string, error)
 		) VALUES (
 			@order_uid, @track_number, @entry, @locale, @internal_signature,
 			@customer_id, @delivery_service, @shardkey, @sm_id, @date_created, @oof_shard
-		)`
+		)
+		ON CONFLICT (order_uid) DO UPDATE SET
+			track_number = EXCLUDED.track_number,
+			entry = EXCLUDED.entry,
+			locale = EXCLUDED.locale,
+			internal_signature = EXCLUDED.internal_signature,
+			customer_id = EXCLUDED.customer_id,
+			delivery_service = EXCLUDED.delivery_service,
+			shardkey = EXCLUDED.shardkey,
+			sm_id = EXCLUDED.sm_id,
+			date_created = EXCLUDED.date_created,
+			oof_shard = EXCLUDED.oof_shard
+		WHERE orders.deleted_at IS NULL
+		RETURNING (xmax = 0) AS inserted`
 	args := pgx.NamedArgs{
 		"order_uid":          ord.OrderUID,
 		"track_number":       ord.TrackNumber,
@@ -59,23 +128,58 @@ func (o repo) CreateOrder(ctx context.Context, ord *model.Order) (string, error)
 		"date_created":       ord.DateCreated,
 		"oof_shard":          ord.OOFShard,
 	}
-	_, err = tx.Exec(ctx, query, args)
-	if err != nil {
+	var inserted bool
+	if err = tx.QueryRow(ctx, query, args).Scan(&inserted); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrOrderDeleted
+		}
 		return "", err
 	}
 
+	if !inserted {
+		if _, err = tx.Exec(ctx, "DELETE FROM items WHERE order_uid = $1", ord.OrderUID); err != nil {
+			return "", err
+		}
+	}
+	started := mergeItemStatus(before, ord, time.Now().UTC().Truncate(time.Microsecond))
+
+	d, err := o.enc.seal(ord.OrderUID, ord.Delivery)
+	if err != nil {
+		return "", err
+	}
 	query =
-		`INSERT INTO deliveries (order_uid, name, phone, zip, city, address, region, email)
-		VALUES (@order_uid, @name, @phone, @zip, @city, @address, @region, @email)`
+		`INSERT INTO deliveries (
+			order_uid, name, phone, zip, city, address, region, email,
+			pii_key_id, pii_data_key, email_bidx, phone_bidx
+		) VALUES (
+			@order_uid, @name, @phone, @zip, @city, @address, @region, @email,
+			@pii_key_id, @pii_data_key, @email_bidx, @phone_bidx
+		)
+		ON CONFLICT (order_uid) DO UPDATE SET
+			name = EXCLUDED.name,
+			phone = EXCLUDED.phone,
+			zip = EXCLUDED.zip,
+			city = EXCLUDED.city,
+			address = EXCLUDED.address,
+			region = EXCLUDED.region,
+			email = EXCLUDED.email,
+			pii_key_id = EXCLUDED.pii_key_id,
+			pii_data_key = EXCLUDED.pii_data_key,
+			email_bidx = EXCLUDED.email_bidx,
+			phone_bidx = EXCLUDED.phone_bidx`
 	args = pgx.NamedArgs{
-		"order_uid": ord.OrderUID,
-		"name":      ord.Delivery.Name,
-		"phone":     ord.Delivery.Phone,
-		"zip":       ord.Delivery.Zip,
-		"city":      ord.Delivery.City,
-		"address":   ord.Delivery.Address,
-		"region":    ord.Delivery.Region,
-		"email":     ord.Delivery.Email,
+		"order_uid":    ord.OrderUID,
+		"name":         d.Name,
+		"phone":        d.Phone,
+		"zip":          d.Zip,
+		"city":         d.City,
+		"address":      d.Address,
+		"region":       d.Region,
+		"email":        d.Email,
+		"pii_key_id":   d.KeyID,
+		"pii_data_key": d.DataKey,
+		"email_bidx":   d.EmailBidx,
+		"phone_bidx":   d.PhoneBidx,
 	}
 	_, err = tx.Exec(ctx, query, args)
 	if err != nil {
@@ -89,7 +193,18 @@ func (o repo) CreateOrder(ctx context.Context, ord *model.Order) (string, error)
 		) VALUES (
 			@order_uid, @transaction, @request_id, @currency, @provider,
 			@amount, @payment_dt, @bank, @delivery_cost, @goods_total, @custom_fee
-		)`
+		)
+		ON CONFLICT (order_uid) DO UPDATE SET
+			transaction = EXCLUDED.transaction,
+			request_id = EXCLUDED.request_id,
+			currency = EXCLUDED.currency,
+			provider = EXCLUDED.provider,
+			amount = EXCLUDED.amount,
+			payment_dt = EXCLUDED.payment_dt,
+			bank = EXCLUDED.bank,
+			delivery_cost = EXCLUDED.delivery_cost,
+			goods_total = EXCLUDED.goods_total,
+			custom_fee = EXCLUDED.custom_fee`
 	args = pgx.NamedArgs{
 		"order_uid":     ord.OrderUID,
 		"transaction":   ord.Payment.Transaction,
@@ -138,6 +253,34 @@ func (o repo) CreateOrder(ctx context.Context, ord *model.Order) (string, error)
 		}
 	}
 
+	rids := make([]string, len(ord.Items))
+	for i, item := range ord.Items {
+		rids[i] = item.RID
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM item_status_history WHERE order_uid = $1 AND rid <> ALL($2)`, ord.OrderUID, rids)
+	if err != nil {
+		return "", err
+	}
+	for _, i := range started {
+		item := ord.Items[i]
+		_, err = tx.Exec(ctx, `INSERT INTO item_status_history (order_uid, rid, status, changed_at) VALUES ($1, $2, $3, $4)`,
+			ord.OrderUID, item.RID, item.Status, item.StatusHistory[0].At)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	eventType := outbox.EventOrderCreated
+	if !inserted {
+		eventType = outbox.EventOrderUpdated
+	}
+	if err = outbox.Enqueue(ctx, tx, eventType, ord); err != nil {
+		return "", err
+	}
+	if err = auditChange(ctx, tx, before, ord); err != nil {
+		return "", err
+	}
+
 	if err = tx.Commit(ctx); err != nil {
 		return "", err
 	}
@@ -145,23 +288,58 @@ func (o repo) CreateOrder(ctx context.Context, ord *model.Order) (string, error)
 	return ord.OrderUID, nil
 }
 
-func (o repo) GetOrderByID(ctx context.Context, id string) (*model.Order, error) {
-	ord := &model.Order{}
-
-	query := `
-		SELECT order_uid, track_number, entry, locale, internal_signature,
-			   customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-		FROM orders
-		WHERE order_uid = $1
-	`
-	args := []interface{}{id}
-
-	row := o.db.QueryRow(ctx, query, args...)
+// selectOrders reads an order with its delivery, payment and items in one
+// statement, so the result comes from a single snapshot and a single round trip.
+const selectOrders = `
+	SELECT
+		o.order_uid,
+		o.track_number,
+		o.entry,
+		row_to_json(d) AS delivery,
+		d.pii_key_id,
+		d.pii_data_key,
+		(SELECT row_to_json(p) FROM payments p WHERE p.order_uid = o.order_uid) AS payment,
+		(
+			SELECT json_agg(to_jsonb(i) || jsonb_build_object('status_history', (
+				SELECT json_agg(json_build_object('status', h.status, 'at', h.changed_at) ORDER BY h.id)
+				FROM item_status_history h
+				WHERE h.order_uid = i.order_uid AND h.rid = i.rid
+			)) ORDER BY i.id)
+			FROM items i WHERE i.order_uid = o.order_uid
+		) AS items,
+		o.locale,
+		o.internal_signature,
+		o.customer_id,
+		o.delivery_service,
+		o.shardkey,
+		o.sm_id,
+		o.date_created,
+		o.oof_shard
+	FROM orders o
+	LEFT JOIN deliveries d ON d.order_uid = o.order_uid
+`
+
+const selectLiveOrders = selectOrders + "WHERE o.deleted_at IS NULL\n"
+
+func (e *fieldEncryption) scanOrder(row pgx.Row) (*model.Order, error) {
+	var (
+		ord          model.Order
+		deliveryJSON []byte
+		keyID        *string
+		dataKey      []byte
+		paymentJSON  []byte
+		itemsJSON    []byte
+	)
 
 	err := row.Scan(
 		&ord.OrderUID,
 		&ord.TrackNumber,
 		&ord.Entry,
+		&deliveryJSON,
+		&keyID,
+		&dataKey,
+		&paymentJSON,
+		&itemsJSON,
 		&ord.Locale,
 		&ord.InternalSignature,
 		&ord.CustomerID,
@@ -175,163 +353,109 @@ func (o repo) GetOrderByID(ctx context.Context, id string) (*model.Order, error)
 		return nil, err
 	}
 
-	query = `
-		SELECT name, phone, zip, city, address, region, email
-		FROM deliveries
-		WHERE order_uid = $1
-	`
-	row = o.db.QueryRow(ctx, query, args...)
-
-	err = row.Scan(
-		&ord.Delivery.Name,
-		&ord.Delivery.Phone,
-		&ord.Delivery.Zip,
-		&ord.Delivery.City,
-		&ord.Delivery.Address,
-		&ord.Delivery.Region,
-		&ord.Delivery.Email,
-	)
-	if err != nil {
-		return nil, err
+	for _, part := range []struct {
+		data []byte
+		dst  any
+	}{
+		{deliveryJSON, &ord.Delivery},
+		{paymentJSON, &ord.Payment},
+		{itemsJSON, &ord.Items},
+	} {
+		if part.data == nil {
+			continue
+		}
+		if err := json.Unmarshal(part.data, part.dst); err != nil {
+			return nil, err
+		}
 	}
-
-	query = `
-		SELECT transaction, request_id, currency, provider, amount,
-			   payment_dt, bank, delivery_cost, goods_total, custom_fee
-		FROM payments
-		WHERE order_uid = $1
-	`
-	row = o.db.QueryRow(ctx, query, args...)
-
-	err = row.Scan(
-		&ord.Payment.Transaction,
-		&ord.Payment.RequestID,
-		&ord.Payment.Currency,
-		&ord.Payment.Provider,
-		&ord.Payment.Amount,
-		&ord.Payment.PaymentDt,
-		&ord.Payment.Bank,
-		&ord.Payment.DeliveryCost,
-		&ord.Payment.GoodsTotal,
-		&ord.Payment.CustomFee,
-	)
-	if err != nil {
+	if err := e.open(ord.OrderUID, &ord.Delivery, keyID, dataKey); err != nil {
 		return nil, err
 	}
 
-	query = `
-		SELECT chrt_id, track_number, price, rid, name,
-			   sale, size, total_price, nm_id, brand, status
-		FROM items
-		WHERE order_uid = $1
-	`
-	rows, err := o.db.Query(ctx, query, args...)
-	if err != nil {
-		return nil, err
-	}
+	return &ord, nil
+}
+
+func (e *fieldEncryption) collectOrders(rows pgx.Rows) ([]*model.Order, error) {
 	defer rows.Close()
 
-	var items []model.Item
+	var orders []*model.Order
 	for rows.Next() {
-		var item model.Item
-		err = rows.Scan(
-			&item.ChrtID,
-			&item.TrackNumber,
-			&item.Price,
-			&item.RID,
-			&item.Name,
-			&item.Sale,
-			&item.Size,
-			&item.TotalPrice,
-			&item.NMID,
-			&item.Brand,
-			&item.Status,
-		)
+		ord, err := e.scanOrder(rows)
 		if err != nil {
 			return nil, err
 		}
-		items = append(items, item)
+		orders = append(orders, ord)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	ord.Items = items
 
-	return ord, nil
+	return orders, nil
+}
+
+func (o repo) GetOrderByID(ctx context.Context, id string) (*model.Order, error) {
+	return o.enc.scanOrder(o.db.QueryRow(ctx, selectLiveOrders+"AND o.order_uid = $1", id))
 }
 
 func (o repo) GetAllOrders(ctx context.Context) ([]*model.Order, error) {
-	rows, err := o.db.Query(ctx, `
-		SELECT 
-			o.order_uid,
-			o.track_number,
-			o.entry,
-			row_to_json(d.*) as delivery,
-			row_to_json(p.*) as payment,
-			COALESCE(json_agg(i.*) FILTER (WHERE i.chrt_id IS NOT NULL), '[]') as items,
-			o.locale,
-			o.internal_signature,
-			o.customer_id,
-			o.delivery_service,
-			o.shardkey,
-			o.sm_id,
-			o.date_created,
-			o.oof_shard
-		FROM orders o
-		LEFT JOIN deliveries d ON o.order_uid = d.order_uid
-		LEFT JOIN payments   p ON o.order_uid = p.order_uid
-		LEFT JOIN items      i ON o.order_uid = i.order_uid
-		GROUP BY o.order_uid, d.*, p.*
-	`)
+	rows, err := o.db.Query(ctx, selectLiveOrders)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var orders []*model.Order
-
-	for rows.Next() {
-		var (
-			order        model.Order
-			deliveryJSON []byte
-			paymentJSON  []byte
-			itemsJSON    []byte
-		)
-
-		err = rows.Scan(
-			&order.OrderUID,
-			&order.TrackNumber,
-			&order.Entry,
-			&deliveryJSON,
-			&paymentJSON,
-			&itemsJSON,
-			&order.Locale,
-			&order.InternalSignature,
-			&order.CustomerID,
-			&order.DeliveryService,
-			&order.ShardKey,
-			&order.SMID,
-			&order.DateCreated,
-			&order.OOFShard,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		if err := json.Unmarshal(deliveryJSON, &order.Delivery); err != nil {
-			return nil, err
-		}
+	return o.enc.collectOrders(rows)
+}
 
-		if err := json.Unmarshal(paymentJSON, &order.Payment); err != nil {
-			return nil, err
-		}
+func (o repo) FindOrdersByContact(ctx context.Context, email, phone string) ([]*model.Order, error) {
+	if email == "" && phone == "" {
+		return nil, nil
+	}
+	// Rows written before encryption was enabled have no blind index and
+	// are compared in clear text.
+	query := selectLiveOrders
+	args := pgx.NamedArgs{}
+	if email != "" {
+		query += `AND (d.email_bidx = @email_bidx OR (d.pii_key_id IS NULL AND lower(btrim(d.email)) = @email))
+`
+		args["email_bidx"] = o.enc.blindIndex("email", email)
+		args["email"] = fieldcrypt.Normalize("email", email)
+	}
+	if phone != "" {
+		query += `AND (d.phone_bidx = @phone_bidx OR (d.pii_key_id IS NULL AND regexp_replace(d.phone, '\D', '', 'g') = @phone))
+`
+		args["phone_bidx"] = o.enc.blindIndex("phone", phone)
+		args["phone"] = fieldcrypt.Normalize("phone", phone)
+	}
+	rows, err := o.db.Query(ctx, query+"ORDER BY o.date_created", args)
+	if err != nil {
+		return nil, err
+	}
+	return o.enc.collectOrders(rows)
+}
 
-		if err := json.Unmarshal(itemsJSON, &order.Items); err != nil {
-			return nil, err
+func (o repo) DeleteOrder(ctx context.Context, id string) error {
+	tx, err := o.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Printf("repo: tx rollback error: %v", err)
 		}
+	}()
 
-		orders = append(orders, &order)
+	tag, err := tx.Exec(ctx,
+		`UPDATE orders SET deleted_at = now() WHERE order_uid = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
 	}
-
-	return orders, nil
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	if err = outbox.EnqueueDeleted(ctx, tx, id); err != nil {
+		return err
+	}
+	if err = insertAudit(ctx, tx, []string{id}, model.AuditDeleted, nil); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
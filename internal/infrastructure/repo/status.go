@@ -0,0 +1,118 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"orderservice/internal/infrastructure/outbox"
+	"orderservice/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrItemNotFound = errors.New("repo: item not found")
+
+// mergeItemStatus makes ord keep the status and history of the items stored
+// already has, so order updates cannot move items back. Other items start
+// their history with the status they came with; their indexes are returned.
+func mergeItemStatus(stored, ord *model.Order, now time.Time) []int {
+	known := map[string]model.Item{}
+	if stored != nil {
+		for _, it := range stored.Items {
+			known[it.RID] = it
+		}
+	}
+	var started []int
+	for i := range ord.Items {
+		it := &ord.Items[i]
+		if prev, ok := known[it.RID]; ok {
+			it.Status = prev.Status
+			it.StatusHistory = append([]model.StatusChange(nil), prev.StatusHistory...)
+			continue
+		}
+		it.StatusHistory = []model.StatusChange{{Status: it.Status, At: now}}
+		started = append(started, i)
+	}
+	return started
+}
+
+// applyStatus returns a copy of ord with the item moved to upd.Status and
+// whether anything changed.
+func applyStatus(ord *model.Order, upd model.StatusUpdate) (*model.Order, bool, error) {
+	c := *ord
+	c.Items = make([]model.Item, len(ord.Items))
+	found := false
+	for i, it := range ord.Items {
+		c.Items[i] = it
+		if it.RID != upd.RID {
+			continue
+		}
+		found = true
+		if err := it.Status.Transition(upd.Status); err != nil {
+			return nil, false, err
+		}
+		if it.Status == upd.Status {
+			return ord, false, nil
+		}
+		c.Items[i].Status = upd.Status
+		c.Items[i].StatusHistory = append(append([]model.StatusChange(nil), it.StatusHistory...),
+			model.StatusChange{Status: upd.Status, At: upd.At})
+	}
+	if !found {
+		return nil, false, ErrItemNotFound
+	}
+	return &c, true, nil
+}
+
+func (o repo) SetItemStatus(ctx context.Context, upd model.StatusUpdate) (*model.Order, error) {
+	tx, err := o.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Printf("repo: tx rollback error: %v", err)
+		}
+	}()
+
+	var deleted bool
+	err = tx.QueryRow(ctx, `SELECT deleted_at IS NOT NULL FROM orders WHERE order_uid = $1 FOR UPDATE`, upd.OrderUID).
+		Scan(&deleted)
+	if err != nil {
+		return nil, err
+	}
+	if deleted {
+		return nil, ErrOrderDeleted
+	}
+	before, err := o.enc.lockedOrder(ctx, tx, upd.OrderUID)
+	if err != nil {
+		return nil, err
+	}
+	after, changed, err := applyStatus(before, upd)
+	if err != nil || !changed {
+		return after, err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE items SET status = $3 WHERE order_uid = $1 AND rid = $2`,
+		upd.OrderUID, upd.RID, upd.Status)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO item_status_history (order_uid, rid, status, changed_at) VALUES ($1, $2, $3, $4)`,
+		upd.OrderUID, upd.RID, upd.Status, upd.At)
+	if err != nil {
+		return nil, err
+	}
+	if err = outbox.Enqueue(ctx, tx, outbox.EventOrderUpdated, after); err != nil {
+		return nil, err
+	}
+	if err = auditChange(ctx, tx, before, after); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
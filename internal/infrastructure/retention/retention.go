@@ -0,0 +1,196 @@
+package retention
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/infrastructure/cache"
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+)
+
+const (
+	ModeArchive = "archive"
+	ModeExport  = "export"
+)
+
+// Store is implemented by repo.Archiver.
+type Store interface {
+	ArchiveExpired(ctx context.Context, cutoff time.Time, limit int) ([]string, error)
+	ExportExpired(ctx context.Context, cutoff time.Time, limit int, export func([]repo.ArchivedOrder) error) ([]string, error)
+}
+
+type Options struct {
+	// MaxAge is how long orders stay in the live tables, counted from
+	// date_created or, for soft deleted orders, from deleted_at.
+	MaxAge    time.Duration
+	Interval  time.Duration
+	BatchSize int
+	// Mode is ModeArchive (move to the *_archive tables) or ModeExport
+	// (write gzipped JSONL files to ExportDir and delete).
+	Mode      string
+	ExportDir string
+}
+
+// Job periodically removes expired orders from the live tables and evicts
+// them from the cache.
+type Job struct {
+	store Store
+	cache cache.Cache
+	opts  Options
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewJob(store Store, c cache.Cache, opts Options) (*Job, error) {
+	if opts.MaxAge <= 0 {
+		return nil, fmt.Errorf("retention: max age must be positive, got %v", opts.MaxAge)
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	switch opts.Mode {
+	case ModeArchive:
+	case ModeExport:
+		if opts.ExportDir == "" {
+			return nil, fmt.Errorf("retention: export mode needs an export dir")
+		}
+	default:
+		return nil, fmt.Errorf("retention: unknown mode %q", opts.Mode)
+	}
+	return &Job{store: store, cache: c, opts: opts}, nil
+}
+
+func (j *Job) Start(ctx context.Context) error {
+	if j.opts.Mode == ModeExport {
+		if err := os.MkdirAll(j.opts.ExportDir, 0o755); err != nil {
+			return fmt.Errorf("retention: %w", err)
+		}
+	}
+
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	j.cancel = cancel
+	j.done = make(chan struct{})
+
+	go j.run(runCtx)
+	return nil
+}
+
+func (j *Job) Stop(ctx context.Context) error {
+	if j.cancel == nil {
+		return nil
+	}
+	j.cancel()
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("retention: job did not stop: %w", ctx.Err())
+	}
+}
+
+func (j *Job) run(ctx context.Context) {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := j.RunOnce(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("retention: %v", err)
+				}
+				continue
+			}
+			if n > 0 {
+				log.Printf("retention: %s %d orders", j.verb(), n)
+			}
+		}
+	}
+}
+
+// RunOnce processes batches until no expired orders are left and returns how
+// many orders were removed from the live tables.
+func (j *Job) RunOnce(ctx context.Context) (int, error) {
+	ctx = audit.WithSource(ctx, model.AuditSource{Kind: model.SourceSystem, Principal: "retention"})
+	cutoff := time.Now().Add(-j.opts.MaxAge)
+	total := 0
+	for {
+		var (
+			ids []string
+			err error
+		)
+		if j.opts.Mode == ModeExport {
+			ids, err = j.store.ExportExpired(ctx, cutoff, j.opts.BatchSize, j.writeFile)
+		} else {
+			ids, err = j.store.ArchiveExpired(ctx, cutoff, j.opts.BatchSize)
+		}
+		if err != nil {
+			return total, fmt.Errorf("%s orders: %w", j.verb(), err)
+		}
+		j.cache.Delete(ids...)
+		total += len(ids)
+		if len(ids) < j.opts.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+func (j *Job) verb() string {
+	if j.opts.Mode == ModeExport {
+		return "exported"
+	}
+	return "archived"
+}
+
+// writeFile writes one batch to its own gzipped JSONL file. The file is
+// synced and renamed into place before the orders are deleted.
+func (j *Job) writeFile(batch []repo.ArchivedOrder) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	name := fmt.Sprintf("orders-%s-%s.jsonl.gz",
+		time.Now().UTC().Format("20060102T150405.000000000Z"), batch[0].Order.OrderUID)
+	path := filepath.Join(j.opts.ExportDir, name)
+
+	f, err := os.CreateTemp(j.opts.ExportDir, name+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	enc := json.NewEncoder(zw)
+	for _, o := range batch {
+		if err := enc.Encode(o); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,143 @@
+package retention
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"orderservice/internal/infrastructure/cache"
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+)
+
+// fakeStore hands out expired orders in batches, like repo.Archiver does.
+type fakeStore struct {
+	expired []string
+	cutoffs []time.Time
+}
+
+func (s *fakeStore) take(cutoff time.Time, limit int) []string {
+	s.cutoffs = append(s.cutoffs, cutoff)
+	n := min(limit, len(s.expired))
+	ids := s.expired[:n]
+	s.expired = s.expired[n:]
+	return ids
+}
+
+func (s *fakeStore) ArchiveExpired(ctx context.Context, cutoff time.Time, limit int) ([]string, error) {
+	return s.take(cutoff, limit), nil
+}
+
+func (s *fakeStore) ExportExpired(ctx context.Context, cutoff time.Time, limit int, export func([]repo.ArchivedOrder) error) ([]string, error) {
+	ids := s.take(cutoff, limit)
+	batch := make([]repo.ArchivedOrder, len(ids))
+	for i, id := range ids {
+		batch[i] = repo.ArchivedOrder{Order: &model.Order{OrderUID: id}}
+	}
+	if err := export(batch); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func TestJob_ArchivesInBatchesAndEvictsCache(t *testing.T) {
+	store := &fakeStore{expired: []string{"a", "b", "c", "d", "e"}}
+	c := cache.NewCache()
+	defer c.Close()
+	c.SetupCache([]*model.Order{{OrderUID: "a"}, {OrderUID: "e"}, {OrderUID: "live"}})
+
+	j, err := NewJob(store, c, Options{MaxAge: time.Hour, BatchSize: 2, Mode: ModeArchive})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := j.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Fatalf("expected 5 archived orders, got %d", n)
+	}
+	if len(store.cutoffs) != 3 {
+		t.Fatalf("expected 3 batches, got %d", len(store.cutoffs))
+	}
+	if age := time.Since(store.cutoffs[0]); age < time.Hour || age > time.Hour+time.Minute {
+		t.Fatalf("expected cutoff an hour ago, got %v ago", age)
+	}
+	for _, id := range []string{"a", "e"} {
+		if _, ok := c.Get(id); ok {
+			t.Fatalf("expected %s evicted from cache", id)
+		}
+	}
+	if _, ok := c.Get("live"); !ok {
+		t.Fatal("expected live order to stay cached")
+	}
+}
+
+func TestJob_ExportWritesGzippedJSONL(t *testing.T) {
+	dir := t.TempDir()
+	store := &fakeStore{expired: []string{"a", "b", "c"}}
+	c := cache.NewCache()
+	defer c.Close()
+
+	j, err := NewJob(store, c, Options{MaxAge: time.Hour, BatchSize: 2, Mode: ModeExport, ExportDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected one file per batch, got %v", files)
+	}
+
+	var got []string
+	for _, name := range files {
+		if filepath.Ext(name) != ".gz" {
+			t.Fatalf("unexpected file %s", name)
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sc := bufio.NewScanner(zr)
+		for sc.Scan() {
+			var rec repo.ArchivedOrder
+			if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			got = append(got, rec.Order.OrderUID)
+		}
+		f.Close()
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 exported orders, got %v", got)
+	}
+}
+
+func TestNewJob_RejectsBadOptions(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Close()
+	for name, opts := range map[string]Options{
+		"zero max age":       {Mode: ModeArchive},
+		"unknown mode":       {MaxAge: time.Hour, Mode: "drop"},
+		"export without dir": {MaxAge: time.Hour, Mode: ModeExport},
+	} {
+		if _, err := NewJob(&fakeStore{}, c, opts); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
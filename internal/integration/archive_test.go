@@ -0,0 +1,200 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func createAged(t *testing.T, ctx context.Context, r repo.Repo, ord *model.Order, age time.Duration) {
+	t.Helper()
+	ord.DateCreated = time.Now().Add(-age).UTC().Truncate(time.Second)
+	if _, err := r.CreateOrder(ctx, ord); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+}
+
+func TestArchiver_ArchiveExpired(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	r := repo.NewRepo(db)
+	gen := newGenerator(t)
+
+	old, fresh, deleted := gen.Order(), gen.Order(), gen.Order()
+	createAged(t, ctx, r, old, 48*time.Hour)
+	createAged(t, ctx, r, fresh, time.Hour)
+	createAged(t, ctx, r, deleted, time.Hour)
+	if err := r.DeleteOrder(ctx, deleted.OrderUID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := db.Exec(ctx, `UPDATE orders SET deleted_at = now() - interval '48 hours' WHERE order_uid = $1`, deleted.OrderUID); err != nil {
+		t.Fatalf("age deleted order: %v", err)
+	}
+	cancelled := model.StatusUpdate{OrderUID: old.OrderUID, RID: old.Items[0].RID, Status: model.ItemCancelled}
+	if _, err := r.SetItemStatus(ctx, cancelled); err != nil {
+		t.Fatalf("set status: %v", err)
+	}
+
+	a := repo.NewArchiver(db)
+	cutoff := time.Now().Add(-24 * time.Hour)
+	ids, err := a.ArchiveExpired(ctx, cutoff, 1)
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if len(ids) != 1 {
+		t.Fatalf("expected the batch limit to apply, got %v", ids)
+	}
+	more, err := a.ArchiveExpired(ctx, cutoff, 10)
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	ids = append(ids, more...)
+	if len(ids) != 2 {
+		t.Fatalf("expected old and deleted orders archived, got %v", ids)
+	}
+
+	if _, err := r.GetOrderByID(ctx, old.OrderUID); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("expected archived order gone from live tables, got %v", err)
+	}
+	if _, err := r.GetOrderByID(ctx, fresh.OrderUID); err != nil {
+		t.Fatalf("expected fresh order to stay: %v", err)
+	}
+
+	counts := map[string]int{}
+	for _, table := range []string{"orders", "deliveries", "payments", "items", "item_status_history"} {
+		var live, archived int
+		err := db.QueryRow(ctx, `SELECT
+			(SELECT count(*) FROM `+table+` WHERE order_uid = ANY($1)),
+			(SELECT count(*) FROM `+table+`_archive WHERE order_uid = ANY($1))`,
+			[]string{old.OrderUID, deleted.OrderUID},
+		).Scan(&live, &archived)
+		if err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if live != 0 {
+			t.Fatalf("expected no live %s rows for archived orders, got %d", table, live)
+		}
+		counts[table] = archived
+	}
+	wantItems := len(old.Items) + len(deleted.Items)
+	if counts["orders"] != 2 || counts["deliveries"] != 2 || counts["payments"] != 2 || counts["items"] != wantItems {
+		t.Fatalf("unexpected archive row counts %v (want 2/2/2/%d)", counts, wantItems)
+	}
+	// Every item starts its history on create, and one was cancelled since.
+	if counts["item_status_history"] != wantItems+1 {
+		t.Fatalf("expected %d archived status history rows, got %d", wantItems+1, counts["item_status_history"])
+	}
+	rows, err := db.Query(ctx, `SELECT status FROM item_status_history_archive WHERE order_uid = $1 AND rid = $2 ORDER BY id`,
+		old.OrderUID, old.Items[0].RID)
+	if err != nil {
+		t.Fatalf("archived history: %v", err)
+	}
+	history, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		t.Fatalf("archived history: %v", err)
+	}
+	if len(history) != 2 || history[0] != int(old.Items[0].Status) || history[1] != int(model.ItemCancelled) {
+		t.Fatalf("expected the archived history %v -> cancelled, got %v", old.Items[0].Status, history)
+	}
+}
+
+func TestArchiver_ArchivedOrdersStayArchived(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	r := repo.NewRepo(db)
+	gen := newGenerator(t)
+
+	replayed, imported := gen.Order(), gen.Order()
+	createAged(t, ctx, r, replayed, 48*time.Hour)
+	createAged(t, ctx, r, imported, 48*time.Hour)
+	if _, err := repo.NewArchiver(db).ArchiveExpired(ctx, time.Now().Add(-24*time.Hour), 10); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	if _, err := r.CreateOrder(ctx, replayed); !errors.Is(err, repo.ErrOrderArchived) {
+		t.Fatalf("expected ErrOrderArchived for a replayed order, got %v", err)
+	}
+	res, err := repo.NewImporter(db).ImportOrders(ctx, []*model.Order{imported})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(res.Inserted) != 0 || len(res.Existing) != 1 || res.Existing[0] != imported.OrderUID {
+		t.Fatalf("expected the archived order to count as existing, got %+v", res)
+	}
+
+	var live int
+	if err := db.QueryRow(ctx, `SELECT count(*) FROM orders WHERE order_uid = ANY($1)`,
+		[]string{replayed.OrderUID, imported.OrderUID}).Scan(&live); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if live != 0 {
+		t.Fatalf("expected archived orders to stay out of the live tables, got %d", live)
+	}
+}
+
+func TestArchiver_ExportExpired(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	r := repo.NewRepo(db)
+	gen := newGenerator(t)
+
+	old, deleted := gen.Order(), gen.Order()
+	createAged(t, ctx, r, old, 48*time.Hour)
+	createAged(t, ctx, r, deleted, 47*time.Hour)
+	if err := r.DeleteOrder(ctx, deleted.OrderUID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	a := repo.NewArchiver(db)
+	cutoff := time.Now().Add(-24 * time.Hour)
+
+	// A failed export keeps the orders in place.
+	failed := errors.New("disk full")
+	if _, err := a.ExportExpired(ctx, cutoff, 10, func([]repo.ArchivedOrder) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("expected export error, got %v", err)
+	}
+	if _, err := r.GetOrderByID(ctx, old.OrderUID); err != nil {
+		t.Fatalf("expected order kept after failed export: %v", err)
+	}
+
+	var exported []repo.ArchivedOrder
+	ids, err := a.ExportExpired(ctx, cutoff, 10, func(batch []repo.ArchivedOrder) error {
+		exported = append(exported, batch...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if len(ids) != 2 || len(exported) != 2 {
+		t.Fatalf("expected 2 exported orders, got ids %v, %d records", ids, len(exported))
+	}
+	assertSameOrder(t, old, exported[0].Order)
+	for _, rec := range exported {
+		for _, it := range rec.Order.Items {
+			if len(it.StatusHistory) != 1 || it.StatusHistory[0].Status != it.Status {
+				t.Fatalf("expected the status history in the export, got %+v", it.StatusHistory)
+			}
+		}
+	}
+	if exported[0].DeletedAt != nil {
+		t.Fatal("expected no deleted_at for a live order")
+	}
+	assertSameOrder(t, deleted, exported[1].Order)
+	if exported[1].DeletedAt == nil {
+		t.Fatal("expected deleted_at for a soft deleted order")
+	}
+
+	var left int
+	if err := db.QueryRow(ctx, `SELECT count(*) FROM orders`).Scan(&left); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if left != 0 {
+		t.Fatalf("expected exported orders deleted, %d left", left)
+	}
+}
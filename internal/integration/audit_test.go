@@ -0,0 +1,101 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+)
+
+func TestRepo_OrderHistory(t *testing.T) {
+	forEachRepo(t, func(t *testing.T, r repo.Repo) {
+		gen := newGenerator(t)
+		partition, offset := 3, int64(42)
+		kafkaCtx := audit.WithSource(context.Background(), model.AuditSource{
+			Kind: model.SourceKafka, Topic: "orders", Partition: &partition, Offset: &offset,
+		})
+		httpCtx := audit.WithSource(context.Background(), model.AuditSource{
+			Kind: model.SourceHTTP, Principal: "ip:127.0.0.1", RequestID: "req-1",
+		})
+
+		ord := gen.Order()
+		if _, err := r.CreateOrder(kafkaCtx, ord); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		// A replay changes nothing and is not recorded.
+		if _, err := r.CreateOrder(kafkaCtx, ord); err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+		updated := *ord
+		updated.TrackNumber = ord.TrackNumber + "-2"
+		if _, err := r.CreateOrder(kafkaCtx, &updated); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		if err := r.DeleteOrder(httpCtx, ord.OrderUID); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+
+		entries, err := r.OrderHistory(context.Background(), ord.OrderUID)
+		if err != nil {
+			t.Fatalf("history: %v", err)
+		}
+		var actions []string
+		for _, e := range entries {
+			actions = append(actions, e.Action)
+		}
+		want := []string{model.AuditCreated, model.AuditUpdated, model.AuditDeleted}
+		if len(actions) != len(want) {
+			t.Fatalf("expected actions %v, got %v", want, actions)
+		}
+		for i := range want {
+			if actions[i] != want[i] {
+				t.Fatalf("expected actions %v, got %v", want, actions)
+			}
+		}
+
+		src := entries[0].Source
+		if src.Kind != model.SourceKafka || src.Topic != "orders" || src.Partition == nil || *src.Partition != 3 ||
+			src.Offset == nil || *src.Offset != 42 {
+			t.Fatalf("unexpected create source %+v", src)
+		}
+		upd := entries[1].Changes
+		if len(upd) != 1 || upd[0].Path != "track_number" || upd[0].After != updated.TrackNumber {
+			t.Fatalf("unexpected update diff %+v", upd)
+		}
+		if src := entries[2].Source; src.Kind != model.SourceHTTP || src.RequestID != "req-1" || src.Principal != "ip:127.0.0.1" {
+			t.Fatalf("unexpected delete source %+v", src)
+		}
+		for _, c := range entries[0].Changes {
+			if c.Path == "delivery.email" && c.After != audit.Redacted {
+				t.Fatalf("expected the email to be redacted, got %v", c.After)
+			}
+		}
+
+		if entries, err := r.OrderHistory(context.Background(), "missing"); err != nil || len(entries) != 0 {
+			t.Fatalf("expected no history, got %d entries, %v", len(entries), err)
+		}
+	})
+}
+
+func TestArchiver_RecordsHistory(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	r := repo.NewRepo(db)
+	ord := newGenerator(t).Order()
+	createAged(t, ctx, r, ord, 48*time.Hour)
+
+	if _, err := repo.NewArchiver(db).ArchiveExpired(ctx, time.Now().Add(-24*time.Hour), 10); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	entries, err := r.OrderHistory(ctx, ord.OrderUID)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	last := entries[len(entries)-1]
+	if last.Action != model.AuditArchived || last.Source.Kind != model.SourceSystem {
+		t.Fatalf("unexpected last entry %+v", last)
+	}
+}
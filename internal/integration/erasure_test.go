@@ -0,0 +1,130 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+)
+
+func TestRepo_EraseCustomer(t *testing.T) {
+	forEachRepo(t, func(t *testing.T, r repo.Repo) {
+		ctx := context.Background()
+		gen := newGenerator(t)
+
+		first, second, other := gen.Order(), gen.Order(), gen.Order()
+		second.CustomerID = first.CustomerID
+		other.CustomerID = first.CustomerID + "-other"
+		for _, o := range []*model.Order{first, second, other} {
+			if _, err := r.CreateOrder(ctx, o); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+		}
+
+		e, err := r.EraseCustomer(ctx, first.CustomerID, "req-1")
+		if err != nil {
+			t.Fatalf("erase: %v", err)
+		}
+		if len(e.OrderUIDs) != 2 {
+			t.Fatalf("expected 2 erased orders, got %v", e.OrderUIDs)
+		}
+
+		for _, o := range []*model.Order{first, second} {
+			got, err := r.GetOrderByID(ctx, o.OrderUID)
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			want := *o
+			want.Delivery.Erase()
+			assertSameOrder(t, &want, got)
+		}
+		got, err := r.GetOrderByID(ctx, other.OrderUID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		assertSameOrder(t, other, got)
+
+		// A replayed message must not restore the erased data, but a new
+		// order placed after the erasure is accepted.
+		if _, err := r.CreateOrder(ctx, first); !errors.Is(err, repo.ErrCustomerErased) {
+			t.Fatalf("expected ErrCustomerErased for a replayed order, got %v", err)
+		}
+		fresh := gen.Order()
+		fresh.CustomerID = first.CustomerID
+		fresh.DateCreated = e.ErasedAt.Add(time.Second)
+		if _, err := r.CreateOrder(ctx, fresh); err != nil {
+			t.Fatalf("expected an order placed after the erasure to be accepted: %v", err)
+		}
+
+		if _, err := r.EraseCustomer(ctx, first.CustomerID, "req-2"); err != nil {
+			t.Fatalf("repeated erase: %v", err)
+		}
+	})
+}
+
+func TestRepo_EraseCustomerAuditAndArchive(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	r := repo.NewRepo(db)
+	gen := newGenerator(t)
+
+	archived, live := gen.Order(), gen.Order()
+	live.CustomerID = archived.CustomerID
+	createAged(t, ctx, r, archived, 48*time.Hour)
+	createAged(t, ctx, r, live, time.Hour)
+	if _, err := repo.NewArchiver(db).ArchiveExpired(ctx, time.Now().Add(-24*time.Hour), 10); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	// An event written while events still carried the delivery, for an
+	// order that is no longer stored.
+	legacy := gen.Order()
+	legacy.CustomerID = archived.CustomerID
+	_, err := db.Exec(ctx, `
+		INSERT INTO outbox (event_id, aggregate_id, event_type, payload, created_at)
+		VALUES (gen_random_uuid(), $1, 'order.created', jsonb_build_object('order', $2::jsonb), now())
+	`, legacy.OrderUID, legacy)
+	if err != nil {
+		t.Fatalf("legacy event: %v", err)
+	}
+
+	if _, err := r.EraseCustomer(ctx, archived.CustomerID, "req-1"); err != nil {
+		t.Fatalf("erase: %v", err)
+	}
+
+	var name, email string
+	err = db.QueryRow(ctx, `SELECT name, email FROM deliveries_archive WHERE order_uid = $1`, archived.OrderUID).Scan(&name, &email)
+	if err != nil {
+		t.Fatalf("archived delivery: %v", err)
+	}
+	if name != model.ErasedValue || email != model.ErasedEmail {
+		t.Fatalf("expected archived delivery erased, got %q %q", name, email)
+	}
+
+	var (
+		requestID string
+		affected  int
+	)
+	err = db.QueryRow(ctx, `SELECT request_id, orders_affected FROM customer_erasures WHERE customer_id = $1`, archived.CustomerID).
+		Scan(&requestID, &affected)
+	if err != nil {
+		t.Fatalf("audit: %v", err)
+	}
+	if requestID != "req-1" || affected != 2 {
+		t.Fatalf("unexpected audit entry: request %q, %d orders", requestID, affected)
+	}
+
+	for _, email := range []string{archived.Delivery.Email, live.Delivery.Email, legacy.Delivery.Email} {
+		var leaked int
+		err = db.QueryRow(ctx, `SELECT count(*) FROM outbox WHERE strpos(payload::text, $1) > 0`, email).Scan(&leaked)
+		if err != nil {
+			t.Fatalf("outbox: %v", err)
+		}
+		if leaked != 0 {
+			t.Fatalf("expected no outbox payloads with %s, got %d", email, leaked)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+)
+
+func TestRepo_ExportOrders(t *testing.T) {
+	forEachRepo(t, func(t *testing.T, r repo.Repo) {
+		ctx := context.Background()
+		gen := newGenerator(t)
+
+		// More orders than one cursor fetch returns, an hour apart.
+		const total = 620
+		var created []*model.Order
+		for i := 0; i < total; i++ {
+			ord := gen.Order()
+			createAged(t, ctx, r, ord, time.Duration(total-i)*time.Hour)
+			created = append(created, ord)
+		}
+		deleted := created[10]
+		if err := r.DeleteOrder(ctx, deleted.OrderUID); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+
+		var got []*model.Order
+		err := r.ExportOrders(ctx, nil, nil, func(o *model.Order) error {
+			got = append(got, o)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("export: %v", err)
+		}
+		if len(got) != total-1 {
+			t.Fatalf("expected %d orders, got %d", total-1, len(got))
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i].DateCreated.Before(got[i-1].DateCreated) {
+				t.Fatalf("orders are not sorted by date_created at %d", i)
+			}
+		}
+		for _, o := range got {
+			if o.OrderUID == deleted.OrderUID {
+				t.Fatal("deleted order was exported")
+			}
+		}
+		assertSameOrder(t, created[0], got[0])
+
+		from, to := created[100].DateCreated, created[200].DateCreated
+		n := 0
+		err = r.ExportOrders(ctx, &from, &to, func(o *model.Order) error {
+			if o.DateCreated.Before(from) || !o.DateCreated.Before(to) {
+				t.Fatalf("order %s created %v is out of range", o.OrderUID, o.DateCreated)
+			}
+			n++
+			return nil
+		})
+		if err != nil || n != 100 {
+			t.Fatalf("expected 100 orders in range, got %d, %v", n, err)
+		}
+
+		stop := errors.New("stop")
+		n = 0
+		err = r.ExportOrders(ctx, nil, nil, func(*model.Order) error {
+			n++
+			if n == 3 {
+				return stop
+			}
+			return nil
+		})
+		if !errors.Is(err, stop) || n != 3 {
+			t.Fatalf("expected the export to stop after 3 orders, got %d, %v", n, err)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package integration
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"orderservice/internal/audit"
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+)
+
+func TestImporter_ImportOrders(t *testing.T) {
+	db := newTestDB(t)
+	ctx := audit.WithSource(context.Background(), model.AuditSource{Kind: model.SourceSystem, Principal: "import"})
+	r := repo.NewRepo(db)
+	gen := newGenerator(t)
+
+	existing, first, second, erased := gen.Order(), gen.Order(), gen.Order(), gen.Order()
+	createAged(t, ctx, r, existing, time.Hour)
+	if _, err := r.EraseCustomer(ctx, erased.CustomerID, "req-1"); err != nil {
+		t.Fatalf("erase: %v", err)
+	}
+	erased.DateCreated = time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	imp := repo.NewImporter(db)
+	res, err := imp.ImportOrders(ctx, []*model.Order{existing, first, second, erased})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	slices.Sort(res.Inserted)
+	want := []string{first.OrderUID, second.OrderUID}
+	slices.Sort(want)
+	if !slices.Equal(res.Inserted, want) {
+		t.Fatalf("expected %v inserted, got %v", want, res.Inserted)
+	}
+	if !slices.Equal(res.Existing, []string{existing.OrderUID}) || !slices.Equal(res.Erased, []string{erased.OrderUID}) {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	got, err := r.GetOrderByID(ctx, first.OrderUID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	assertSameOrder(t, first, got)
+	for _, it := range got.Items {
+		if len(it.StatusHistory) != 1 || it.StatusHistory[0].Status != it.Status {
+			t.Fatalf("expected the history to start with the item status, got %+v", it.StatusHistory)
+		}
+	}
+	entries, err := r.OrderHistory(ctx, first.OrderUID)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Action != model.AuditCreated || entries[0].Source.Principal != "import" || len(entries[0].Changes) == 0 {
+		t.Fatalf("expected a created audit entry by the import, got %+v", entries)
+	}
+
+	// A repeated batch, as after a crash before the checkpoint, changes nothing.
+	res, err = imp.ImportOrders(ctx, []*model.Order{first, second})
+	if err != nil {
+		t.Fatalf("reimport: %v", err)
+	}
+	if len(res.Inserted) != 0 || len(res.Existing) != 2 {
+		t.Fatalf("expected both orders to exist, got %+v", res)
+	}
+}
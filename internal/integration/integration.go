@@ -0,0 +1,5 @@
+// Package integration holds tests that wire the real repo, cache, usecase and
+// consumer pipeline together. Postgres tests run only when PG_TEST_DSN points
+// at a database the tests may create schemas in; everything else runs against
+// the in-memory backends.
+package integration
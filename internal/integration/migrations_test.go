@@ -0,0 +1,159 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"orderservice/internal/infrastructure/migrate"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func tableCount(t *testing.T, db *pgxpool.Pool) int {
+	t.Helper()
+	var n int
+	err := db.QueryRow(context.Background(),
+		`SELECT count(*) FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name <> 'goose_db_version'`,
+	).Scan(&n)
+	if err != nil {
+		t.Fatalf("count tables: %v", err)
+	}
+	return n
+}
+
+func TestMigrations_UpDownUp(t *testing.T) {
+	db := newEmptyTestDB(t)
+	ctx := context.Background()
+
+	m, err := migrate.FromPool(db)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	defer m.Close()
+
+	if err := m.Up(ctx, io.Discard); err != nil {
+		t.Fatalf("first up: %v", err)
+	}
+	latest, err := m.Version(ctx)
+	if err != nil {
+		t.Fatalf("version: %v", err)
+	}
+	tables := tableCount(t, db)
+	if tables == 0 {
+		t.Fatal("expected tables after up")
+	}
+
+	if err := m.Redo(ctx, io.Discard); err != nil {
+		t.Fatalf("redo: %v", err)
+	}
+
+	for v := latest; v > 0; {
+		if err := m.Down(ctx, io.Discard); err != nil {
+			t.Fatalf("down from %d: %v", v, err)
+		}
+		if v, err = m.Version(ctx); err != nil {
+			t.Fatalf("version: %v", err)
+		}
+	}
+	if n := tableCount(t, db); n != 0 {
+		t.Fatalf("expected no tables after rolling everything back, got %d", n)
+	}
+
+	if err := m.Up(ctx, io.Discard); err != nil {
+		t.Fatalf("second up: %v", err)
+	}
+	if v, _ := m.Version(ctx); v != latest {
+		t.Fatalf("expected version %d after second up, got %d", latest, v)
+	}
+	if n := tableCount(t, db); n != tables {
+		t.Fatalf("expected %d tables after second up, got %d", tables, n)
+	}
+}
+
+func TestMigrations_HardenSchemaBackfillsLegacyRows(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	m, err := migrate.FromPool(db)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	defer m.Close()
+
+	// Step back to the loose schema and write rows the old code could produce.
+	const hardenVersion = 20251015120000
+	for {
+		v, err := m.Version(ctx)
+		if err != nil {
+			t.Fatalf("version: %v", err)
+		}
+		if v < hardenVersion {
+			break
+		}
+		if err := m.Down(ctx, io.Discard); err != nil {
+			t.Fatalf("down: %v", err)
+		}
+	}
+	for _, q := range []string{
+		`INSERT INTO orders (order_uid, date_created) VALUES ('legacy', '2024-01-02 03:04:05')`,
+		`INSERT INTO deliveries (order_uid, city) VALUES ('legacy', 'Old'), ('legacy', 'New'), (NULL, 'Orphan')`,
+		`INSERT INTO payments (order_uid, amount) VALUES ('legacy', 1), ('legacy', 2)`,
+		`INSERT INTO items (order_uid, price) VALUES ('legacy', NULL)`,
+	} {
+		if _, err := db.Exec(ctx, q); err != nil {
+			t.Fatalf("seed %q: %v", q, err)
+		}
+	}
+
+	if err := m.Up(ctx, io.Discard); err != nil {
+		t.Fatalf("up over legacy rows: %v", err)
+	}
+
+	var (
+		deliveries, payments int
+		city                 string
+		amount               int64
+	)
+	err = db.QueryRow(ctx, `SELECT count(*), max(city) FROM deliveries`).Scan(&deliveries, &city)
+	if err != nil {
+		t.Fatalf("deliveries: %v", err)
+	}
+	if deliveries != 1 || city != "New" {
+		t.Fatalf("expected only the latest delivery to survive, got %d rows, city %q", deliveries, city)
+	}
+	err = db.QueryRow(ctx, `SELECT count(*), max(amount) FROM payments`).Scan(&payments, &amount)
+	if err != nil {
+		t.Fatalf("payments: %v", err)
+	}
+	if payments != 1 || amount != 2 {
+		t.Fatalf("expected only the latest payment to survive, got %d rows, amount %d", payments, amount)
+	}
+
+	var created time.Time
+	if err := db.QueryRow(ctx, `SELECT date_created FROM orders`).Scan(&created); err != nil {
+		t.Fatalf("orders: %v", err)
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !created.Equal(want) {
+		t.Fatalf("expected date_created %v to be read as UTC, got %v", want, created)
+	}
+
+	// Legacy rows violate the checks, so they stay NOT VALID but still guard
+	// new writes (23514 check_violation, 23505 unique_violation).
+	_, err = db.Exec(ctx, `INSERT INTO orders (order_uid, track_number, entry, locale,
+		internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
+		VALUES ('fresh', ' ', '', '', '', 'c', '', '', 0, now(), '')`)
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23514" {
+		t.Fatalf("expected a check violation for a blank track_number, got %v", err)
+	}
+	_, err = db.Exec(ctx, `INSERT INTO deliveries (order_uid, name, phone, zip, city, address, region, email)
+		VALUES ('legacy', 'n', 'p', '', 'c', 'a', '', 'x@y')`)
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		t.Fatalf("expected a unique violation for a second delivery, got %v", err)
+	}
+}
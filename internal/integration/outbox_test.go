@@ -0,0 +1,149 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"orderservice/internal/infrastructure/outbox"
+	"orderservice/internal/infrastructure/repo"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/segmentio/kafka-go"
+)
+
+type fakePublisher struct {
+	err  error
+	msgs []kafka.Message
+}
+
+func (p *fakePublisher) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.msgs = append(p.msgs, msgs...)
+	return nil
+}
+
+func countOutbox(t *testing.T, db *pgxpool.Pool) (pending, delivered int) {
+	t.Helper()
+	err := db.QueryRow(context.Background(), `SELECT
+		count(*) FILTER (WHERE delivered_at IS NULL),
+		count(*) FILTER (WHERE delivered_at IS NOT NULL)
+		FROM outbox`).Scan(&pending, &delivered)
+	if err != nil {
+		t.Fatalf("count outbox: %v", err)
+	}
+	return pending, delivered
+}
+
+func TestRelay_RelayOnce(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	r := repo.NewRepo(db)
+	gen := newGenerator(t)
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.CreateOrder(ctx, gen.Order()); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	pub := &fakePublisher{err: errors.New("broker down")}
+	relay := outbox.NewRelay(db, pub, 2, time.Second, time.Hour)
+	if _, err := relay.RelayOnce(ctx); err == nil {
+		t.Fatal("expected the publish error")
+	}
+	if pending, delivered := countOutbox(t, db); pending != 3 || delivered != 0 {
+		t.Fatalf("expected a failed publish to leave 3 pending, got %d pending, %d delivered", pending, delivered)
+	}
+
+	pub.err = nil
+	n, err := relay.RelayOnce(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("expected a batch of 2, got %d, %v", n, err)
+	}
+	if pending, delivered := countOutbox(t, db); pending != 1 || delivered != 2 {
+		t.Fatalf("expected 1 pending and 2 delivered, got %d and %d", pending, delivered)
+	}
+	if n, err := relay.RelayOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("expected the last event, got %d, %v", n, err)
+	}
+	if n, err := relay.RelayOnce(ctx); err != nil || n != 0 {
+		t.Fatalf("expected nothing left, got %d, %v", n, err)
+	}
+
+	if len(pub.msgs) != 3 {
+		t.Fatalf("expected 3 published messages, got %d", len(pub.msgs))
+	}
+	for _, m := range pub.msgs {
+		headers := map[string]string{}
+		for _, h := range m.Headers {
+			headers[h.Key] = string(h.Value)
+		}
+		if len(m.Key) == 0 || headers["event-id"] == "" || headers["event-type"] != outbox.EventOrderCreated {
+			t.Fatalf("unexpected message key %q headers %v", m.Key, headers)
+		}
+	}
+}
+
+func TestRelay_Cleanup(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	r := repo.NewRepo(db)
+	gen := newGenerator(t)
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.CreateOrder(ctx, gen.Order()); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	// One old delivered event, one recently delivered, one pending.
+	_, err := db.Exec(ctx, `
+		UPDATE outbox SET delivered_at = CASE
+			WHEN id = (SELECT min(id) FROM outbox) THEN now() - interval '2 hours'
+			ELSE now()
+		END
+		WHERE id < (SELECT max(id) FROM outbox)`)
+	if err != nil {
+		t.Fatalf("mark delivered: %v", err)
+	}
+
+	relay := outbox.NewRelay(db, &fakePublisher{}, 10, time.Second, time.Hour)
+	n, err := relay.Cleanup(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("expected one old delivered event removed, got %d, %v", n, err)
+	}
+	if pending, delivered := countOutbox(t, db); pending != 1 || delivered != 1 {
+		t.Fatalf("expected the pending and the fresh event kept, got %d pending, %d delivered", pending, delivered)
+	}
+}
+
+func TestOutbox_EventsLeaveOutTheDelivery(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	r := repo.NewRepo(db)
+	ord := newGenerator(t).Order()
+	if _, err := r.CreateOrder(ctx, ord); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	var payload []byte
+	if err := db.QueryRow(ctx, `SELECT payload FROM outbox WHERE aggregate_id = $1`, ord.OrderUID).Scan(&payload); err != nil {
+		t.Fatalf("outbox: %v", err)
+	}
+	var ev struct {
+		Order map[string]json.RawMessage `json:"order"`
+	}
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if _, ok := ev.Order["delivery"]; ok {
+		t.Fatalf("expected no delivery in the event, got %s", payload)
+	}
+	if string(ev.Order["customer_id"]) != `"`+ord.CustomerID+`"` || ev.Order["items"] == nil {
+		t.Fatalf("expected the rest of the order in the event, got %s", payload)
+	}
+}
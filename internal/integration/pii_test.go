@@ -0,0 +1,191 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"orderservice/internal/infrastructure/fieldcrypt"
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+)
+
+func newTestCipher(t *testing.T, active string) *fieldcrypt.Cipher {
+	t.Helper()
+	keys := map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 32),
+	}
+	c, err := fieldcrypt.New(keys, active, bytes.Repeat([]byte{9}, 32))
+	if err != nil {
+		t.Fatalf("cipher: %v", err)
+	}
+	return c
+}
+
+func TestRepo_EncryptedDelivery(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	r := repo.NewRepo(db, repo.WithEncryption(newTestCipher(t, "k1"), repo.PIIFields))
+	gen := newGenerator(t)
+
+	ord := gen.Order()
+	if _, err := r.CreateOrder(ctx, ord); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	var name, phone, email, keyID string
+	err := db.QueryRow(ctx, `SELECT name, phone, email, pii_key_id FROM deliveries WHERE order_uid = $1`, ord.OrderUID).
+		Scan(&name, &phone, &email, &keyID)
+	if err != nil {
+		t.Fatalf("raw delivery: %v", err)
+	}
+	for _, v := range []string{name, phone, email} {
+		if !fieldcrypt.IsEncrypted(v) {
+			t.Fatalf("expected ciphertext at rest, got %q", v)
+		}
+	}
+	if keyID != "k1" {
+		t.Fatalf("expected key k1, got %q", keyID)
+	}
+
+	got, err := r.GetOrderByID(ctx, ord.OrderUID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	assertSameOrder(t, ord, got)
+
+	found, err := r.FindOrdersByContact(ctx, strings.ToUpper(ord.Delivery.Email), "")
+	if err != nil {
+		t.Fatalf("find by email: %v", err)
+	}
+	if len(found) != 1 || found[0].OrderUID != ord.OrderUID {
+		t.Fatalf("expected to find the order by email, got %d orders", len(found))
+	}
+	found, err = r.FindOrdersByContact(ctx, "", ord.Delivery.Phone)
+	if err != nil {
+		t.Fatalf("find by phone: %v", err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("expected to find the order by phone, got %d orders", len(found))
+	}
+
+	if _, err := repo.NewRepo(db).GetOrderByID(ctx, ord.OrderUID); err == nil {
+		t.Fatal("expected reading encrypted rows without keys to fail")
+	}
+}
+
+func TestRepo_PlaintextRowsWithEncryption(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	gen := newGenerator(t)
+
+	old := gen.Order()
+	if _, err := repo.NewRepo(db).CreateOrder(ctx, old); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	opt := repo.WithEncryption(newTestCipher(t, "k1"), repo.PIIFields)
+	r := repo.NewRepo(db, opt)
+	got, err := r.GetOrderByID(ctx, old.OrderUID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	assertSameOrder(t, old, got)
+
+	found, err := r.FindOrdersByContact(ctx, old.Delivery.Email, "")
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("expected to find the plaintext order, got %d orders", len(found))
+	}
+
+	k := repo.NewKeyMaintainer(db, opt)
+	if n, err := k.Encrypt(ctx, 100); err != nil || n != 1 {
+		t.Fatalf("encrypt: %d rows, %v", n, err)
+	}
+	var email string
+	if err := db.QueryRow(ctx, `SELECT email FROM deliveries WHERE order_uid = $1`, old.OrderUID).Scan(&email); err != nil {
+		t.Fatalf("raw delivery: %v", err)
+	}
+	if !fieldcrypt.IsEncrypted(email) {
+		t.Fatalf("expected the backfill to encrypt the row, got %q", email)
+	}
+	got, err = r.GetOrderByID(ctx, old.OrderUID)
+	if err != nil {
+		t.Fatalf("get after backfill: %v", err)
+	}
+	assertSameOrder(t, old, got)
+
+	if n, err := k.Decrypt(ctx, 100); err != nil || n != 1 {
+		t.Fatalf("decrypt: %d rows, %v", n, err)
+	}
+	got, err = repo.NewRepo(db).GetOrderByID(ctx, old.OrderUID)
+	if err != nil {
+		t.Fatalf("get after decrypt: %v", err)
+	}
+	assertSameOrder(t, old, got)
+}
+
+func TestRepo_RejectsReservedPrefix(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	gen := newGenerator(t)
+
+	for _, r := range []repo.Repo{
+		repo.NewRepo(db),
+		repo.NewRepo(db, repo.WithEncryption(newTestCipher(t, "k1"), []string{"email"})),
+	} {
+		ord := gen.Order()
+		ord.Delivery.Region = "enc:x"
+		if _, err := r.CreateOrder(ctx, ord); !errors.Is(err, repo.ErrReservedPrefix) {
+			t.Fatalf("expected ErrReservedPrefix, got %v", err)
+		}
+		if _, err := r.GetOrderByID(ctx, ord.OrderUID); err == nil {
+			t.Fatal("expected the rejected order not to be stored")
+		}
+	}
+}
+
+func TestKeyMaintainer_Rewrap(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	gen := newGenerator(t)
+
+	orders := []*model.Order{gen.Order(), gen.Order()}
+	r := repo.NewRepo(db, repo.WithEncryption(newTestCipher(t, "k1"), repo.PIIFields))
+	for _, o := range orders {
+		if _, err := r.CreateOrder(ctx, o); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	opt := repo.WithEncryption(newTestCipher(t, "k2"), repo.PIIFields)
+	k := repo.NewKeyMaintainer(db, opt)
+	if n, err := k.Rewrap(ctx, 1); err != nil || n != 1 {
+		t.Fatalf("first batch: %d rows, %v", n, err)
+	}
+	if n, err := k.Rewrap(ctx, 10); err != nil || n != 1 {
+		t.Fatalf("second batch: %d rows, %v", n, err)
+	}
+	if n, err := k.Rewrap(ctx, 10); err != nil || n != 0 {
+		t.Fatalf("expected nothing left, got %d rows, %v", n, err)
+	}
+
+	// Once every row uses k2, k1 can be dropped from the configuration.
+	only, err := fieldcrypt.New(map[string][]byte{"k2": bytes.Repeat([]byte{2}, 32)}, "k2", bytes.Repeat([]byte{9}, 32))
+	if err != nil {
+		t.Fatalf("cipher: %v", err)
+	}
+	r = repo.NewRepo(db, repo.WithEncryption(only, repo.PIIFields))
+	for _, o := range orders {
+		got, err := r.GetOrderByID(ctx, o.OrderUID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		assertSameOrder(t, o, got)
+	}
+}
@@ -0,0 +1,80 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"orderservice/internal/codec"
+	ctrlkafka "orderservice/internal/controller/kafkacontroller"
+	"orderservice/internal/infrastructure/cache"
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/usecase"
+	"orderservice/pkg/consumer"
+)
+
+// TestPipeline_IngestToRead pushes payloads through the consumer controller
+// into the real usecase, cache and repo, then reads them back.
+func TestPipeline_IngestToRead(t *testing.T) {
+	forEachRepo(t, func(t *testing.T, r repo.Repo) {
+		ctx := context.Background()
+		c := cache.NewCacheWithTTL(50 * time.Millisecond)
+		defer c.Close()
+		u := usecase.NewOrderUsecase(r, c)
+
+		source := consumer.NewChannelConsumer(8)
+		ctrl := ctrlkafka.NewKafkaController(u, source, codec.NewRegistry(codec.NewStrictJSON()))
+		if err := ctrl.Start(ctx); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+		defer ctrl.Stop(ctx)
+
+		gen := newGenerator(t)
+		valid := gen.Order()
+		invalid := gen.Order()
+		invalid.Delivery.Email = "not-an-email"
+
+		for _, ord := range []any{invalid, valid} {
+			data, err := json.Marshal(ord)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if err := source.Publish(ctx, consumer.Message{Value: data}); err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+		}
+		if err := source.Publish(ctx, consumer.Message{Value: []byte(`{"order_uid":`)}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if _, ok := c.Get(valid.OrderUID); ok {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("valid order never reached the cache")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		if _, err := r.GetOrderByID(ctx, invalid.OrderUID); err == nil {
+			t.Fatal("invalid order must not be stored")
+		}
+
+		// Once the cache entry expires the usecase reads through to the repo.
+		time.Sleep(80 * time.Millisecond)
+		if _, ok := c.Get(valid.OrderUID); ok {
+			t.Fatal("expected cache entry to expire")
+		}
+		got, err := u.GetOrder(ctx, valid.OrderUID)
+		if err != nil {
+			t.Fatalf("get order: %v", err)
+		}
+		assertSameOrder(t, valid, got)
+		if _, ok := c.Get(valid.OrderUID); !ok {
+			t.Fatal("expected read-through to repopulate the cache")
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// getOrderSequential is the former GetOrderByID: one query per table, outside
+// a transaction. It is kept here as the baseline for BenchmarkGetOrderByID.
+func getOrderSequential(ctx context.Context, db *pgxpool.Pool, id string) (*model.Order, error) {
+	ord := &model.Order{}
+	err := db.QueryRow(ctx, `
+		SELECT order_uid, track_number, entry, locale, internal_signature,
+			   customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+		FROM orders WHERE order_uid = $1`, id,
+	).Scan(&ord.OrderUID, &ord.TrackNumber, &ord.Entry, &ord.Locale, &ord.InternalSignature,
+		&ord.CustomerID, &ord.DeliveryService, &ord.ShardKey, &ord.SMID, &ord.DateCreated, &ord.OOFShard)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &ord.Delivery
+	err = db.QueryRow(ctx, `
+		SELECT name, phone, zip, city, address, region, email
+		FROM deliveries WHERE order_uid = $1`, id,
+	).Scan(&d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ord.Payment
+	err = db.QueryRow(ctx, `
+		SELECT transaction, request_id, currency, provider, amount,
+			   payment_dt, bank, delivery_cost, goods_total, custom_fee
+		FROM payments WHERE order_uid = $1`, id,
+	).Scan(&p.Transaction, &p.RequestID, &p.Currency, &p.Provider, &p.Amount,
+		&p.PaymentDt, &p.Bank, &p.DeliveryCost, &p.GoodsTotal, &p.CustomFee)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT chrt_id, track_number, price, rid, name,
+			   sale, size, total_price, nm_id, brand, status
+		FROM items WHERE order_uid = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it model.Item
+		err = rows.Scan(&it.ChrtID, &it.TrackNumber, &it.Price, &it.RID, &it.Name,
+			&it.Sale, &it.Size, &it.TotalPrice, &it.NMID, &it.Brand, &it.Status)
+		if err != nil {
+			return nil, err
+		}
+		ord.Items = append(ord.Items, it)
+	}
+	return ord, rows.Err()
+}
+
+// BenchmarkGetOrderByID compares the single-statement fetch with the former
+// four-query one against PG_TEST_DSN:
+//
+//	PG_TEST_DSN=... go test ./internal/integration -run '^$' -bench GetOrderByID
+func BenchmarkGetOrderByID(b *testing.B) {
+	db := newTestDB(b)
+	ctx := context.Background()
+	r := repo.NewRepo(db)
+
+	gen := newGenerator(b)
+	ids := make([]string, 100)
+	for i := range ids {
+		ord := gen.Order()
+		if _, err := r.CreateOrder(ctx, ord); err != nil {
+			b.Fatalf("create: %v", err)
+		}
+		ids[i] = ord.OrderUID
+	}
+
+	b.Run("single-query", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := r.GetOrderByID(ctx, ids[i%len(ids)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("four-queries", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := getOrderSequential(ctx, db, ids[i%len(ids)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGetAllOrders(b *testing.B) {
+	db := newTestDB(b)
+	ctx := context.Background()
+	r := repo.NewRepo(db)
+
+	gen := newGenerator(b)
+	for i := 0; i < 1000; i++ {
+		if _, err := r.CreateOrder(ctx, gen.Order()); err != nil {
+			b.Fatalf("create: %v", err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.GetAllOrders(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"orderservice/internal/infrastructure/outbox"
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+	"orderservice/pkg/generator"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func newGenerator(t testing.TB) *generator.Generator {
+	t.Helper()
+	opts := generator.DefaultOptions()
+	opts.Seed = 42
+	gen, err := generator.New(opts)
+	if err != nil {
+		t.Fatalf("generator: %v", err)
+	}
+	return gen
+}
+
+// forEachRepo runs fn against the in-memory repo and, when PG_TEST_DSN is
+// set, against the Postgres one, so both backends keep the same behavior.
+func forEachRepo(t *testing.T, fn func(t *testing.T, r repo.Repo)) {
+	t.Run("memory", func(t *testing.T) {
+		fn(t, repo.NewMemoryRepo())
+	})
+	t.Run("postgres", func(t *testing.T) {
+		fn(t, repo.NewRepo(newTestDB(t)))
+	})
+}
+
+func assertSameOrder(t *testing.T, want, got *model.Order) {
+	t.Helper()
+	w, g := normalize(want), normalize(got)
+	if !reflect.DeepEqual(&w, &g) {
+		t.Fatalf("order mismatch:\nwant %+v\ngot  %+v", w, g)
+	}
+}
+
+// normalize makes orders comparable: Postgres returns items in no particular
+// order and times in UTC.
+func normalize(ord *model.Order) model.Order {
+	o := *ord
+	o.DateCreated = o.DateCreated.UTC()
+	// The aggregate status and status history are set by the service and
+	// checked by the status tests.
+	o.Status = ""
+	o.Items = append([]model.Item(nil), ord.Items...)
+	for i := range o.Items {
+		o.Items[i].StatusHistory = nil
+	}
+	sort.Slice(o.Items, func(i, j int) bool { return o.Items[i].RID < o.Items[j].RID })
+	return o
+}
+
+func TestRepo_CreateAndGet(t *testing.T) {
+	forEachRepo(t, func(t *testing.T, r repo.Repo) {
+		ctx := context.Background()
+		ord := newGenerator(t).Order()
+
+		id, err := r.CreateOrder(ctx, ord)
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if id != ord.OrderUID {
+			t.Fatalf("expected id %q, got %q", ord.OrderUID, id)
+		}
+
+		got, err := r.GetOrderByID(ctx, ord.OrderUID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		assertSameOrder(t, ord, got)
+	})
+}
+
+func TestRepo_GetOrderByIDNotFound(t *testing.T) {
+	forEachRepo(t, func(t *testing.T, r repo.Repo) {
+		_, err := r.GetOrderByID(context.Background(), "missing")
+		if !errors.Is(err, pgx.ErrNoRows) {
+			t.Fatalf("expected pgx.ErrNoRows, got %v", err)
+		}
+	})
+}
+
+func TestRepo_UpsertReplacesChildren(t *testing.T) {
+	forEachRepo(t, func(t *testing.T, r repo.Repo) {
+		ctx := context.Background()
+		gen := newGenerator(t)
+		ord := gen.Order()
+		ord.Items = append(ord.Items, gen.Order().Items...)
+		if _, err := r.CreateOrder(ctx, ord); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+
+		updated := *ord
+		updated.Items = ord.Items[:1]
+		updated.Delivery.City = "Kazan"
+		if _, err := r.CreateOrder(ctx, &updated); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+
+		got, err := r.GetOrderByID(ctx, ord.OrderUID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		assertSameOrder(t, &updated, got)
+	})
+}
+
+func TestRepo_GetAllOrders(t *testing.T) {
+	forEachRepo(t, func(t *testing.T, r repo.Repo) {
+		ctx := context.Background()
+		gen := newGenerator(t)
+
+		want := map[string]*model.Order{}
+		for i := 0; i < 5; i++ {
+			ord := gen.Order()
+			want[ord.OrderUID] = ord
+			if _, err := r.CreateOrder(ctx, ord); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+		}
+
+		all, err := r.GetAllOrders(ctx)
+		if err != nil {
+			t.Fatalf("get all: %v", err)
+		}
+		if len(all) != len(want) {
+			t.Fatalf("expected %d orders, got %d", len(want), len(all))
+		}
+		for _, got := range all {
+			w, ok := want[got.OrderUID]
+			if !ok {
+				t.Fatalf("unexpected order %q", got.OrderUID)
+			}
+			assertSameOrder(t, w, got)
+		}
+	})
+}
+
+func TestRepo_DeleteOrder(t *testing.T) {
+	forEachRepo(t, func(t *testing.T, r repo.Repo) {
+		ctx := context.Background()
+		gen := newGenerator(t)
+		ord, kept := gen.Order(), gen.Order()
+		for _, o := range []*model.Order{ord, kept} {
+			if _, err := r.CreateOrder(ctx, o); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+		}
+
+		if err := r.DeleteOrder(ctx, ord.OrderUID); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if _, err := r.GetOrderByID(ctx, ord.OrderUID); !errors.Is(err, pgx.ErrNoRows) {
+			t.Fatalf("expected deleted order to be hidden, got %v", err)
+		}
+		if err := r.DeleteOrder(ctx, ord.OrderUID); !errors.Is(err, pgx.ErrNoRows) {
+			t.Fatalf("expected pgx.ErrNoRows on second delete, got %v", err)
+		}
+		if err := r.DeleteOrder(ctx, "missing"); !errors.Is(err, pgx.ErrNoRows) {
+			t.Fatalf("expected pgx.ErrNoRows for a missing order, got %v", err)
+		}
+		if _, err := r.CreateOrder(ctx, ord); !errors.Is(err, repo.ErrOrderDeleted) {
+			t.Fatalf("expected ErrOrderDeleted when writing a deleted order, got %v", err)
+		}
+
+		all, err := r.GetAllOrders(ctx)
+		if err != nil {
+			t.Fatalf("get all: %v", err)
+		}
+		if len(all) != 1 || all[0].OrderUID != kept.OrderUID {
+			t.Fatalf("expected only %s after delete, got %d orders", kept.OrderUID, len(all))
+		}
+	})
+}
+
+func TestRepo_WritesOutboxEventsInTransaction(t *testing.T) {
+	db := newTestDB(t)
+	r := repo.NewRepo(db)
+	ctx := context.Background()
+	ord := newGenerator(t).Order()
+
+	if _, err := r.CreateOrder(ctx, ord); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := r.CreateOrder(ctx, ord); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := r.DeleteOrder(ctx, ord.OrderUID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	rows, err := db.Query(ctx, `SELECT event_type FROM outbox WHERE aggregate_id = $1 ORDER BY id`, ord.OrderUID)
+	if err != nil {
+		t.Fatalf("query outbox: %v", err)
+	}
+	types, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		t.Fatalf("scan outbox: %v", err)
+	}
+	want := []string{outbox.EventOrderCreated, outbox.EventOrderUpdated, outbox.EventOrderDeleted}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("expected events %v, got %v", want, types)
+	}
+}
@@ -0,0 +1,82 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/reporting"
+)
+
+func TestReports_PostgresMatchesAggregate(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	r := repo.NewRepo(db)
+	gen := newGenerator(t)
+	for i := 0; i < 50; i++ {
+		if _, err := r.CreateOrder(ctx, gen.Order()); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	deleted := gen.Order()
+	if _, err := r.CreateOrder(ctx, deleted); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := r.DeleteOrder(ctx, deleted.OrderUID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	orders, err := r.GetAllOrders(ctx)
+	if err != nil {
+		t.Fatalf("get all: %v", err)
+	}
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+	from, to := now.AddDate(0, 0, -30), now.Add(-90*time.Minute)
+	pg := reporting.NewPostgres(db, reporting.PostgresOptions{Views: true})
+
+	check := func(t *testing.T, q reporting.Query, source string) {
+		t.Helper()
+		rep, err := pg.OrderReport(ctx, q)
+		if err != nil {
+			t.Fatalf("report: %v", err)
+		}
+		if rep.Source != source {
+			t.Fatalf("expected source %s, got %s", source, rep.Source)
+		}
+		want := reporting.Aggregate(orders, q)
+		if (len(want) > 0 || len(rep.Rows) > 0) && !reflect.DeepEqual(rep.Rows, want) {
+			t.Fatalf("%s by %s:\nwant %+v\ngot  %+v", source, q.GroupBy, want, rep.Rows)
+		}
+	}
+
+	groups := []reporting.GroupBy{reporting.GroupByDay, reporting.GroupByBrand, reporting.GroupByDeliveryService, reporting.GroupByRegion}
+	// The views are created empty, so reports read the live tables until
+	// the first refresh.
+	for _, g := range groups {
+		check(t, reporting.Query{GroupBy: g}, reporting.SourceLive)
+		check(t, reporting.Query{GroupBy: g, From: &from, To: &to, Limit: 3}, reporting.SourceLive)
+	}
+
+	refresher := reporting.NewRefresher(db, time.Hour)
+	for i := 0; i < 2; i++ {
+		if ok, err := refresher.RunOnce(ctx); err != nil || !ok {
+			t.Fatalf("refresh: %v, %v", ok, err)
+		}
+	}
+	to = now
+	for _, g := range groups {
+		check(t, reporting.Query{GroupBy: g}, reporting.SourceViews)
+		check(t, reporting.Query{GroupBy: g, From: &from, To: &to}, reporting.SourceViews)
+	}
+	// Bounds inside a day cannot be answered from daily aggregates.
+	to = now.Add(-90 * time.Minute)
+	check(t, reporting.Query{GroupBy: reporting.GroupByDay, To: &to}, reporting.SourceLive)
+
+	encrypted := reporting.NewPostgres(db, reporting.PostgresOptions{Encrypted: []string{"region"}})
+	if _, err := encrypted.OrderReport(ctx, reporting.Query{GroupBy: reporting.GroupByRegion}); !errors.Is(err, reporting.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"orderservice/internal/infrastructure/repo"
+	"orderservice/internal/model"
+)
+
+func TestRepo_SetItemStatus(t *testing.T) {
+	forEachRepo(t, func(t *testing.T, r repo.Repo) {
+		ctx := context.Background()
+		ord := newGenerator(t).Order()
+		if _, err := r.CreateOrder(ctx, ord); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		rid := ord.Items[0].RID
+		start := time.Now().UTC().Truncate(time.Second)
+
+		for i, s := range []model.ItemStatus{model.ItemAssembled, model.ItemShipped, model.ItemShipped} {
+			at := start.Add(time.Duration(i) * time.Minute)
+			got, err := r.SetItemStatus(ctx, model.StatusUpdate{OrderUID: ord.OrderUID, RID: rid, Status: s, At: at})
+			if err != nil {
+				t.Fatalf("set %s: %v", s, err)
+			}
+			if got.Items[0].Status != s {
+				t.Fatalf("expected status %s, got %s", s, got.Items[0].Status)
+			}
+		}
+
+		_, err := r.SetItemStatus(ctx, model.StatusUpdate{OrderUID: ord.OrderUID, RID: rid, Status: model.ItemAccepted, At: start})
+		if !errors.Is(err, model.ErrInvalidTransition) {
+			t.Fatalf("expected ErrInvalidTransition, got %v", err)
+		}
+		_, err = r.SetItemStatus(ctx, model.StatusUpdate{OrderUID: ord.OrderUID, RID: "missing", Status: model.ItemShipped, At: start})
+		if !errors.Is(err, repo.ErrItemNotFound) {
+			t.Fatalf("expected ErrItemNotFound, got %v", err)
+		}
+
+		// An upsert of the order keeps the status the item reached.
+		if _, err := r.CreateOrder(ctx, ord); err != nil {
+			t.Fatalf("upsert: %v", err)
+		}
+		got, err := r.GetOrderByID(ctx, ord.OrderUID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		var item model.Item
+		for _, it := range got.Items {
+			if it.RID == rid {
+				item = it
+			}
+		}
+		if item.Status != model.ItemShipped {
+			t.Fatalf("expected the upsert to keep %s, got %s", model.ItemShipped, item.Status)
+		}
+		want := []model.ItemStatus{model.ItemAccepted, model.ItemAssembled, model.ItemShipped}
+		if len(item.StatusHistory) != len(want) {
+			t.Fatalf("expected history %v, got %+v", want, item.StatusHistory)
+		}
+		for i, c := range item.StatusHistory {
+			if c.Status != want[i] {
+				t.Fatalf("expected history %v, got %+v", want, item.StatusHistory)
+			}
+		}
+		if at := item.StatusHistory[2].At; !at.Equal(start.Add(time.Minute)) {
+			t.Fatalf("expected shipped at %v, got %v", start.Add(time.Minute), at)
+		}
+
+		entries, err := r.OrderHistory(ctx, ord.OrderUID)
+		if err != nil {
+			t.Fatalf("history: %v", err)
+		}
+		if n := len(entries); n != 3 || entries[2].Action != model.AuditUpdated {
+			t.Fatalf("expected created and two updated entries, got %d", n)
+		}
+
+		if err := r.DeleteOrder(ctx, ord.OrderUID); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		_, err = r.SetItemStatus(ctx, model.StatusUpdate{OrderUID: ord.OrderUID, RID: rid, Status: model.ItemDelivered, At: start})
+		if !errors.Is(err, repo.ErrOrderDeleted) {
+			t.Fatalf("expected ErrOrderDeleted, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultStopTimeout = 5 * time.Second
+
+// Component is a unit managed by Manager. Start must not block past
+// initialization; Stop must release everything Start acquired. Either may be nil.
+type Component struct {
+	Name        string
+	Start       func(ctx context.Context) error
+	Stop        func(ctx context.Context) error
+	StopTimeout time.Duration
+}
+
+type Manager struct {
+	logger     *zap.Logger
+	components []Component
+	started    int
+}
+
+func NewManager(logger *zap.Logger) *Manager {
+	return &Manager{logger: logger}
+}
+
+// Add registers a component. Components start in the order they were added
+// and stop in reverse, so dependencies must be added before their dependants.
+func (m *Manager) Add(c Component) {
+	m.components = append(m.components, c)
+}
+
+func (m *Manager) Start(ctx context.Context) error {
+	for _, c := range m.components {
+		if c.Start != nil {
+			if err := c.Start(ctx); err != nil {
+				m.logger.Error("component failed to start", zap.String("component", c.Name), zap.Error(err))
+				stopErr := m.Stop(context.Background())
+				return errors.Join(fmt.Errorf("lifecycle: start %s: %w", c.Name, err), stopErr)
+			}
+		}
+		m.started++
+		m.logger.Info("component started", zap.String("component", c.Name))
+	}
+	return nil
+}
+
+// Stop stops started components in reverse order. Each component gets its own
+// timeout; a component that does not finish in time is reported and skipped.
+func (m *Manager) Stop(ctx context.Context) error {
+	var errs []error
+	for i := m.started - 1; i >= 0; i-- {
+		c := m.components[i]
+		if c.Stop == nil {
+			continue
+		}
+
+		if err := m.stopOne(ctx, c); err != nil {
+			m.logger.Error("component failed to stop", zap.String("component", c.Name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("lifecycle: stop %s: %w", c.Name, err))
+			continue
+		}
+		m.logger.Info("component stopped", zap.String("component", c.Name))
+	}
+	m.started = 0
+	return errors.Join(errs...)
+}
+
+func (m *Manager) stopOne(ctx context.Context, c Component) error {
+	timeout := c.StopTimeout
+	if timeout <= 0 {
+		timeout = defaultStopTimeout
+	}
+	stopCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Stop(stopCtx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-stopCtx.Done():
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
@@ -0,0 +1,73 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestManager_StopsInReverseOrder(t *testing.T) {
+	var events []string
+	comp := func(name string) Component {
+		return Component{
+			Name:  name,
+			Start: func(ctx context.Context) error { events = append(events, "start "+name); return nil },
+			Stop:  func(ctx context.Context) error { events = append(events, "stop "+name); return nil },
+		}
+	}
+
+	m := NewManager(zap.NewNop())
+	m.Add(comp("db"))
+	m.Add(comp("cache"))
+	m.Add(comp("http"))
+
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("unexpected start error: %v", err)
+	}
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatalf("unexpected stop error: %v", err)
+	}
+
+	want := []string{"start db", "start cache", "start http", "stop http", "stop cache", "stop db"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("unexpected order: %v", events)
+	}
+}
+
+func TestManager_StartFailureStopsStartedComponents(t *testing.T) {
+	var stopped []string
+	m := NewManager(zap.NewNop())
+	m.Add(Component{Name: "db", Stop: func(ctx context.Context) error { stopped = append(stopped, "db"); return nil }})
+	m.Add(Component{Name: "kafka", Start: func(ctx context.Context) error { return errors.New("boom") }})
+
+	if err := m.Start(context.Background()); err == nil {
+		t.Fatalf("expected start error")
+	}
+	if !reflect.DeepEqual(stopped, []string{"db"}) {
+		t.Fatalf("expected db to be stopped, got %v", stopped)
+	}
+}
+
+func TestManager_StopTimeoutIsReported(t *testing.T) {
+	m := NewManager(zap.NewNop())
+	m.Add(Component{
+		Name:        "slow",
+		StopTimeout: 10 * time.Millisecond,
+		Stop: func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		},
+	})
+	m.Add(Component{Name: "fast", Stop: func(ctx context.Context) error { return nil }})
+
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("unexpected start error: %v", err)
+	}
+	if err := m.Stop(context.Background()); err == nil {
+		t.Fatalf("expected timeout error")
+	}
+}
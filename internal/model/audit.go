@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+const (
+	AuditCreated  = "created"
+	AuditUpdated  = "updated"
+	AuditDeleted  = "deleted"
+	AuditErased   = "erased"
+	AuditArchived = "archived"
+	AuditExported = "exported"
+)
+
+const (
+	SourceKafka  = "kafka"
+	SourceHTTP   = "http"
+	SourceSystem = "system"
+)
+
+// AuditSource tells who or what changed an order: a Kafka message, an HTTP
+// request or a background job of the service itself.
+type AuditSource struct {
+	Kind      string `json:"kind"`
+	Principal string `json:"principal,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Topic     string `json:"topic,omitempty"`
+	Partition *int   `json:"partition,omitempty"`
+	Offset    *int64 `json:"offset,omitempty"`
+}
+
+// AuditChange is one changed leaf of the order JSON, e.g. "items[0].price".
+// Before is absent for added values and After for removed ones.
+type AuditChange struct {
+	Path   string `json:"path"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+type AuditEntry struct {
+	ID       int64         `json:"id"`
+	OrderUID string        `json:"order_uid"`
+	Action   string        `json:"action"`
+	Source   AuditSource   `json:"source"`
+	Changes  []AuditChange `json:"changes"`
+	At       time.Time     `json:"at"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// Placeholders written over the personal data of erased customers. They
+// still pass Validate.
+const (
+	ErasedValue = "erased"
+	ErasedEmail = "erased@erased.invalid"
+)
+
+// Erasure is the outcome of erasing a customer's personal data.
+type Erasure struct {
+	CustomerID string
+	// OrderUIDs lists the live and soft deleted orders that were anonymized.
+	OrderUIDs []string
+	ErasedAt  time.Time
+}
+
+// Erase replaces the personal fields of the delivery with placeholders.
+func (d *Delivery) Erase() {
+	d.Name = ErasedValue
+	d.Phone = ErasedValue
+	d.Address = ErasedValue
+	d.Email = ErasedEmail
+}
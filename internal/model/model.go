@@ -22,6 +22,8 @@ type Order struct {
 	SMID              int       `json:"sm_id"`
 	DateCreated       time.Time `json:"date_created"`
 	OOFShard          string    `json:"oof_shard"`
+	// Status is derived from the item statuses on reads, see AggregateStatus.
+	Status string `json:"status,omitempty"`
 }
 
 type Delivery struct {
@@ -39,26 +41,28 @@ type Payment struct {
 	RequestID    string `json:"request_id"`
 	Currency     string `json:"currency"`
 	Provider     string `json:"provider"`
-	Amount       int    `json:"amount"`
-	PaymentDt    int    `json:"payment_dt"`
+	Amount       int64  `json:"amount"`
+	PaymentDt    int64  `json:"payment_dt"`
 	Bank         string `json:"bank"`
-	DeliveryCost int    `json:"delivery_cost"`
-	GoodsTotal   int    `json:"goods_total"`
-	CustomFee    int    `json:"custom_fee"`
+	DeliveryCost int64  `json:"delivery_cost"`
+	GoodsTotal   int64  `json:"goods_total"`
+	CustomFee    int64  `json:"custom_fee"`
 }
 
 type Item struct {
-	ChrtID      int    `json:"chrt_id"`
-	TrackNumber string `json:"track_number"`
-	Price       int    `json:"price"`
-	RID         string `json:"rid"`
-	Name        string `json:"name"`
-	Sale        int    `json:"sale"`
-	Size        string `json:"size"`
-	TotalPrice  int    `json:"total_price"`
-	NMID        int    `json:"nm_id"`
-	Brand       string `json:"brand"`
-	Status      int    `json:"status"`
+	ChrtID      int64      `json:"chrt_id"`
+	TrackNumber string     `json:"track_number"`
+	Price       int64      `json:"price"`
+	RID         string     `json:"rid"`
+	Name        string     `json:"name"`
+	Sale        int64      `json:"sale"`
+	Size        string     `json:"size"`
+	TotalPrice  int64      `json:"total_price"`
+	NMID        int64      `json:"nm_id"`
+	Brand       string     `json:"brand"`
+	Status      ItemStatus `json:"status"`
+	// StatusHistory is filled in on reads; it is ignored on ingest.
+	StatusHistory []StatusChange `json:"status_history,omitempty"`
 }
 
 func (o *Order) Validate() error {
@@ -99,10 +103,15 @@ func (o *Order) Validate() error {
 	if len(o.Items) == 0 {
 		return errors.New("order: must contain at least one item")
 	}
+	rids := make(map[string]bool, len(o.Items))
 	for i, item := range o.Items {
 		if err := item.Validate(); err != nil {
 			return fmt.Errorf("order: invalid item[%d]: %w", i, err)
 		}
+		if rids[item.RID] {
+			return fmt.Errorf("order: duplicate item rid %q", item.RID)
+		}
+		rids[item.RID] = true
 	}
 
 	return nil
@@ -166,5 +175,8 @@ func (i *Item) Validate() error {
 	if strings.TrimSpace(i.Brand) == "" {
 		return errors.New("item: brand is empty")
 	}
+	if !i.Status.Valid() {
+		return fmt.Errorf("item: unknown status %d", i.Status)
+	}
 	return nil
 }
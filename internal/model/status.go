@@ -0,0 +1,166 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ItemStatus is the lifecycle stage of an item. The codes are kept numeric on
+// the wire; 202 is what producers have always sent for new items.
+type ItemStatus int
+
+const (
+	ItemAccepted  ItemStatus = 202
+	ItemAssembled ItemStatus = 203
+	ItemShipped   ItemStatus = 204
+	ItemDelivered ItemStatus = 205
+	ItemReceived  ItemStatus = 206
+	ItemReturned  ItemStatus = 207
+	ItemCancelled ItemStatus = 208
+)
+
+var ErrInvalidTransition = errors.New("invalid item status transition")
+
+var itemStatusNames = map[ItemStatus]string{
+	ItemAccepted:  "accepted",
+	ItemAssembled: "assembled",
+	ItemShipped:   "shipped",
+	ItemDelivered: "delivered",
+	ItemReceived:  "received",
+	ItemReturned:  "returned",
+	ItemCancelled: "cancelled",
+}
+
+// itemTransitions lists the statuses each status may move to. Returned and
+// cancelled are final.
+var itemTransitions = map[ItemStatus][]ItemStatus{
+	ItemAccepted:  {ItemAssembled, ItemCancelled},
+	ItemAssembled: {ItemShipped, ItemCancelled},
+	ItemShipped:   {ItemDelivered, ItemReturned},
+	ItemDelivered: {ItemReceived, ItemReturned},
+	ItemReceived:  {ItemReturned},
+}
+
+// ItemStatuses returns all known statuses in lifecycle order.
+func ItemStatuses() []ItemStatus {
+	return []ItemStatus{ItemAccepted, ItemAssembled, ItemShipped, ItemDelivered, ItemReceived, ItemReturned, ItemCancelled}
+}
+
+func (s ItemStatus) Valid() bool {
+	_, ok := itemStatusNames[s]
+	return ok
+}
+
+func (s ItemStatus) String() string {
+	if name, ok := itemStatusNames[s]; ok {
+		return name
+	}
+	return strconv.Itoa(int(s))
+}
+
+// ParseItemStatus accepts a status name or its numeric code.
+func ParseItemStatus(v string) (ItemStatus, error) {
+	for s, name := range itemStatusNames {
+		if name == v {
+			return s, nil
+		}
+	}
+	if n, err := strconv.Atoi(v); err == nil && ItemStatus(n).Valid() {
+		return ItemStatus(n), nil
+	}
+	return 0, fmt.Errorf("unknown item status %q", v)
+}
+
+// Transition checks that an item may move from s to next. Staying in the same
+// status is allowed, so repeated events are harmless.
+func (s ItemStatus) Transition(next ItemStatus) error {
+	if !next.Valid() {
+		return fmt.Errorf("unknown item status %d", next)
+	}
+	if s == next {
+		return nil
+	}
+	for _, allowed := range itemTransitions[s] {
+		if allowed == next {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, s, next)
+}
+
+// StatusChange is an entry of Item.StatusHistory.
+type StatusChange struct {
+	Status ItemStatus `json:"status"`
+	At     time.Time  `json:"at"`
+}
+
+// StatusUpdate moves an item of an order to a new status. It is the payload
+// of item status events and of PATCH /orders/{id}/items/{rid}/status; the
+// status may be given by name or by code.
+type StatusUpdate struct {
+	OrderUID string     `json:"order_uid"`
+	RID      string     `json:"rid"`
+	Status   ItemStatus `json:"status"`
+	// At is when the change happened; zero means now.
+	At time.Time `json:"at"`
+}
+
+func (u *StatusUpdate) UnmarshalJSON(data []byte) error {
+	type plain StatusUpdate
+	var v struct {
+		plain
+		Status json.RawMessage `json:"status"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*u = StatusUpdate(v.plain)
+	if len(v.Status) == 0 {
+		return errors.New("status is missing")
+	}
+	var name string
+	if err := json.Unmarshal(v.Status, &name); err != nil {
+		name = string(v.Status)
+	}
+	s, err := ParseItemStatus(name)
+	if err != nil {
+		return err
+	}
+	u.Status = s
+	return nil
+}
+
+// AggregateStatus is the least advanced status among the items still in
+// play, so an order is "shipped" once all of them are. Items returned or
+// cancelled do not hold the order back; if no other items are left, the
+// order is "returned" when any item was returned and "cancelled" otherwise.
+func (o *Order) AggregateStatus() string {
+	var (
+		lowest   ItemStatus
+		returned bool
+	)
+	for _, it := range o.Items {
+		switch it.Status {
+		case ItemReturned:
+			returned = true
+		case ItemCancelled:
+		default:
+			if lowest == 0 || it.Status < lowest {
+				lowest = it.Status
+			}
+		}
+	}
+	switch {
+	case lowest != 0:
+		return lowest.String()
+	case returned:
+		return ItemReturned.String()
+	case len(o.Items) > 0:
+		return ItemCancelled.String()
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,128 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestItemStatus_Transition(t *testing.T) {
+	for _, tc := range []struct {
+		from, to ItemStatus
+		ok       bool
+	}{
+		{ItemAccepted, ItemAssembled, true},
+		{ItemAccepted, ItemCancelled, true},
+		{ItemAssembled, ItemShipped, true},
+		{ItemAssembled, ItemCancelled, true},
+		{ItemShipped, ItemDelivered, true},
+		{ItemShipped, ItemReturned, true},
+		{ItemDelivered, ItemReceived, true},
+		{ItemDelivered, ItemReturned, true},
+		{ItemReceived, ItemReturned, true},
+		{ItemShipped, ItemShipped, true},
+		{ItemCancelled, ItemCancelled, true},
+
+		{ItemAccepted, ItemShipped, false},
+		{ItemAssembled, ItemAccepted, false},
+		{ItemShipped, ItemCancelled, false},
+		{ItemDelivered, ItemShipped, false},
+		{ItemReceived, ItemDelivered, false},
+		{ItemReturned, ItemDelivered, false},
+		{ItemCancelled, ItemAccepted, false},
+	} {
+		err := tc.from.Transition(tc.to)
+		if tc.ok && err != nil {
+			t.Errorf("%s -> %s: unexpected error %v", tc.from, tc.to, err)
+		}
+		if !tc.ok && !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("%s -> %s: expected ErrInvalidTransition, got %v", tc.from, tc.to, err)
+		}
+	}
+
+	if err := ItemAccepted.Transition(ItemStatus(999)); err == nil || errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("expected an unknown status error, got %v", err)
+	}
+}
+
+func TestParseItemStatus(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    ItemStatus
+		wantErr bool
+	}{
+		{in: "accepted", want: ItemAccepted},
+		{in: "cancelled", want: ItemCancelled},
+		{in: "204", want: ItemShipped},
+		{in: "207", want: ItemReturned},
+		{in: "Shipped", wantErr: true},
+		{in: "999", wantErr: true},
+		{in: "", wantErr: true},
+	} {
+		got, err := ParseItemStatus(tc.in)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %s", tc.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%q: expected %s, got %s, %v", tc.in, tc.want, got, err)
+		}
+	}
+}
+
+func TestStatusUpdate_UnmarshalJSON(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    ItemStatus
+		wantErr bool
+	}{
+		{in: `{"order_uid":"o1","rid":"r1","status":"shipped"}`, want: ItemShipped},
+		{in: `{"order_uid":"o1","rid":"r1","status":204}`, want: ItemShipped},
+		{in: `{"order_uid":"o1","rid":"r1","status":"208"}`, want: ItemCancelled},
+		{in: `{"order_uid":"o1","rid":"r1"}`, wantErr: true},
+		{in: `{"order_uid":"o1","rid":"r1","status":"lost"}`, wantErr: true},
+		{in: `{"order_uid":"o1","rid":"r1","status":1}`, wantErr: true},
+	} {
+		var u StatusUpdate
+		err := json.Unmarshal([]byte(tc.in), &u)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %+v", tc.in, u)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.in, err)
+			continue
+		}
+		if u.Status != tc.want || u.OrderUID != "o1" || u.RID != "r1" {
+			t.Errorf("%s: unexpected update %+v", tc.in, u)
+		}
+	}
+}
+
+func TestOrder_AggregateStatus(t *testing.T) {
+	items := func(ss ...ItemStatus) *Order {
+		o := &Order{}
+		for _, s := range ss {
+			o.Items = append(o.Items, Item{Status: s})
+		}
+		return o
+	}
+	for _, tc := range []struct {
+		order *Order
+		want  string
+	}{
+		{items(ItemShipped, ItemAssembled), "assembled"},
+		{items(ItemShipped, ItemCancelled), "shipped"},
+		{items(ItemReturned, ItemCancelled), "returned"},
+		{items(ItemCancelled), "cancelled"},
+		{items(), ""},
+	} {
+		if got := tc.order.AggregateStatus(); got != tc.want {
+			t.Errorf("expected %q, got %q", tc.want, got)
+		}
+	}
+}
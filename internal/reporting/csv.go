@@ -0,0 +1,30 @@
+package reporting
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// WriteCSV writes rows with a header line; the first column is named after
+// the grouping.
+func WriteCSV(w io.Writer, g GroupBy, rows []Row) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{string(g), "currency", "orders", "items", "revenue"}); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		err := cw.Write([]string{
+			r.Key,
+			r.Currency,
+			strconv.FormatInt(r.Orders, 10),
+			strconv.FormatInt(r.Items, 10),
+			strconv.FormatInt(r.Revenue, 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
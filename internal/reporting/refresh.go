@@ -0,0 +1,111 @@
+package reporting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Refresher periodically refreshes the report views. The first refresh runs
+// on start, so the views are used soon after they are enabled.
+type Refresher struct {
+	db       *pgxpool.Pool
+	interval time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewRefresher(db *pgxpool.Pool, interval time.Duration) *Refresher {
+	if interval <= 0 {
+		interval = 15 * time.Minute
+	}
+	return &Refresher{db: db, interval: interval}
+}
+
+func (r *Refresher) Start(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	r.cancel = cancel
+	r.done = make(chan struct{})
+
+	go r.run(runCtx)
+	return nil
+}
+
+func (r *Refresher) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("reporting: refresher did not stop: %w", ctx.Err())
+	}
+}
+
+func (r *Refresher) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("reporting: refresh views: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce refreshes the views and reports whether it did. It returns false
+// without waiting when another replica is refreshing them. Populated views are
+// refreshed concurrently, so reports keep reading them meanwhile.
+func (r *Refresher) RunOnce(ctx context.Context) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Printf("reporting: tx rollback error: %v", err)
+		}
+	}()
+
+	var locked bool
+	if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock(hashtext('report_refresh'))`).Scan(&locked); err != nil {
+		return false, err
+	}
+	if !locked {
+		return false, nil
+	}
+	for _, v := range views {
+		var populated bool
+		err := tx.QueryRow(ctx,
+			`SELECT ispopulated FROM pg_matviews WHERE schemaname = current_schema() AND matviewname = $1`, v).Scan(&populated)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", v, err)
+		}
+		sql := `REFRESH MATERIALIZED VIEW ` + v
+		if populated {
+			sql = `REFRESH MATERIALIZED VIEW CONCURRENTLY ` + v
+		}
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return false, fmt.Errorf("%s: %w", v, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,170 @@
+// Package reporting aggregates live orders for the /reports endpoints.
+//
+// Rows are split by currency, since amounts in different currencies cannot be
+// added up. Soft deleted and archived orders are not counted.
+package reporting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"orderservice/internal/model"
+)
+
+type GroupBy string
+
+const (
+	GroupByDay             GroupBy = "day"
+	GroupByBrand           GroupBy = "brand"
+	GroupByDeliveryService GroupBy = "delivery_service"
+	GroupByRegion          GroupBy = "region"
+)
+
+const dayLayout = "2006-01-02"
+
+// ErrUnavailable is returned for groupings a source cannot compute, e.g. by a
+// column that is stored encrypted.
+var ErrUnavailable = errors.New("reporting: grouping is not available")
+
+func ParseGroupBy(v string) (GroupBy, error) {
+	switch g := GroupBy(v); g {
+	case GroupByDay, GroupByBrand, GroupByDeliveryService, GroupByRegion:
+		return g, nil
+	default:
+		return "", fmt.Errorf("unknown group_by %q, want day, brand, delivery_service or region", v)
+	}
+}
+
+// Query selects orders created in [From, To); nil bounds are open. Limit of 0
+// returns all rows.
+type Query struct {
+	GroupBy GroupBy
+	From    *time.Time
+	To      *time.Time
+	Limit   int
+}
+
+// Row is one group and currency. Revenue is the sum of payment amounts, or of
+// the items' total_price when grouping by brand; Items counts the items of the
+// orders, or of the brand.
+type Row struct {
+	Key      string `json:"key"`
+	Currency string `json:"currency"`
+	Orders   int64  `json:"orders"`
+	Items    int64  `json:"items"`
+	Revenue  int64  `json:"revenue"`
+}
+
+// Report is the result of a query. Source tells whether the rows were read
+// from the live tables or from the materialized views.
+type Report struct {
+	Rows   []Row
+	Source string
+}
+
+const (
+	SourceLive  = "live"
+	SourceViews = "views"
+)
+
+type Source interface {
+	OrderReport(ctx context.Context, q Query) (*Report, error)
+}
+
+// Aggregate computes a report from orders in memory.
+func Aggregate(orders []*model.Order, q Query) []Row {
+	type key struct{ group, currency string }
+	groups := map[key]*Row{}
+	add := func(group, currency string, items, revenue int64) *Row {
+		k := key{group, currency}
+		row, ok := groups[k]
+		if !ok {
+			row = &Row{Key: group, Currency: currency}
+			groups[k] = row
+		}
+		row.Items += items
+		row.Revenue += revenue
+		return row
+	}
+
+	for _, o := range orders {
+		if !q.contains(o.DateCreated) {
+			continue
+		}
+		currency := o.Payment.Currency
+		switch q.GroupBy {
+		case GroupByBrand:
+			seen := map[string]bool{}
+			for _, it := range o.Items {
+				row := add(it.Brand, currency, 1, it.TotalPrice)
+				if !seen[it.Brand] {
+					seen[it.Brand] = true
+					row.Orders++
+				}
+			}
+		default:
+			add(orderKey(o, q.GroupBy), currency, int64(len(o.Items)), o.Payment.Amount).Orders++
+		}
+	}
+
+	rows := make([]Row, 0, len(groups))
+	for _, row := range groups {
+		rows = append(rows, *row)
+	}
+	sortRows(rows, q.GroupBy)
+	if q.Limit > 0 && len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+	}
+	return rows
+}
+
+func orderKey(o *model.Order, g GroupBy) string {
+	switch g {
+	case GroupByDeliveryService:
+		return o.DeliveryService
+	case GroupByRegion:
+		return o.Delivery.Region
+	default:
+		return o.DateCreated.UTC().Format(dayLayout)
+	}
+}
+
+func (q Query) contains(t time.Time) bool {
+	return (q.From == nil || !t.Before(*q.From)) && (q.To == nil || t.Before(*q.To))
+}
+
+// sortRows orders days chronologically and everything else by revenue, so the
+// top brands come first.
+func sortRows(rows []Row, g GroupBy) {
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if g != GroupByDay && a.Revenue != b.Revenue {
+			return a.Revenue > b.Revenue
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Currency < b.Currency
+	})
+}
+
+// Orders is a Source that aggregates the result of list, e.g. the
+// GetAllOrders of the in-memory repo.
+type Orders struct {
+	list func(ctx context.Context) ([]*model.Order, error)
+}
+
+func NewOrders(list func(ctx context.Context) ([]*model.Order, error)) *Orders {
+	return &Orders{list: list}
+}
+
+func (s *Orders) OrderReport(ctx context.Context, q Query) (*Report, error) {
+	orders, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Report{Rows: Aggregate(orders, q), Source: SourceLive}, nil
+}
@@ -0,0 +1,87 @@
+package reporting
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"orderservice/internal/model"
+)
+
+func testOrders() []*model.Order {
+	order := func(day int, currency, service, region string, amount int64, brands ...string) *model.Order {
+		o := &model.Order{
+			DateCreated:     time.Date(2025, 11, day, 23, 30, 0, 0, time.FixedZone("MSK", 3*3600)),
+			DeliveryService: service,
+			Delivery:        model.Delivery{Region: region},
+			Payment:         model.Payment{Currency: currency, Amount: amount},
+		}
+		for _, b := range brands {
+			o.Items = append(o.Items, model.Item{Brand: b, TotalPrice: 10})
+		}
+		return o
+	}
+	return []*model.Order{
+		order(1, "RUB", "cdek", "Moscow", 100, "acme", "acme"),
+		order(1, "USD", "cdek", "Moscow", 5, "zeta"),
+		order(2, "RUB", "boxberry", "Kazan", 300, "acme", "zeta"),
+		order(3, "RUB", "cdek", "Kazan", 50, "zeta"),
+	}
+}
+
+func TestAggregate_ByDay(t *testing.T) {
+	// Days are taken in UTC: 23:30 MSK is still the same day.
+	got := Aggregate(testOrders(), Query{GroupBy: GroupByDay})
+	want := []Row{
+		{Key: "2025-11-01", Currency: "RUB", Orders: 1, Items: 2, Revenue: 100},
+		{Key: "2025-11-01", Currency: "USD", Orders: 1, Items: 1, Revenue: 5},
+		{Key: "2025-11-02", Currency: "RUB", Orders: 1, Items: 2, Revenue: 300},
+		{Key: "2025-11-03", Currency: "RUB", Orders: 1, Items: 1, Revenue: 50},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestAggregate_ByBrand(t *testing.T) {
+	got := Aggregate(testOrders(), Query{GroupBy: GroupByBrand, Limit: 2})
+	want := []Row{
+		{Key: "acme", Currency: "RUB", Orders: 2, Items: 3, Revenue: 30},
+		{Key: "zeta", Currency: "RUB", Orders: 2, Items: 2, Revenue: 20},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestAggregate_Range(t *testing.T) {
+	from := time.Date(2025, 11, 2, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 11, 3, 0, 0, 0, 0, time.UTC)
+	got := Aggregate(testOrders(), Query{GroupBy: GroupByRegion, From: &from, To: &to})
+	want := []Row{{Key: "Kazan", Currency: "RUB", Orders: 1, Items: 2, Revenue: 300}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	rows := []Row{{Key: "cdek, express", Currency: "RUB", Orders: 2, Items: 3, Revenue: 150}}
+	if err := WriteCSV(&buf, GroupByDeliveryService, rows); err != nil {
+		t.Fatal(err)
+	}
+	want := "delivery_service,currency,orders,items,revenue\n\"cdek, express\",RUB,2,3,150\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestParseGroupBy(t *testing.T) {
+	if g, err := ParseGroupBy("brand"); err != nil || g != GroupByBrand {
+		t.Fatalf("expected brand, got %q, %v", g, err)
+	}
+	if _, err := ParseGroupBy("customer"); err == nil {
+		t.Fatal("expected an error for an unknown grouping")
+	}
+}
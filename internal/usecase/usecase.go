@@ -2,15 +2,31 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
+
 	"orderservice/internal/infrastructure/cache"
 	"orderservice/internal/infrastructure/repo"
 	"orderservice/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrOrderNotFound = errors.New("order not found")
+	ErrItemNotFound  = errors.New("item not found")
 )
 
 type OrderUsecase interface {
 	GetOrder(ctx context.Context, orderUID string) (*model.Order, error)
 	CreateOrder(ctx context.Context, ord *model.Order) error
+	DeleteOrder(ctx context.Context, orderUID string) error
+	EraseCustomer(ctx context.Context, customerID, requestID string) (*model.Erasure, error)
+	FindOrdersByContact(ctx context.Context, email, phone string) ([]*model.Order, error)
+	OrderHistory(ctx context.Context, orderUID string) ([]model.AuditEntry, error)
+	UpdateItemStatus(ctx context.Context, upd model.StatusUpdate) (*model.Order, error)
+	ExportOrders(ctx context.Context, from, to *time.Time, fn func(*model.Order) error) error
 }
 
 type orderUsecase struct {
@@ -22,9 +38,10 @@ func NewOrderUsecase(r repo.Repo, c cache.Cache) OrderUsecase {
 	return &orderUsecase{repo: r, cache: c}
 }
 
+// GetOrder returns the order with its aggregate status filled in.
 func (u *orderUsecase) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
 	if o, ok := u.cache.Get(orderUID); ok {
-		return o, nil
+		return withStatus(o), nil
 	}
 
 	o, err := u.repo.GetOrderByID(ctx, orderUID)
@@ -33,7 +50,14 @@ func (u *orderUsecase) GetOrder(ctx context.Context, orderUID string) (*model.Or
 	}
 
 	u.cache.Set(o)
-	return o, nil
+	return withStatus(o), nil
+}
+
+// withStatus returns a copy, since cached orders are shared.
+func withStatus(o *model.Order) *model.Order {
+	c := *o
+	c.Status = o.AggregateStatus()
+	return &c
 }
 
 func (u *orderUsecase) CreateOrder(ctx context.Context, ord *model.Order) error {
@@ -48,3 +72,73 @@ func (u *orderUsecase) CreateOrder(ctx context.Context, ord *model.Order) error
 	u.cache.Set(ord)
 	return nil
 }
+
+func (u *orderUsecase) DeleteOrder(ctx context.Context, orderUID string) error {
+	if err := u.repo.DeleteOrder(ctx, orderUID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrOrderNotFound
+		}
+		return err
+	}
+
+	u.cache.Delete(orderUID)
+	return nil
+}
+
+func (u *orderUsecase) EraseCustomer(ctx context.Context, customerID, requestID string) (*model.Erasure, error) {
+	e, err := u.repo.EraseCustomer(ctx, customerID, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	u.cache.Delete(e.OrderUIDs...)
+	return e, nil
+}
+
+func (u *orderUsecase) FindOrdersByContact(ctx context.Context, email, phone string) ([]*model.Order, error) {
+	return u.repo.FindOrdersByContact(ctx, email, phone)
+}
+
+// OrderHistory returns ErrOrderNotFound for orders without audit entries,
+// including orders stored before the audit log existed.
+func (u *orderUsecase) OrderHistory(ctx context.Context, orderUID string) ([]model.AuditEntry, error) {
+	entries, err := u.repo.OrderHistory(ctx, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrOrderNotFound
+	}
+	return entries, nil
+}
+
+// UpdateItemStatus returns ErrOrderNotFound for missing and deleted orders;
+// the latter also matches repo.ErrOrderDeleted.
+func (u *orderUsecase) UpdateItemStatus(ctx context.Context, upd model.StatusUpdate) (*model.Order, error) {
+	if upd.At.IsZero() {
+		upd.At = time.Now()
+	}
+	upd.At = upd.At.UTC().Truncate(time.Microsecond)
+
+	o, err := u.repo.SetItemStatus(ctx, upd)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrOrderNotFound
+		case errors.Is(err, repo.ErrOrderDeleted):
+			return nil, fmt.Errorf("%w: %w", ErrOrderNotFound, err)
+		case errors.Is(err, repo.ErrItemNotFound):
+			return nil, ErrItemNotFound
+		}
+		return nil, err
+	}
+
+	u.cache.Set(o)
+	return withStatus(o), nil
+}
+
+// ExportOrders streams live orders created in [from, to) from the repo,
+// bypassing the cache.
+func (u *orderUsecase) ExportOrders(ctx context.Context, from, to *time.Time, fn func(*model.Order) error) error {
+	return u.repo.ExportOrders(ctx, from, to, fn)
+}
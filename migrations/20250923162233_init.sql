@@ -57,7 +57,7 @@ CREATE TABLE IF NOT EXISTS items (
 );
 
 -- +goose Down
-DROP TABLE orders;
-DROP TABLE deliveries;
-DROP TABLE payments;
-DROP TABLE items;
\ No newline at end of file
+DROP TABLE IF EXISTS items;
+DROP TABLE IF EXISTS payments;
+DROP TABLE IF EXISTS deliveries;
+DROP TABLE IF EXISTS orders;
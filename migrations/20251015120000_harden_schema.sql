@@ -0,0 +1,255 @@
+-- +goose Up
+
+-- Backfill: child rows without an order can never be read, and only the
+-- latest delivery/payment of an order was ever visible.
+DELETE FROM deliveries WHERE order_uid IS NULL;
+DELETE FROM payments WHERE order_uid IS NULL;
+DELETE FROM items WHERE order_uid IS NULL;
+DELETE FROM deliveries d USING deliveries newer WHERE d.order_uid = newer.order_uid AND d.id < newer.id;
+DELETE FROM payments p USING payments newer WHERE p.order_uid = newer.order_uid AND p.id < newer.id;
+
+UPDATE orders SET
+    track_number = COALESCE(track_number, ''),
+    entry = COALESCE(entry, ''),
+    locale = COALESCE(locale, ''),
+    internal_signature = COALESCE(internal_signature, ''),
+    customer_id = COALESCE(customer_id, ''),
+    delivery_service = COALESCE(delivery_service, ''),
+    shardkey = COALESCE(shardkey, ''),
+    sm_id = COALESCE(sm_id, 0),
+    date_created = COALESCE(date_created, 'epoch'),
+    oof_shard = COALESCE(oof_shard, '');
+
+UPDATE deliveries SET
+    name = COALESCE(name, ''),
+    phone = COALESCE(phone, ''),
+    zip = COALESCE(zip, ''),
+    city = COALESCE(city, ''),
+    address = COALESCE(address, ''),
+    region = COALESCE(region, ''),
+    email = COALESCE(email, '');
+
+UPDATE payments SET
+    transaction = COALESCE(transaction, ''),
+    request_id = COALESCE(request_id, ''),
+    currency = COALESCE(currency, ''),
+    provider = COALESCE(provider, ''),
+    amount = COALESCE(amount, 0),
+    payment_dt = COALESCE(payment_dt, 0),
+    bank = COALESCE(bank, ''),
+    delivery_cost = COALESCE(delivery_cost, 0),
+    goods_total = COALESCE(goods_total, 0),
+    custom_fee = COALESCE(custom_fee, 0);
+
+UPDATE items SET
+    chrt_id = COALESCE(chrt_id, 0),
+    track_number = COALESCE(track_number, ''),
+    price = COALESCE(price, 0),
+    rid = COALESCE(rid, ''),
+    name = COALESCE(name, ''),
+    sale = COALESCE(sale, 0),
+    size = COALESCE(size, ''),
+    total_price = COALESCE(total_price, 0),
+    nm_id = COALESCE(nm_id, 0),
+    brand = COALESCE(brand, ''),
+    status = COALESCE(status, 0);
+
+-- date_created was written as a UTC wall clock.
+ALTER TABLE orders
+    ALTER COLUMN track_number SET NOT NULL,
+    ALTER COLUMN entry SET NOT NULL,
+    ALTER COLUMN locale SET NOT NULL,
+    ALTER COLUMN internal_signature SET NOT NULL,
+    ALTER COLUMN customer_id SET NOT NULL,
+    ALTER COLUMN delivery_service SET NOT NULL,
+    ALTER COLUMN shardkey SET NOT NULL,
+    ALTER COLUMN sm_id SET NOT NULL,
+    ALTER COLUMN date_created TYPE TIMESTAMPTZ USING date_created AT TIME ZONE 'UTC',
+    ALTER COLUMN date_created SET NOT NULL,
+    ALTER COLUMN oof_shard SET NOT NULL;
+
+ALTER TABLE deliveries
+    ALTER COLUMN order_uid SET NOT NULL,
+    ALTER COLUMN name SET NOT NULL,
+    ALTER COLUMN phone SET NOT NULL,
+    ALTER COLUMN zip SET NOT NULL,
+    ALTER COLUMN city SET NOT NULL,
+    ALTER COLUMN address SET NOT NULL,
+    ALTER COLUMN region SET NOT NULL,
+    ALTER COLUMN email SET NOT NULL,
+    ADD CONSTRAINT deliveries_order_uid_key UNIQUE (order_uid);
+
+ALTER TABLE payments
+    ALTER COLUMN order_uid SET NOT NULL,
+    ALTER COLUMN transaction SET NOT NULL,
+    ALTER COLUMN request_id SET NOT NULL,
+    ALTER COLUMN currency SET NOT NULL,
+    ALTER COLUMN provider SET NOT NULL,
+    ALTER COLUMN amount TYPE BIGINT,
+    ALTER COLUMN amount SET NOT NULL,
+    ALTER COLUMN payment_dt SET NOT NULL,
+    ALTER COLUMN bank SET NOT NULL,
+    ALTER COLUMN delivery_cost TYPE BIGINT,
+    ALTER COLUMN delivery_cost SET NOT NULL,
+    ALTER COLUMN goods_total TYPE BIGINT,
+    ALTER COLUMN goods_total SET NOT NULL,
+    ALTER COLUMN custom_fee TYPE BIGINT,
+    ALTER COLUMN custom_fee SET NOT NULL,
+    ADD CONSTRAINT payments_order_uid_key UNIQUE (order_uid);
+
+ALTER TABLE items
+    ALTER COLUMN order_uid SET NOT NULL,
+    ALTER COLUMN chrt_id TYPE BIGINT,
+    ALTER COLUMN chrt_id SET NOT NULL,
+    ALTER COLUMN track_number SET NOT NULL,
+    ALTER COLUMN price TYPE BIGINT,
+    ALTER COLUMN price SET NOT NULL,
+    ALTER COLUMN rid SET NOT NULL,
+    ALTER COLUMN name SET NOT NULL,
+    ALTER COLUMN sale TYPE BIGINT,
+    ALTER COLUMN sale SET NOT NULL,
+    ALTER COLUMN size SET NOT NULL,
+    ALTER COLUMN total_price TYPE BIGINT,
+    ALTER COLUMN total_price SET NOT NULL,
+    ALTER COLUMN nm_id TYPE BIGINT,
+    ALTER COLUMN nm_id SET NOT NULL,
+    ALTER COLUMN brand SET NOT NULL,
+    ALTER COLUMN status SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS items_order_uid_idx ON items (order_uid);
+
+-- Mirrors model.Validate (except the date_created range, which depends on
+-- the current time). Added NOT VALID so new writes are checked right away;
+-- the DO block below validates them unless legacy rows violate a check.
+ALTER TABLE orders
+    ADD CONSTRAINT orders_order_uid_check CHECK (btrim(order_uid) <> '') NOT VALID,
+    ADD CONSTRAINT orders_track_number_check CHECK (btrim(track_number) <> '') NOT VALID,
+    ADD CONSTRAINT orders_customer_id_check CHECK (btrim(customer_id) <> '') NOT VALID;
+
+ALTER TABLE deliveries
+    ADD CONSTRAINT deliveries_name_check CHECK (btrim(name) <> '') NOT VALID,
+    ADD CONSTRAINT deliveries_phone_check CHECK (btrim(phone) <> '') NOT VALID,
+    ADD CONSTRAINT deliveries_city_check CHECK (btrim(city) <> '') NOT VALID,
+    ADD CONSTRAINT deliveries_address_check CHECK (btrim(address) <> '') NOT VALID,
+    ADD CONSTRAINT deliveries_email_check CHECK (strpos(email, '@') > 0) NOT VALID;
+
+ALTER TABLE payments
+    ADD CONSTRAINT payments_amount_check CHECK (amount > 0) NOT VALID,
+    ADD CONSTRAINT payments_currency_check CHECK (btrim(currency) <> '') NOT VALID,
+    ADD CONSTRAINT payments_provider_check CHECK (btrim(provider) <> '') NOT VALID,
+    ADD CONSTRAINT payments_transaction_check CHECK (btrim(transaction) <> '') NOT VALID;
+
+ALTER TABLE items
+    ADD CONSTRAINT items_name_check CHECK (btrim(name) <> '') NOT VALID,
+    ADD CONSTRAINT items_price_check CHECK (price > 0) NOT VALID,
+    ADD CONSTRAINT items_total_price_check CHECK (total_price >= price - sale) NOT VALID,
+    ADD CONSTRAINT items_brand_check CHECK (btrim(brand) <> '') NOT VALID;
+
+-- +goose StatementBegin
+DO $$
+DECLARE
+    c RECORD;
+BEGIN
+    FOR c IN
+        SELECT conrelid::regclass AS tbl, conname
+        FROM pg_constraint
+        WHERE contype = 'c' AND NOT convalidated
+          AND conrelid IN ('orders'::regclass, 'deliveries'::regclass, 'payments'::regclass, 'items'::regclass)
+    LOOP
+        BEGIN
+            EXECUTE format('ALTER TABLE %s VALIDATE CONSTRAINT %I', c.tbl, c.conname);
+        EXCEPTION WHEN check_violation THEN
+            RAISE WARNING 'constraint % on % left NOT VALID: existing rows violate it', c.conname, c.tbl;
+        END;
+    END LOOP;
+END
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+ALTER TABLE items
+    DROP CONSTRAINT IF EXISTS items_name_check,
+    DROP CONSTRAINT IF EXISTS items_price_check,
+    DROP CONSTRAINT IF EXISTS items_total_price_check,
+    DROP CONSTRAINT IF EXISTS items_brand_check;
+
+ALTER TABLE payments
+    DROP CONSTRAINT IF EXISTS payments_amount_check,
+    DROP CONSTRAINT IF EXISTS payments_currency_check,
+    DROP CONSTRAINT IF EXISTS payments_provider_check,
+    DROP CONSTRAINT IF EXISTS payments_transaction_check;
+
+ALTER TABLE deliveries
+    DROP CONSTRAINT IF EXISTS deliveries_name_check,
+    DROP CONSTRAINT IF EXISTS deliveries_phone_check,
+    DROP CONSTRAINT IF EXISTS deliveries_city_check,
+    DROP CONSTRAINT IF EXISTS deliveries_address_check,
+    DROP CONSTRAINT IF EXISTS deliveries_email_check;
+
+ALTER TABLE orders
+    DROP CONSTRAINT IF EXISTS orders_order_uid_check,
+    DROP CONSTRAINT IF EXISTS orders_track_number_check,
+    DROP CONSTRAINT IF EXISTS orders_customer_id_check;
+
+DROP INDEX IF EXISTS items_order_uid_idx;
+
+ALTER TABLE items
+    ALTER COLUMN order_uid DROP NOT NULL,
+    ALTER COLUMN chrt_id DROP NOT NULL,
+    ALTER COLUMN chrt_id TYPE INT,
+    ALTER COLUMN track_number DROP NOT NULL,
+    ALTER COLUMN price DROP NOT NULL,
+    ALTER COLUMN price TYPE INT,
+    ALTER COLUMN rid DROP NOT NULL,
+    ALTER COLUMN name DROP NOT NULL,
+    ALTER COLUMN sale DROP NOT NULL,
+    ALTER COLUMN sale TYPE INT,
+    ALTER COLUMN size DROP NOT NULL,
+    ALTER COLUMN total_price DROP NOT NULL,
+    ALTER COLUMN total_price TYPE INT,
+    ALTER COLUMN nm_id DROP NOT NULL,
+    ALTER COLUMN nm_id TYPE INT,
+    ALTER COLUMN brand DROP NOT NULL,
+    ALTER COLUMN status DROP NOT NULL;
+
+ALTER TABLE payments
+    DROP CONSTRAINT IF EXISTS payments_order_uid_key,
+    ALTER COLUMN order_uid DROP NOT NULL,
+    ALTER COLUMN transaction DROP NOT NULL,
+    ALTER COLUMN request_id DROP NOT NULL,
+    ALTER COLUMN currency DROP NOT NULL,
+    ALTER COLUMN provider DROP NOT NULL,
+    ALTER COLUMN amount DROP NOT NULL,
+    ALTER COLUMN amount TYPE INT,
+    ALTER COLUMN payment_dt DROP NOT NULL,
+    ALTER COLUMN bank DROP NOT NULL,
+    ALTER COLUMN delivery_cost DROP NOT NULL,
+    ALTER COLUMN delivery_cost TYPE INT,
+    ALTER COLUMN goods_total DROP NOT NULL,
+    ALTER COLUMN goods_total TYPE INT,
+    ALTER COLUMN custom_fee DROP NOT NULL,
+    ALTER COLUMN custom_fee TYPE INT;
+
+ALTER TABLE deliveries
+    DROP CONSTRAINT IF EXISTS deliveries_order_uid_key,
+    ALTER COLUMN order_uid DROP NOT NULL,
+    ALTER COLUMN name DROP NOT NULL,
+    ALTER COLUMN phone DROP NOT NULL,
+    ALTER COLUMN zip DROP NOT NULL,
+    ALTER COLUMN city DROP NOT NULL,
+    ALTER COLUMN address DROP NOT NULL,
+    ALTER COLUMN region DROP NOT NULL,
+    ALTER COLUMN email DROP NOT NULL;
+
+ALTER TABLE orders
+    ALTER COLUMN track_number DROP NOT NULL,
+    ALTER COLUMN entry DROP NOT NULL,
+    ALTER COLUMN locale DROP NOT NULL,
+    ALTER COLUMN internal_signature DROP NOT NULL,
+    ALTER COLUMN customer_id DROP NOT NULL,
+    ALTER COLUMN delivery_service DROP NOT NULL,
+    ALTER COLUMN shardkey DROP NOT NULL,
+    ALTER COLUMN sm_id DROP NOT NULL,
+    ALTER COLUMN date_created DROP NOT NULL,
+    ALTER COLUMN date_created TYPE TIMESTAMP USING date_created AT TIME ZONE 'UTC',
+    ALTER COLUMN oof_shard DROP NOT NULL;
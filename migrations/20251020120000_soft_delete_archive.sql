@@ -0,0 +1,42 @@
+-- +goose Up
+ALTER TABLE orders ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS orders_date_created_idx ON orders (date_created);
+CREATE INDEX IF NOT EXISTS orders_deleted_at_idx ON orders (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Archive tables mirror the live ones without foreign keys, so archived rows
+-- outlive the orders they were moved from.
+CREATE TABLE IF NOT EXISTS orders_archive (
+    LIKE orders,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (order_uid)
+);
+
+CREATE TABLE IF NOT EXISTS deliveries_archive (
+    LIKE deliveries,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS payments_archive (
+    LIKE payments,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS items_archive (
+    LIKE items,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS deliveries_archive_order_uid_idx ON deliveries_archive (order_uid);
+CREATE INDEX IF NOT EXISTS payments_archive_order_uid_idx ON payments_archive (order_uid);
+CREATE INDEX IF NOT EXISTS items_archive_order_uid_idx ON items_archive (order_uid);
+
+-- +goose Down
+DROP TABLE IF EXISTS items_archive;
+DROP TABLE IF EXISTS payments_archive;
+DROP TABLE IF EXISTS deliveries_archive;
+DROP TABLE IF EXISTS orders_archive;
+
+DROP INDEX IF EXISTS orders_deleted_at_idx;
+DROP INDEX IF EXISTS orders_date_created_idx;
+ALTER TABLE orders DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- One row per erasure request. Orders of a customer dated before their
+-- latest erasure are rejected on ingest, so replayed messages cannot bring
+-- the personal data back.
+CREATE TABLE IF NOT EXISTS customer_erasures (
+    id BIGSERIAL PRIMARY KEY,
+    customer_id TEXT NOT NULL,
+    request_id TEXT NOT NULL,
+    orders_affected INT NOT NULL,
+    erased_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS customer_erasures_customer_id_idx ON customer_erasures (customer_id, erased_at);
+CREATE INDEX IF NOT EXISTS orders_customer_id_idx ON orders (customer_id);
+CREATE INDEX IF NOT EXISTS orders_archive_customer_id_idx ON orders_archive (customer_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS orders_archive_customer_id_idx;
+DROP INDEX IF EXISTS orders_customer_id_idx;
+DROP TABLE IF EXISTS customer_erasures;
@@ -0,0 +1,70 @@
+-- +goose Up
+-- pii_key_id names the master key that wraps pii_data_key, the row's data
+-- key. Rows with a NULL pii_key_id are stored in clear text. The archive
+-- table gets the same columns in the same order, so rows still copy with
+-- SELECT *.
+ALTER TABLE deliveries
+    ADD COLUMN pii_key_id TEXT,
+    ADD COLUMN pii_data_key BYTEA,
+    ADD COLUMN email_bidx BYTEA,
+    ADD COLUMN phone_bidx BYTEA;
+
+ALTER TABLE deliveries_archive
+    ADD COLUMN pii_key_id TEXT,
+    ADD COLUMN pii_data_key BYTEA,
+    ADD COLUMN email_bidx BYTEA,
+    ADD COLUMN phone_bidx BYTEA;
+
+CREATE INDEX IF NOT EXISTS deliveries_email_bidx_idx ON deliveries (email_bidx) WHERE email_bidx IS NOT NULL;
+CREATE INDEX IF NOT EXISTS deliveries_phone_bidx_idx ON deliveries (phone_bidx) WHERE phone_bidx IS NOT NULL;
+CREATE INDEX IF NOT EXISTS deliveries_pii_key_id_idx ON deliveries (pii_key_id);
+CREATE INDEX IF NOT EXISTS deliveries_archive_pii_key_id_idx ON deliveries_archive (pii_key_id);
+
+-- An encrypted email has no '@'.
+ALTER TABLE deliveries DROP CONSTRAINT IF EXISTS deliveries_email_check;
+ALTER TABLE deliveries
+    ADD CONSTRAINT deliveries_email_check CHECK (strpos(email, '@') > 0 OR email LIKE 'enc:%') NOT VALID;
+
+-- +goose StatementBegin
+DO $$
+BEGIN
+    ALTER TABLE deliveries VALIDATE CONSTRAINT deliveries_email_check;
+EXCEPTION WHEN check_violation THEN
+    RAISE WARNING 'constraint deliveries_email_check left NOT VALID: existing rows violate it';
+END
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Dropping the key columns would leave encrypted values unreadable.
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM deliveries WHERE pii_key_id IS NOT NULL)
+        OR EXISTS (SELECT 1 FROM deliveries_archive WHERE pii_key_id IS NOT NULL) THEN
+        RAISE EXCEPTION 'deliveries hold encrypted rows; decrypt them before rolling back';
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+ALTER TABLE deliveries DROP CONSTRAINT IF EXISTS deliveries_email_check;
+ALTER TABLE deliveries
+    ADD CONSTRAINT deliveries_email_check CHECK (strpos(email, '@') > 0) NOT VALID;
+
+DROP INDEX IF EXISTS deliveries_archive_pii_key_id_idx;
+DROP INDEX IF EXISTS deliveries_pii_key_id_idx;
+DROP INDEX IF EXISTS deliveries_phone_bidx_idx;
+DROP INDEX IF EXISTS deliveries_email_bidx_idx;
+
+ALTER TABLE deliveries_archive
+    DROP COLUMN IF EXISTS pii_key_id,
+    DROP COLUMN IF EXISTS pii_data_key,
+    DROP COLUMN IF EXISTS email_bidx,
+    DROP COLUMN IF EXISTS phone_bidx;
+
+ALTER TABLE deliveries
+    DROP COLUMN IF EXISTS pii_key_id,
+    DROP COLUMN IF EXISTS pii_data_key,
+    DROP COLUMN IF EXISTS email_bidx,
+    DROP COLUMN IF EXISTS phone_bidx;
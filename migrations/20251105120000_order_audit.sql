@@ -0,0 +1,22 @@
+-- +goose Up
+-- Append-only history of order changes. There is no foreign key to orders:
+-- the history outlives deleted and archived orders.
+CREATE TABLE IF NOT EXISTS order_audit (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid TEXT NOT NULL,
+    action TEXT NOT NULL
+        CHECK (action IN ('created', 'updated', 'deleted', 'erased', 'archived', 'exported')),
+    source_kind TEXT NOT NULL,
+    principal TEXT,
+    request_id TEXT,
+    topic TEXT,
+    kafka_partition INT,
+    kafka_offset BIGINT,
+    changes JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS order_audit_order_uid_idx ON order_audit (order_uid, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS order_audit;
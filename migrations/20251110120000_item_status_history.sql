@@ -0,0 +1,62 @@
+-- +goose Up
+-- Item statuses had no meaning before; producers always sent 202, which is
+-- now "accepted". Rows with any other value are reported, not rewritten, and
+-- the constraint stays NOT VALID until they are fixed by hand.
+ALTER TABLE items
+    ADD CONSTRAINT items_status_check CHECK (status BETWEEN 202 AND 208) NOT VALID;
+
+-- +goose StatementBegin
+DO $$
+DECLARE
+    n BIGINT;
+    sample TEXT;
+BEGIN
+    SELECT count(*), string_agg(order_uid || '/' || rid || '=' || status, ', ')
+    INTO n, sample
+    FROM (
+        SELECT order_uid, rid, status
+        FROM items
+        WHERE status NOT BETWEEN 202 AND 208
+        ORDER BY id
+        LIMIT 20
+    ) s;
+    IF n = 0 THEN
+        ALTER TABLE items VALIDATE CONSTRAINT items_status_check;
+    ELSE
+        SELECT count(*) INTO n FROM items WHERE status NOT BETWEEN 202 AND 208;
+        RAISE WARNING 'constraint items_status_check on items left NOT VALID: % rows have an unknown status (%)', n, sample;
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+-- Keyed by rid rather than items.id: order updates replace the item rows but
+-- keep the status history of items they still contain.
+CREATE TABLE IF NOT EXISTS item_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid TEXT NOT NULL REFERENCES orders (order_uid) ON DELETE CASCADE,
+    rid TEXT NOT NULL,
+    status INT NOT NULL CHECK (status BETWEEN 202 AND 208),
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS item_status_history_order_uid_idx ON item_status_history (order_uid, rid, id);
+
+CREATE TABLE IF NOT EXISTS item_status_history_archive (
+    LIKE item_status_history,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS item_status_history_archive_order_uid_idx ON item_status_history_archive (order_uid);
+
+INSERT INTO item_status_history (order_uid, rid, status, changed_at)
+SELECT i.order_uid, i.rid, i.status, o.date_created
+FROM items i
+JOIN orders o ON o.order_uid = i.order_uid
+WHERE i.status BETWEEN 202 AND 208
+ORDER BY i.id;
+
+-- +goose Down
+DROP TABLE IF EXISTS item_status_history_archive;
+DROP TABLE IF EXISTS item_status_history;
+ALTER TABLE items DROP CONSTRAINT IF EXISTS items_status_check;
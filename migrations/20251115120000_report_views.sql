@@ -0,0 +1,46 @@
+-- +goose Up
+-- Daily aggregates for /reports/orders. They are created empty and filled by
+-- the refresher (REPORT_VIEWS), so the migration stays fast on large tables;
+-- reports read the live tables until then.
+CREATE MATERIALIZED VIEW IF NOT EXISTS report_orders_daily AS
+SELECT
+    (o.date_created AT TIME ZONE 'UTC')::date AS day,
+    p.currency,
+    o.delivery_service,
+    d.region,
+    count(*)::bigint AS orders,
+    sum((SELECT count(*) FROM items i WHERE i.order_uid = o.order_uid))::bigint AS items,
+    sum(p.amount)::bigint AS revenue
+FROM orders o
+JOIN payments p ON p.order_uid = o.order_uid
+JOIN deliveries d ON d.order_uid = o.order_uid
+WHERE o.deleted_at IS NULL
+GROUP BY 1, 2, 3, 4
+WITH NO DATA;
+
+-- REFRESH ... CONCURRENTLY needs a unique index.
+CREATE UNIQUE INDEX IF NOT EXISTS report_orders_daily_key
+    ON report_orders_daily (day, currency, delivery_service, region);
+
+-- An order is counted once per day and brand, so orders add up over days.
+CREATE MATERIALIZED VIEW IF NOT EXISTS report_brands_daily AS
+SELECT
+    (o.date_created AT TIME ZONE 'UTC')::date AS day,
+    p.currency,
+    i.brand,
+    count(DISTINCT o.order_uid)::bigint AS orders,
+    count(*)::bigint AS items,
+    sum(i.total_price)::bigint AS revenue
+FROM orders o
+JOIN payments p ON p.order_uid = o.order_uid
+JOIN items i ON i.order_uid = o.order_uid
+WHERE o.deleted_at IS NULL
+GROUP BY 1, 2, 3
+WITH NO DATA;
+
+CREATE UNIQUE INDEX IF NOT EXISTS report_brands_daily_key
+    ON report_brands_daily (day, currency, brand);
+
+-- +goose Down
+DROP MATERIALIZED VIEW IF EXISTS report_brands_daily;
+DROP MATERIALIZED VIEW IF EXISTS report_orders_daily;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Events no longer carry the order delivery, which holds personal data;
+-- drop it from the events already written.
+UPDATE outbox SET payload = payload #- '{order,delivery}'
+WHERE payload->'order' ? 'delivery';
+
+-- +goose Down
+-- The delivery data is gone; nothing to restore.
+SELECT 1;
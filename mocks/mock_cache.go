@@ -52,6 +52,22 @@ func (mr *MockCacheMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockCache)(nil).Close))
 }
 
+// Delete mocks base method.
+func (m *MockCache) Delete(orderUIDs ...string) {
+	m.ctrl.T.Helper()
+	varargs := []any{}
+	for _, a := range orderUIDs {
+		varargs = append(varargs, a)
+	}
+	m.ctrl.Call(m, "Delete", varargs...)
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockCacheMockRecorder) Delete(orderUIDs ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockCache)(nil).Delete), orderUIDs...)
+}
+
 // Get mocks base method.
 func (m *MockCache) Get(orderUID string) (*model.Order, bool) {
 	m.ctrl.T.Helper()
@@ -13,6 +13,7 @@ import (
 	context "context"
 	model "orderservice/internal/model"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -56,6 +57,64 @@ func (mr *MockRepoMockRecorder) CreateOrder(ctx, order any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockRepo)(nil).CreateOrder), ctx, order)
 }
 
+// DeleteOrder mocks base method.
+func (m *MockRepo) DeleteOrder(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOrder", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOrder indicates an expected call of DeleteOrder.
+func (mr *MockRepoMockRecorder) DeleteOrder(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOrder", reflect.TypeOf((*MockRepo)(nil).DeleteOrder), ctx, id)
+}
+
+// EraseCustomer mocks base method.
+func (m *MockRepo) EraseCustomer(ctx context.Context, customerID, requestID string) (*model.Erasure, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EraseCustomer", ctx, customerID, requestID)
+	ret0, _ := ret[0].(*model.Erasure)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EraseCustomer indicates an expected call of EraseCustomer.
+func (mr *MockRepoMockRecorder) EraseCustomer(ctx, customerID, requestID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EraseCustomer", reflect.TypeOf((*MockRepo)(nil).EraseCustomer), ctx, customerID, requestID)
+}
+
+// ExportOrders mocks base method.
+func (m *MockRepo) ExportOrders(ctx context.Context, from, to *time.Time, fn func(*model.Order) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportOrders", ctx, from, to, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportOrders indicates an expected call of ExportOrders.
+func (mr *MockRepoMockRecorder) ExportOrders(ctx, from, to, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportOrders", reflect.TypeOf((*MockRepo)(nil).ExportOrders), ctx, from, to, fn)
+}
+
+// FindOrdersByContact mocks base method.
+func (m *MockRepo) FindOrdersByContact(ctx context.Context, email, phone string) ([]*model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOrdersByContact", ctx, email, phone)
+	ret0, _ := ret[0].([]*model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOrdersByContact indicates an expected call of FindOrdersByContact.
+func (mr *MockRepoMockRecorder) FindOrdersByContact(ctx, email, phone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrdersByContact", reflect.TypeOf((*MockRepo)(nil).FindOrdersByContact), ctx, email, phone)
+}
+
 // GetAllOrders mocks base method.
 func (m *MockRepo) GetAllOrders(ctx context.Context) ([]*model.Order, error) {
 	m.ctrl.T.Helper()
@@ -85,3 +144,33 @@ func (mr *MockRepoMockRecorder) GetOrderByID(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderByID", reflect.TypeOf((*MockRepo)(nil).GetOrderByID), ctx, id)
 }
+
+// OrderHistory mocks base method.
+func (m *MockRepo) OrderHistory(ctx context.Context, id string) ([]model.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrderHistory", ctx, id)
+	ret0, _ := ret[0].([]model.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OrderHistory indicates an expected call of OrderHistory.
+func (mr *MockRepoMockRecorder) OrderHistory(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderHistory", reflect.TypeOf((*MockRepo)(nil).OrderHistory), ctx, id)
+}
+
+// SetItemStatus mocks base method.
+func (m *MockRepo) SetItemStatus(ctx context.Context, upd model.StatusUpdate) (*model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetItemStatus", ctx, upd)
+	ret0, _ := ret[0].(*model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetItemStatus indicates an expected call of SetItemStatus.
+func (mr *MockRepoMockRecorder) SetItemStatus(ctx, upd any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetItemStatus", reflect.TypeOf((*MockRepo)(nil).SetItemStatus), ctx, upd)
+}
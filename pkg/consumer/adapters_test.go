@@ -0,0 +1,77 @@
+package consumer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestSegmentioConversionKeepsHeaders(t *testing.T) {
+	in := kafka.Message{
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    41,
+		Key:       []byte("k"),
+		Value:     []byte("v"),
+		Headers:   []kafka.Header{{Key: "content-type", Value: []byte("application/json")}},
+	}
+	msg := fromKafka(in)
+	if msg.Topic != "orders" || msg.Partition != 2 || msg.Offset != 41 {
+		t.Fatalf("position not kept: %+v", msg)
+	}
+	if msg.Header("Content-Type") != "application/json" {
+		t.Fatalf("header not kept: %+v", msg.Headers)
+	}
+
+	out := toKafka(msg)
+	if !reflect.DeepEqual(out.Headers, in.Headers) || string(out.Key) != "k" || string(out.Value) != "v" {
+		t.Fatalf("round trip mismatch: %+v", out)
+	}
+}
+
+func TestFranzConversionKeepsHeaders(t *testing.T) {
+	in := &kgo.Record{
+		Topic:     "orders",
+		Partition: 1,
+		Offset:    7,
+		Key:       []byte("k"),
+		Value:     []byte("v"),
+		Headers:   []kgo.RecordHeader{{Key: "schema-version", Value: []byte("2")}},
+	}
+	msg := fromRecord(in)
+	if msg.Topic != "orders" || msg.Partition != 1 || msg.Offset != 7 {
+		t.Fatalf("position not kept: %+v", msg)
+	}
+
+	out := toRecord(msg)
+	if !reflect.DeepEqual(out.Headers, in.Headers) || string(out.Key) != "k" || string(out.Value) != "v" {
+		t.Fatalf("round trip mismatch: %+v", out)
+	}
+}
+
+func TestFranzFetchErrorsReportsEveryPartition(t *testing.T) {
+	errA, errB := errors.New("leader not available"), errors.New("offset out of range")
+	fetches := kgo.Fetches{{Topics: []kgo.FetchTopic{{
+		Topic: "orders",
+		Partitions: []kgo.FetchPartition{
+			{Partition: 0, Records: []*kgo.Record{{Topic: "orders"}}},
+			{Partition: 1, Err: errA},
+			{Partition: 2, Err: errB},
+		},
+	}}}}
+
+	err := fetchErrors(fetches)
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Fatalf("expected both partition errors, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "orders[2]") {
+		t.Fatalf("expected the partition in the error, got %v", err)
+	}
+	if err := fetchErrors(kgo.Fetches{}); err != nil {
+		t.Fatalf("expected no error for an empty fetch, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryBroker_KeyedMessagesShareAPartition(t *testing.T) {
+	b := NewMemoryBroker(4)
+	sink := b.Sink()
+	for _, key := range []string{"a", "b", "a", "c", "a"} {
+		if err := sink.WriteMessages(context.Background(), Message{Topic: "orders", Key: []byte(key), Value: []byte(key)}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := sink.WriteMessages(context.Background(), Message{Key: []byte("a")}); !errors.Is(err, ErrNoTopic) {
+		t.Fatalf("expected ErrNoTopic, got %v", err)
+	}
+
+	partition := map[string]int{}
+	next := map[int]int64{}
+	for _, m := range b.Messages("orders") {
+		if p, ok := partition[string(m.Key)]; ok && p != m.Partition {
+			t.Fatalf("key %s in partitions %d and %d", m.Key, p, m.Partition)
+		}
+		partition[string(m.Key)] = m.Partition
+		if m.Offset != next[m.Partition] {
+			t.Fatalf("expected offset %d in partition %d, got %d", next[m.Partition], m.Partition, m.Offset)
+		}
+		next[m.Partition]++
+	}
+	if len(b.Messages("orders")) != 5 {
+		t.Fatalf("expected 5 messages, got %d", len(b.Messages("orders")))
+	}
+}
+
+func TestMemoryBroker_SourceResumesFromCommitted(t *testing.T) {
+	b := NewMemoryBroker(1)
+	for _, v := range []string{"1", "2", "3"} {
+		if err := b.Produce(Message{Topic: "orders", Value: []byte(v)}); err != nil {
+			t.Fatalf("produce: %v", err)
+		}
+	}
+
+	ctx := context.Background()
+	src := b.Source("orders")
+	if lag := src.Lag(); lag != 3 {
+		t.Fatalf("expected lag 3, got %d", lag)
+	}
+	first, err := src.FetchMessage(ctx)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	second, err := src.FetchMessage(ctx)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if string(first.Value) != "1" || string(second.Value) != "2" || src.Lag() != 1 {
+		t.Fatalf("unexpected messages %s, %s, lag %d", first.Value, second.Value, src.Lag())
+	}
+	// Committing an older message does not move the offset back.
+	if err := src.CommitMessages(ctx, second, first); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if got := b.Committed("orders", 0); got != 2 {
+		t.Fatalf("expected committed offset 2, got %d", got)
+	}
+	src.Close()
+
+	src = b.Source("orders")
+	defer src.Close()
+	msg, err := src.FetchMessage(ctx)
+	if err != nil || string(msg.Value) != "3" {
+		t.Fatalf("expected to resume at the third message, got %s, %v", msg.Value, err)
+	}
+}
+
+func TestMemoryBroker_FetchBlocksUntilProducedOrStopped(t *testing.T) {
+	b := NewMemoryBroker(2)
+	src := b.Source("orders")
+
+	got := make(chan Message, 1)
+	go func() {
+		msg, err := src.FetchMessage(context.Background())
+		if err != nil {
+			t.Errorf("fetch: %v", err)
+		}
+		got <- msg
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := b.Produce(Message{Topic: "orders", Value: []byte("late")}); err != nil {
+		t.Fatalf("produce: %v", err)
+	}
+	select {
+	case msg := <-got:
+		if string(msg.Value) != "late" {
+			t.Fatalf("unexpected message %s", msg.Value)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetch did not wake up for a new message")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := src.FetchMessage(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to end the fetch, got %v", err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := src.FetchMessage(context.Background())
+		errs <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	src.Close()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrClosed) {
+			t.Fatalf("expected ErrClosed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("close did not end the fetch")
+	}
+}
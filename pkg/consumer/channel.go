@@ -0,0 +1,63 @@
+package consumer
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// ChannelConsumer is an in-process message source for running without a
+// broker. Failed messages are logged and dropped: there is no retry topic or DLQ.
+type ChannelConsumer struct {
+	ch        chan Message
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewChannelConsumer(buffer int) *ChannelConsumer {
+	return &ChannelConsumer{
+		ch:   make(chan Message, buffer),
+		done: make(chan struct{}),
+	}
+}
+
+// Publish queues msg, blocking while the buffer is full.
+func (c *ChannelConsumer) Publish(ctx context.Context, msg Message) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case c.ch <- msg:
+		return nil
+	case <-c.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *ChannelConsumer) Consume(ctx context.Context, handler Handler) error {
+	log.Println("channel consumer: started")
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("channel consumer: stopping due to context done")
+			return nil
+		case <-c.done:
+			return nil
+		case msg := <-c.ch:
+			if err := handler(context.WithoutCancel(ctx), msg); err != nil {
+				log.Printf("channel consumer: dropping message: %v", err)
+			}
+		}
+	}
+}
+
+// Close makes further Publish calls fail. Messages still buffered are dropped.
+func (c *ChannelConsumer) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return nil
+}
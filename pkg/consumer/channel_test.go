@@ -0,0 +1,52 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChannelConsumer_DeliversAndStopsOnClose(t *testing.T) {
+	c := NewChannelConsumer(1)
+	got := make(chan Message, 2)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Consume(context.Background(), func(ctx context.Context, msg Message) error {
+			got <- msg
+			return errors.New("dropped, not retried")
+		})
+	}()
+
+	ctx := context.Background()
+	if err := c.Publish(ctx, Message{Value: []byte("a")}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if err := c.Publish(ctx, Message{Value: []byte("b")}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	for _, want := range []string{"a", "b"} {
+		select {
+		case msg := <-got:
+			if string(msg.Value) != want {
+				t.Fatalf("expected %q, got %q", want, msg.Value)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("message %q not delivered", want)
+		}
+	}
+
+	c.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("consume returned %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("consume did not return after Close")
+	}
+	if err := c.Publish(ctx, Message{}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed after Close, got %v", err)
+	}
+}
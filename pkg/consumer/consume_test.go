@@ -0,0 +1,393 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testTopic = "orders"
+	testRetry = "orders_retry"
+	testDLQ   = "orders_dlq"
+)
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// runConsumer starts c.Consume in the background and returns a function that
+// stops it and waits for it to return.
+func runConsumer(t *testing.T, c *Consumer, handler Handler) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Consume(ctx, handler) }()
+
+	return func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("consume returned %v", err)
+		}
+	}
+}
+
+func TestConsume_CommitsHandledMessages(t *testing.T) {
+	b := NewMemoryBroker(1)
+	for i := 0; i < 3; i++ {
+		b.Produce(Message{Topic: testTopic, Value: []byte(strconv.Itoa(i))})
+	}
+
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	c := NewConsumer(b.Source(testTopic), b.Sink(), WithTopics(testRetry, testDLQ))
+	stop := runConsumer(t, c, func(ctx context.Context, msg Message) error {
+		mu.Lock()
+		seen = append(seen, string(msg.Value))
+		mu.Unlock()
+		return nil
+	})
+
+	waitFor(t, "commit of all messages", func() bool { return b.Committed(testTopic, 0) == 3 })
+	stop()
+
+	if fmt.Sprint(seen) != "[0 1 2]" {
+		t.Fatalf("expected messages in order, got %v", seen)
+	}
+	if n := len(b.Messages(testRetry)) + len(b.Messages(testDLQ)); n != 0 {
+		t.Fatalf("expected no retried or dead-lettered messages, got %d", n)
+	}
+	if lag := c.Lag(); lag != 0 {
+		t.Fatalf("expected zero lag, got %d", lag)
+	}
+}
+
+func TestConsume_RetriesThenDeadLetters(t *testing.T) {
+	b := NewMemoryBroker(1)
+	b.Produce(Message{
+		Topic:   testTopic,
+		Key:     []byte("order-1"),
+		Value:   []byte(`{}`),
+		Headers: map[string]string{"content-type": "application/json"},
+	})
+
+	failing := func(ctx context.Context, msg Message) error {
+		return errors.New("db unavailable")
+	}
+	// The retry topic is consumed by its own reader, as a deployment would.
+	main := NewConsumer(b.Source(testTopic), b.Sink(), WithTopics(testRetry, testDLQ))
+	retry := NewConsumer(b.Source(testRetry), b.Sink(), WithTopics(testRetry, testDLQ))
+	stopMain := runConsumer(t, main, failing)
+	stopRetry := runConsumer(t, retry, failing)
+
+	waitFor(t, "message in DLQ", func() bool { return len(b.Messages(testDLQ)) == 1 })
+	waitFor(t, "retry offsets committed", func() bool { return b.Committed(testRetry, 0) == maxRetries })
+	stopMain()
+	stopRetry()
+
+	retries := b.Messages(testRetry)
+	if len(retries) != maxRetries {
+		t.Fatalf("expected %d retries, got %d", maxRetries, len(retries))
+	}
+	for i, m := range retries {
+		if got := m.Header("x-retry-count"); got != strconv.Itoa(i+1) {
+			t.Fatalf("retry %d: expected x-retry-count %d, got %q", i, i+1, got)
+		}
+		if got := m.Header("content-type"); got != "application/json" {
+			t.Fatalf("retry %d: content-type not forwarded, got %q", i, got)
+		}
+		if string(m.Key) != "order-1" {
+			t.Fatalf("retry %d: key not preserved, got %q", i, m.Key)
+		}
+	}
+
+	dead := b.Messages(testDLQ)[0]
+	if got := dead.Header("x-original-topic"); got != testRetry {
+		t.Fatalf("expected x-original-topic %q, got %q", testRetry, got)
+	}
+	if got := dead.Header("x-error"); got != "db unavailable" {
+		t.Fatalf("expected x-error to carry the handler error, got %q", got)
+	}
+	if got := b.Committed(testTopic, 0); got != 1 {
+		t.Fatalf("expected original message committed, got offset %d", got)
+	}
+}
+
+func TestConsume_PermanentErrorSkipsRetries(t *testing.T) {
+	b := NewMemoryBroker(1)
+	b.Produce(Message{Topic: testTopic, Value: []byte("not json")})
+
+	c := NewConsumer(b.Source(testTopic), b.Sink(), WithTopics(testRetry, testDLQ))
+	stop := runConsumer(t, c, func(ctx context.Context, msg Message) error {
+		return Permanent(errors.New("decode failed"))
+	})
+
+	waitFor(t, "message in DLQ", func() bool { return len(b.Messages(testDLQ)) == 1 })
+	waitFor(t, "commit", func() bool { return b.Committed(testTopic, 0) == 1 })
+	stop()
+
+	if n := len(b.Messages(testRetry)); n != 0 {
+		t.Fatalf("permanent errors must not be retried, got %d retries", n)
+	}
+	if got := b.Messages(testDLQ)[0].Header("x-original-topic"); got != testTopic {
+		t.Fatalf("expected x-original-topic %q, got %q", testTopic, got)
+	}
+}
+
+func TestConsume_FinishesInFlightMessageOnCancel(t *testing.T) {
+	b := NewMemoryBroker(1)
+	b.Produce(Message{Topic: testTopic, Value: []byte("slow")})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c := NewConsumer(b.Source(testTopic), b.Sink(), WithTopics(testRetry, testDLQ))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Consume(ctx, func(hctx context.Context, msg Message) error {
+			close(started)
+			<-release
+			return hctx.Err()
+		})
+	}()
+
+	<-started
+	cancel()
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("consume returned %v", err)
+	}
+
+	if got := b.Committed(testTopic, 0); got != 1 {
+		t.Fatalf("expected in-flight message committed after cancel, got offset %d", got)
+	}
+	if n := len(b.Messages(testRetry)); n != 0 {
+		t.Fatalf("handler must not see a cancelled context, got %d retries", n)
+	}
+}
+
+func TestConsume_ParallelKeepsKeyOrderAndCommitsAll(t *testing.T) {
+	const partitions, total = 3, 150
+	b := NewMemoryBroker(partitions)
+	for i := 0; i < total; i++ {
+		b.Produce(Message{
+			Topic: testTopic,
+			Key:   []byte(fmt.Sprintf("key-%d", i%7)),
+			Value: []byte(strconv.Itoa(i)),
+		})
+	}
+
+	var (
+		mu    sync.Mutex
+		byKey = map[string][]int{}
+	)
+	c := NewConsumer(b.Source(testTopic), b.Sink(),
+		WithTopics(testRetry, testDLQ),
+		WithWorkers(4, OrderByKey),
+	)
+	stop := runConsumer(t, c, func(ctx context.Context, msg Message) error {
+		v, _ := strconv.Atoi(string(msg.Value))
+		mu.Lock()
+		byKey[string(msg.Key)] = append(byKey[string(msg.Key)], v)
+		mu.Unlock()
+		return nil
+	})
+
+	waitFor(t, "all partitions committed", func() bool {
+		var committed int64
+		for p := 0; p < partitions; p++ {
+			committed += b.Committed(testTopic, p)
+		}
+		return committed == total
+	})
+	stop()
+
+	handled := 0
+	for key, vals := range byKey {
+		handled += len(vals)
+		for i := 1; i < len(vals); i++ {
+			if vals[i] < vals[i-1] {
+				t.Fatalf("key %s handled out of order: %v", key, vals)
+			}
+		}
+	}
+	if handled != total {
+		t.Fatalf("expected %d handled messages, got %d", total, handled)
+	}
+}
+
+func TestConsume_ResumesFromCommittedOffset(t *testing.T) {
+	b := NewMemoryBroker(1)
+	for i := 0; i < 3; i++ {
+		b.Produce(Message{Topic: testTopic, Value: []byte(strconv.Itoa(i))})
+	}
+
+	first := NewConsumer(b.Source(testTopic), b.Sink(), WithTopics(testRetry, testDLQ))
+	stop := runConsumer(t, first, func(ctx context.Context, msg Message) error { return nil })
+	waitFor(t, "first run commits", func() bool { return b.Committed(testTopic, 0) == 3 })
+	stop()
+
+	b.Produce(Message{Topic: testTopic, Value: []byte("3")})
+
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	second := NewConsumer(b.Source(testTopic), b.Sink(), WithTopics(testRetry, testDLQ))
+	stop = runConsumer(t, second, func(ctx context.Context, msg Message) error {
+		mu.Lock()
+		seen = append(seen, string(msg.Value))
+		mu.Unlock()
+		return nil
+	})
+	waitFor(t, "second run commits", func() bool { return b.Committed(testTopic, 0) == 4 })
+	stop()
+
+	if fmt.Sprint(seen) != "[3]" {
+		t.Fatalf("expected only the new message after restart, got %v", seen)
+	}
+}
+
+// failingSink fails the first failures writes, or all of them when failures
+// is negative, and passes the rest to the wrapped sink.
+type failingSink struct {
+	MessageSink
+	mu       sync.Mutex
+	failures int
+	attempts int
+}
+
+func (s *failingSink) WriteMessages(ctx context.Context, msgs ...Message) error {
+	s.mu.Lock()
+	s.attempts++
+	fail := s.failures < 0 || s.attempts <= s.failures
+	s.mu.Unlock()
+	if fail {
+		return errors.New("broker unavailable")
+	}
+	return s.MessageSink.WriteMessages(ctx, msgs...)
+}
+
+func (s *failingSink) Attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts
+}
+
+func TestConsume_FailedSinkWriteLeavesOffsetUncommitted(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			b := NewMemoryBroker(1)
+			b.Produce(Message{Topic: testTopic, Value: []byte("0")})
+			b.Produce(Message{Topic: testTopic, Value: []byte("1")})
+
+			sink := &failingSink{MessageSink: b.Sink(), failures: -1}
+			c := NewConsumer(b.Source(testTopic), sink, WithTopics(testRetry, testDLQ), WithWorkers(workers, OrderByPartition))
+			stop := runConsumer(t, c, func(ctx context.Context, msg Message) error {
+				if string(msg.Value) == "0" {
+					return errors.New("db unavailable")
+				}
+				return nil
+			})
+
+			waitFor(t, "repeated retry writes", func() bool { return sink.Attempts() >= 2 })
+			stop()
+
+			if got := b.Committed(testTopic, 0); got != 0 {
+				t.Fatalf("expected nothing committed while the retry write fails, got offset %d", got)
+			}
+		})
+	}
+}
+
+func TestConsume_RetriesSinkWriteUntilItSucceeds(t *testing.T) {
+	b := NewMemoryBroker(1)
+	b.Produce(Message{Topic: testTopic, Value: []byte("0")})
+
+	sink := &failingSink{MessageSink: b.Sink(), failures: 2}
+	c := NewConsumer(b.Source(testTopic), sink, WithTopics(testRetry, testDLQ))
+	stop := runConsumer(t, c, func(ctx context.Context, msg Message) error {
+		return errors.New("db unavailable")
+	})
+
+	waitFor(t, "commit after the retry write", func() bool { return b.Committed(testTopic, 0) == 1 })
+	stop()
+
+	if n := len(b.Messages(testRetry)); n != 1 || sink.Attempts() != 3 {
+		t.Fatalf("expected one retry message after 3 attempts, got %d after %d", n, sink.Attempts())
+	}
+}
+
+func TestConsume_RetryTopicOnTheSameSourceAfterDelay(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	b := NewMemoryBroker(2)
+	b.Produce(Message{Topic: testTopic, Key: []byte("order-1"), Value: []byte("status")})
+
+	var (
+		mu      sync.Mutex
+		handled []time.Time
+	)
+	c := NewConsumer(b.Source(testTopic, testRetry), b.Sink(), WithTopics(testRetry, testDLQ), WithRetryDelay(delay))
+	stop := runConsumer(t, c, func(ctx context.Context, msg Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, time.Now())
+		if msg.Topic == testTopic {
+			return errors.New("order not found")
+		}
+		return nil
+	})
+
+	waitFor(t, "retry committed", func() bool {
+		var n int64
+		for p := 0; p < 2; p++ {
+			n += b.Committed(testRetry, p)
+		}
+		return n == 1
+	})
+	stop()
+
+	if len(handled) != 2 {
+		t.Fatalf("expected the message and its retry to be handled, got %d", len(handled))
+	}
+	if gap := handled[1].Sub(handled[0]); gap < delay {
+		t.Fatalf("expected the retry after %s, got it after %s", delay, gap)
+	}
+	if n := len(b.Messages(testDLQ)); n != 0 {
+		t.Fatalf("expected nothing in the DLQ, got %d", n)
+	}
+}
+
+func TestConsume_StopDuringRetryDelayLeavesRetryUncommitted(t *testing.T) {
+	b := NewMemoryBroker(1)
+	b.Produce(Message{Topic: testRetry, Value: []byte("later"), Headers: map[string]string{
+		"x-retry-count": "1",
+		"x-retry-after": time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano),
+	}})
+
+	c := NewConsumer(b.Source(testTopic, testRetry), b.Sink(), WithTopics(testRetry, testDLQ))
+	stop := runConsumer(t, c, func(ctx context.Context, msg Message) error {
+		t.Errorf("retry handled before it was due")
+		return nil
+	})
+	time.Sleep(20 * time.Millisecond)
+	stop()
+
+	if got := b.Committed(testRetry, 0); got != 0 {
+		t.Fatalf("expected the pending retry to stay uncommitted, got offset %d", got)
+	}
+}
@@ -4,33 +4,138 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+}
+
+// Header returns the value of the header key, matched case-insensitively.
+func (m Message) Header(key string) string {
+	if v, ok := m.Headers[key]; ok {
+		return v
+	}
+	for k, v := range m.Headers {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+type Handler func(ctx context.Context, msg Message) error
 
-	"github.com/segmentio/kafka-go"
+const maxRetries = 3
+
+// Failed retry and DLQ writes are repeated with a backoff between these
+// bounds until they succeed or fetching stops.
+const (
+	sinkBackoffMin = 100 * time.Millisecond
+	sinkBackoffMax = 10 * time.Second
 )
 
 type Consumer struct {
-	reader *kafka.Reader
-	writer *kafka.Writer
+	source     MessageSource
+	sink       MessageSink
+	retryTopic string
+	dlqTopic   string
+
+	workers  int
+	ordering Ordering
+
+	retryDelay time.Duration
 }
 
-func NewConsumer(reader *kafka.Reader, writer *kafka.Writer) *Consumer {
-	return &Consumer{reader: reader, writer: writer}
+type Option func(*Consumer)
+
+// WithTopics sets where failed messages are published.
+func WithTopics(retryTopic, dlqTopic string) Option {
+	return func(c *Consumer) {
+		c.retryTopic = retryTopic
+		c.dlqTopic = dlqTopic
+	}
 }
 
-func getRetryCount(msg kafka.Message) int {
-	for _, h := range msg.Headers {
-		if h.Key == "x-retry-count" {
-			var count int
-			fmt.Sscanf(string(h.Value), "%d", &count)
-			return count
-		}
+// WithRetryDelay delays retried messages: the nth retry is handled no sooner
+// than d*2^(n-1) after it was published. The retry topic must be fetched by
+// the same source, or by a consumer of its own.
+func WithRetryDelay(d time.Duration) Option {
+	return func(c *Consumer) {
+		c.retryDelay = d
+	}
+}
+
+// WithWorkers enables concurrent processing with n workers. Messages that
+// share a key (or a partition, depending on ordering) always go to the same
+// worker, so their relative order is preserved.
+func WithWorkers(n int, ordering Ordering) Option {
+	return func(c *Consumer) {
+		c.workers = n
+		c.ordering = ordering
 	}
-	return 0
 }
 
-func (c *Consumer) Consume(ctx context.Context, handler func(ctx context.Context, key, value []byte) error) error {
-	if c.reader == nil {
-		return fmt.Errorf("kafka: reader is nil")
+func NewConsumer(source MessageSource, sink MessageSink, opts ...Option) *Consumer {
+	c := &Consumer{source: source, sink: sink, workers: 1, ordering: OrderByKey}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// forwardHeaders copies the original headers (e.g. content-type) onto a
+// retried or dead-lettered message, replacing any previous value of override keys.
+func forwardHeaders(msg Message, overrides map[string]string) map[string]string {
+	headers := make(map[string]string, len(msg.Headers)+len(overrides))
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	for k, v := range overrides {
+		headers[k] = v
+	}
+	return headers
+}
+
+func getRetryCount(msg Message) int {
+	count, _ := strconv.Atoi(msg.Header("x-retry-count"))
+	return count
+}
+
+// waitRetryAfter blocks until the x-retry-after time of a retried message,
+// or returns an error once stop is done.
+func waitRetryAfter(stop context.Context, msg Message) error {
+	at, err := time.Parse(time.RFC3339Nano, msg.Header("x-retry-after"))
+	if err != nil {
+		return nil
+	}
+	wait := time.Until(at)
+	if wait <= 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-stop.Done():
+		return fmt.Errorf("kafka: retry of offset %d not due yet: %w", msg.Offset, stop.Err())
+	case <-t.C:
+		return nil
+	}
+}
+
+func (c *Consumer) Consume(ctx context.Context, handler Handler) error {
+	if c.source == nil {
+		return fmt.Errorf("kafka: source is nil")
+	}
+	if c.workers > 1 {
+		return c.consumeParallel(ctx, handler)
 	}
 
 	log.Println("kafka: consumer started")
@@ -42,7 +147,7 @@ func (c *Consumer) Consume(ctx context.Context, handler func(ctx context.Context
 		default:
 		}
 
-		msg, err := c.reader.FetchMessage(ctx)
+		msg, err := c.source.FetchMessage(ctx)
 		if err != nil {
 			if ctx.Err() != nil {
 				log.Println("kafka: context canceled, stopping consumer loop")
@@ -52,54 +157,122 @@ func (c *Consumer) Consume(ctx context.Context, handler func(ctx context.Context
 			continue
 		}
 
-		if err := handler(ctx, msg.Key, msg.Value); err != nil {
-			retryCount := getRetryCount(msg) + 1
-
-			if retryCount <= 3 {
-				log.Printf("kafka: handler failed, retry #%d for offset %d", retryCount, msg.Offset)
-
-				retryMsg := kafka.Message{
-					Key:   msg.Key,
-					Value: msg.Value,
-					Headers: []kafka.Header{
-						{Key: "x-retry-count", Value: []byte(fmt.Sprintf("%d", retryCount))},
-					},
-				}
-
-				if err := c.writer.WriteMessages(ctx, retryMsg); err != nil {
-					log.Printf("kafka: failed to publish retry message: %v", err)
-				}
-			} else {
-				log.Printf("kafka: sending message offset %d to DLQ", msg.Offset)
-				dlqMsg := kafka.Message{
-					Key:   msg.Key,
-					Value: msg.Value,
-					Headers: []kafka.Header{
-						{Key: "x-original-topic", Value: []byte(c.reader.Config().Topic)},
-					},
-				}
-
-				if err := c.writer.WriteMessages(ctx, dlqMsg); err != nil {
-					log.Printf("kafka: failed to send to DLQ: %v", err)
-				}
-			}
+		// The in-flight message is finished and committed even if ctx is
+		// cancelled meanwhile: cancelling ctx only stops fetching.
+		procCtx := context.WithoutCancel(ctx)
+		if err := c.process(procCtx, ctx, msg, handler); err != nil {
+			// Committing a later message would skip this one, so stop
+			// here; it is redelivered after restart.
+			log.Printf("kafka: offset %d left uncommitted, stopping consumer loop: %v", msg.Offset, err)
+			return nil
+		}
+	}
+}
 
-			if err := c.reader.CommitMessages(ctx, msg); err != nil {
-				log.Printf("kafka: failed to commit after error: %v", err)
-			}
+func (c *Consumer) process(ctx, stop context.Context, msg Message, handler Handler) error {
+	if err := c.handle(ctx, stop, msg, handler); err != nil {
+		return err
+	}
 
-			continue
+	if err := c.source.CommitMessages(ctx, msg); err != nil {
+		log.Printf("kafka: failed to commit offset %d: %v\n", msg.Offset, err)
+	}
+	return nil
+}
+
+// handle runs handler and routes failures to the retry topic or the DLQ. The
+// message is done once it returns nil; committing is up to the caller. An
+// error means the retry or DLQ write did not succeed before stop was done,
+// and the message must not be committed.
+func (c *Consumer) handle(ctx, stop context.Context, msg Message, handler Handler) error {
+	if err := waitRetryAfter(stop, msg); err != nil {
+		return err
+	}
+	err := handler(ctx, msg)
+	if err == nil {
+		return nil
+	}
+
+	retryCount := getRetryCount(msg) + 1
+	if retryCount <= maxRetries && !IsPermanent(err) {
+		log.Printf("kafka: handler failed, retry #%d for offset %d: %v", retryCount, msg.Offset, err)
+
+		retryMsg := Message{
+			Topic: c.retryTopic,
+			Key:   msg.Key,
+			Value: msg.Value,
+			Headers: forwardHeaders(msg, map[string]string{
+				"x-retry-count": strconv.Itoa(retryCount),
+				"x-retry-after": time.Now().Add(c.retryDelay << (retryCount - 1)).UTC().Format(time.RFC3339Nano),
+				"x-error":       err.Error(),
+			}),
 		}
 
-		if err := c.reader.CommitMessages(ctx, msg); err != nil {
-			log.Printf("kafka: failed to commit offset %d: %v\n", msg.Offset, err)
+		if err := c.write(ctx, stop, retryMsg); err != nil {
+			return fmt.Errorf("kafka: publish retry message: %w", err)
+		}
+		return nil
+	}
+
+	log.Printf("kafka: sending message offset %d to DLQ: %v", msg.Offset, err)
+	dlqMsg := Message{
+		Topic: c.dlqTopic,
+		Key:   msg.Key,
+		Value: msg.Value,
+		Headers: forwardHeaders(msg, map[string]string{
+			"x-original-topic": msg.Topic,
+			"x-error":          err.Error(),
+		}),
+	}
+
+	if err := c.write(ctx, stop, dlqMsg); err != nil {
+		return fmt.Errorf("kafka: send to DLQ: %w", err)
+	}
+	return nil
+}
+
+// write publishes msg, retrying with backoff until it succeeds or stop is done.
+func (c *Consumer) write(ctx, stop context.Context, msg Message) error {
+	delay := sinkBackoffMin
+	for {
+		err := c.sink.WriteMessages(ctx, msg)
+		if err == nil {
+			return nil
+		}
+		log.Printf("kafka: failed to write to %s, retrying in %s: %v", msg.Topic, delay, err)
+		select {
+		case <-stop.Done():
+			return err
+		case <-time.After(delay):
 		}
+		delay = min(2*delay, sinkBackoffMax)
+	}
+}
+
+func (c *Consumer) Ping(ctx context.Context) error {
+	if c.source == nil {
+		return fmt.Errorf("kafka: source is nil")
+	}
+	return c.source.Ping(ctx)
+}
+
+func (c *Consumer) Lag() int64 {
+	if c.source == nil {
+		return -1
 	}
+	return c.source.Lag()
 }
 
 func (c *Consumer) Close() error {
-	if c.reader != nil {
-		return c.reader.Close()
+	if c.source != nil {
+		return c.source.Close()
+	}
+	return nil
+}
+
+func (c *Consumer) CloseWriter() error {
+	if c.sink != nil {
+		return c.sink.Close()
 	}
 	return nil
 }
@@ -0,0 +1,34 @@
+package consumer
+
+import "errors"
+
+var (
+	ErrClosed  = errors.New("consumer: closed")
+	ErrNoTopic = errors.New("consumer: message has no topic")
+)
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as not worth retrying: the message goes straight to the
+// DLQ, e.g. when its payload cannot be decoded.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
@@ -0,0 +1,204 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+type franzSource struct {
+	cl  *kgo.Client
+	buf []*kgo.Record
+
+	mu        sync.Mutex
+	watermark map[topicPartition]int64
+	fetched   map[topicPartition]int64
+	revokeFn  func([]topicPartition)
+}
+
+// NewFranzSource creates a group consuming client from opts, which must
+// include kgo.DisableAutoCommit; offsets are committed through
+// CommitMessages. The source installs its own OnPartitionsRevoked and
+// OnPartitionsLost hooks, overriding any in opts.
+func NewFranzSource(opts ...kgo.Opt) (MessageSource, error) {
+	s := &franzSource{
+		watermark: make(map[topicPartition]int64),
+		fetched:   make(map[topicPartition]int64),
+	}
+	opts = append(opts,
+		kgo.OnPartitionsRevoked(s.revoked),
+		kgo.OnPartitionsLost(s.revoked),
+	)
+	cl, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.cl = cl
+	return s, nil
+}
+
+// revoked forgets partitions the group took away and reports them to the
+// consumer, which drops their uncommitted offsets.
+func (s *franzSource) revoked(_ context.Context, _ *kgo.Client, partitions map[string][]int32) {
+	var tps []topicPartition
+	for topic, ps := range partitions {
+		for _, p := range ps {
+			tps = append(tps, topicPartition{topic, int(p)})
+		}
+	}
+
+	s.mu.Lock()
+	for _, tp := range tps {
+		delete(s.watermark, tp)
+		delete(s.fetched, tp)
+	}
+	fn := s.revokeFn
+	s.mu.Unlock()
+
+	if fn != nil {
+		fn(tps)
+	}
+}
+
+func (s *franzSource) onRevoked(fn func([]topicPartition)) {
+	s.mu.Lock()
+	s.revokeFn = fn
+	s.mu.Unlock()
+}
+
+func (s *franzSource) FetchMessage(ctx context.Context) (Message, error) {
+	for len(s.buf) == 0 {
+		fetches := s.cl.PollFetches(ctx)
+		if fetches.IsClientClosed() {
+			return Message{}, ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return Message{}, err
+		}
+
+		s.mu.Lock()
+		fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+			s.watermark[topicPartition{p.Topic, int(p.Partition)}] = p.HighWatermark
+		})
+		s.mu.Unlock()
+
+		s.buf = fetches.Records()
+		if err := fetchErrors(fetches); err != nil {
+			if len(s.buf) == 0 {
+				return Message{}, err
+			}
+			// The client keeps fetching the failed partitions; the records
+			// of the others are processed meanwhile.
+			log.Printf("kafka: fetch error: %v", err)
+		}
+	}
+
+	r := s.buf[0]
+	s.buf[0] = nil
+	s.buf = s.buf[1:]
+
+	s.mu.Lock()
+	s.fetched[topicPartition{r.Topic, int(r.Partition)}] = r.Offset
+	s.mu.Unlock()
+
+	return fromRecord(r), nil
+}
+
+// fetchErrors joins the errors of all partitions of a fetch.
+func fetchErrors(fetches kgo.Fetches) error {
+	var errs []error
+	for _, e := range fetches.Errors() {
+		errs = append(errs, fmt.Errorf("%s[%d]: %w", e.Topic, e.Partition, e.Err))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *franzSource) CommitMessages(ctx context.Context, msgs ...Message) error {
+	recs := make([]*kgo.Record, len(msgs))
+	for i, m := range msgs {
+		recs[i] = &kgo.Record{
+			Topic:       m.Topic,
+			Partition:   int32(m.Partition),
+			Offset:      m.Offset,
+			LeaderEpoch: -1,
+		}
+	}
+	return s.cl.CommitRecords(ctx, recs...)
+}
+
+func (s *franzSource) Lag() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.watermark) == 0 {
+		return -1
+	}
+	var lag int64
+	for tp, hw := range s.watermark {
+		next, ok := s.fetched[tp]
+		if ok {
+			next++
+		}
+		if hw > next {
+			lag += hw - next
+		}
+	}
+	return lag
+}
+
+func (s *franzSource) Ping(ctx context.Context) error {
+	return s.cl.Ping(ctx)
+}
+
+func (s *franzSource) Close() error {
+	s.cl.Close()
+	return nil
+}
+
+type franzSink struct {
+	cl *kgo.Client
+}
+
+func NewFranzSink(cl *kgo.Client) MessageSink {
+	return &franzSink{cl: cl}
+}
+
+func (s *franzSink) WriteMessages(ctx context.Context, msgs ...Message) error {
+	recs := make([]*kgo.Record, len(msgs))
+	for i, m := range msgs {
+		recs[i] = toRecord(m)
+	}
+	return s.cl.ProduceSync(ctx, recs...).FirstErr()
+}
+
+func (s *franzSink) Close() error {
+	s.cl.Close()
+	return nil
+}
+
+func fromRecord(r *kgo.Record) Message {
+	headers := make(map[string]string, len(r.Headers))
+	for _, h := range r.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	return Message{
+		Topic:     r.Topic,
+		Partition: int(r.Partition),
+		Offset:    r.Offset,
+		Key:       r.Key,
+		Value:     r.Value,
+		Headers:   headers,
+	}
+}
+
+func toRecord(msg Message) *kgo.Record {
+	headers := make([]kgo.RecordHeader, 0, len(msg.Headers))
+	for k, v := range msg.Headers {
+		headers = append(headers, kgo.RecordHeader{Key: k, Value: []byte(v)})
+	}
+	return &kgo.Record{Topic: msg.Topic, Key: msg.Key, Value: msg.Value, Headers: headers}
+}
@@ -0,0 +1,212 @@
+package consumer
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+)
+
+// MemoryBroker is an in-process stand-in for Kafka, used in tests. Topics
+// are append-only logs split into partitions, and committed offsets are kept
+// per topic and partition as for a single consumer group.
+type MemoryBroker struct {
+	mu         sync.Mutex
+	cond       *sync.Cond
+	partitions int
+	logs       map[string][][]Message
+	committed  map[topicPartition]int64
+	next       int
+}
+
+func NewMemoryBroker(partitions int) *MemoryBroker {
+	if partitions < 1 {
+		partitions = 1
+	}
+	b := &MemoryBroker{
+		partitions: partitions,
+		logs:       make(map[string][][]Message),
+		committed:  make(map[topicPartition]int64),
+	}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+func (b *MemoryBroker) topicLog(topic string) [][]Message {
+	l, ok := b.logs[topic]
+	if !ok {
+		l = make([][]Message, b.partitions)
+		b.logs[topic] = l
+	}
+	return l
+}
+
+// Produce appends msg to its topic. Messages with a key are partitioned by
+// its hash, others round-robin.
+func (b *MemoryBroker) Produce(msg Message) error {
+	if msg.Topic == "" {
+		return ErrNoTopic
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := b.next % b.partitions
+	if len(msg.Key) > 0 {
+		h := fnv.New32a()
+		h.Write(msg.Key)
+		p = int(h.Sum32() % uint32(b.partitions))
+	} else {
+		b.next++
+	}
+
+	l := b.topicLog(msg.Topic)
+	msg.Partition = p
+	msg.Offset = int64(len(l[p]))
+	l[p] = append(l[p], msg)
+	b.cond.Broadcast()
+	return nil
+}
+
+// Messages returns everything written to topic, partition by partition.
+func (b *MemoryBroker) Messages(topic string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var out []Message
+	for _, p := range b.topicLog(topic) {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// Committed returns the offset the group would resume partition from.
+func (b *MemoryBroker) Committed(topic string, partition int) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.committed[topicPartition{topic, partition}]
+}
+
+// Source subscribes to the topics, starting from the committed offsets.
+func (b *MemoryBroker) Source(topics ...string) MessageSource {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pos := make(map[string][]int64, len(topics))
+	for _, topic := range topics {
+		pos[topic] = make([]int64, b.partitions)
+		for p := range pos[topic] {
+			pos[topic][p] = b.committed[topicPartition{topic, p}]
+		}
+	}
+	return &memorySource{b: b, topics: topics, pos: pos, done: make(chan struct{})}
+}
+
+func (b *MemoryBroker) Sink() MessageSink {
+	return memorySink{b: b}
+}
+
+type memorySource struct {
+	b      *MemoryBroker
+	topics []string
+	pos    map[string][]int64
+	next   int
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// FetchMessage hands out messages round-robin across the partitions of all
+// topics and blocks until one is available, ctx is done or the source is
+// closed.
+func (s *memorySource) FetchMessage(ctx context.Context) (Message, error) {
+	wake := func() {
+		s.b.mu.Lock()
+		s.b.cond.Broadcast()
+		s.b.mu.Unlock()
+	}
+	stop := context.AfterFunc(ctx, wake)
+	defer stop()
+
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			return Message{}, err
+		}
+		select {
+		case <-s.done:
+			return Message{}, ErrClosed
+		default:
+		}
+
+		n := len(s.topics) * s.b.partitions
+		for i := 0; i < n; i++ {
+			slot := (s.next + i) % n
+			topic, p := s.topics[slot/s.b.partitions], slot%s.b.partitions
+			l, pos := s.b.topicLog(topic), s.pos[topic]
+			if pos[p] < int64(len(l[p])) {
+				msg := l[p][pos[p]]
+				pos[p]++
+				s.next = slot + 1
+				return msg, nil
+			}
+		}
+		s.b.cond.Wait()
+	}
+}
+
+func (s *memorySource) CommitMessages(ctx context.Context, msgs ...Message) error {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+
+	for _, m := range msgs {
+		tp := topicPartition{m.Topic, m.Partition}
+		if m.Offset+1 > s.b.committed[tp] {
+			s.b.committed[tp] = m.Offset + 1
+		}
+	}
+	return nil
+}
+
+func (s *memorySource) Lag() int64 {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+
+	var lag int64
+	for _, topic := range s.topics {
+		for p, msgs := range s.b.topicLog(topic) {
+			lag += int64(len(msgs)) - s.pos[topic][p]
+		}
+	}
+	return lag
+}
+
+func (s *memorySource) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *memorySource) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.b.mu.Lock()
+		s.b.cond.Broadcast()
+		s.b.mu.Unlock()
+	})
+	return nil
+}
+
+type memorySink struct {
+	b *MemoryBroker
+}
+
+func (s memorySink) WriteMessages(ctx context.Context, msgs ...Message) error {
+	for _, m := range msgs {
+		if err := s.b.Produce(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s memorySink) Close() error {
+	return nil
+}
@@ -0,0 +1,181 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sync"
+)
+
+type Ordering string
+
+const (
+	OrderByKey       Ordering = "key"
+	OrderByPartition Ordering = "partition"
+)
+
+const workerQueueSize = 16
+
+func ParseOrdering(s string) (Ordering, error) {
+	switch Ordering(s) {
+	case OrderByKey, OrderByPartition:
+		return Ordering(s), nil
+	default:
+		return "", fmt.Errorf("kafka: unknown ordering %q (want key or partition)", s)
+	}
+}
+
+func (c *Consumer) consumeParallel(ctx context.Context, handler Handler) error {
+	log.Printf("kafka: consumer started with %d workers, ordered by %s", c.workers, c.ordering)
+
+	procCtx := context.WithoutCancel(ctx)
+	tracker := newOffsetTracker()
+	if n, ok := c.source.(revokeNotifier); ok {
+		n.onRevoked(tracker.revoke)
+		defer n.onRevoked(nil)
+	}
+	completed := make(chan Message, c.workers*workerQueueSize)
+
+	queues := make([]chan Message, c.workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan Message, workerQueueSize)
+		wg.Add(1)
+		go func(q <-chan Message) {
+			defer wg.Done()
+			for msg := range q {
+				if err := c.handle(procCtx, ctx, msg, handler); err != nil {
+					// Never completed, so the tracker commits nothing
+					// past it and it is redelivered after restart.
+					log.Printf("kafka: %s partition %d offset %d left uncommitted: %v", msg.Topic, msg.Partition, msg.Offset, err)
+					continue
+				}
+				completed <- msg
+			}
+		}(queues[i])
+	}
+
+	commitDone := make(chan struct{})
+	go func() {
+		defer close(commitDone)
+		for msg := range completed {
+			toCommit, ok := tracker.complete(msg)
+			if !ok {
+				continue
+			}
+			if err := c.source.CommitMessages(procCtx, toCommit); err != nil {
+				log.Printf("kafka: failed to commit %s partition %d offset %d: %v", toCommit.Topic, toCommit.Partition, toCommit.Offset, err)
+			}
+		}
+	}()
+
+	defer func() {
+		for _, q := range queues {
+			close(q)
+		}
+		wg.Wait()
+		close(completed)
+		<-commitDone
+		log.Println("kafka: workers drained")
+	}()
+
+	for {
+		msg, err := c.source.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Println("kafka: context canceled, stopping consumer loop")
+				return nil
+			}
+			log.Printf("kafka: fetch error: %v\n", err)
+			continue
+		}
+
+		tracker.track(msg)
+
+		select {
+		case queues[c.workerFor(msg)] <- msg:
+		case <-ctx.Done():
+			// Left pending in the tracker, so nothing past it gets committed
+			// and it is redelivered after restart.
+			log.Println("kafka: context canceled, stopping consumer loop")
+			return nil
+		}
+	}
+}
+
+func (c *Consumer) workerFor(msg Message) int {
+	if c.ordering == OrderByKey && len(msg.Key) > 0 {
+		h := fnv.New32a()
+		h.Write(msg.Key)
+		return int(h.Sum32() % uint32(c.workers))
+	}
+	return msg.Partition % c.workers
+}
+
+// offsetTracker keeps fetched-but-uncommitted offsets per topic and
+// partition and reports the highest offset below which every message has
+// completed.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[topicPartition]*partitionOffsets
+}
+
+type partitionOffsets struct {
+	pending []Message
+	done    map[int64]bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[topicPartition]*partitionOffsets)}
+}
+
+func (t *offsetTracker) track(msg Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tp := topicPartition{msg.Topic, msg.Partition}
+	p, ok := t.partitions[tp]
+	if !ok {
+		p = &partitionOffsets{done: make(map[int64]bool)}
+		t.partitions[tp] = p
+	}
+	p.pending = append(p.pending, msg)
+}
+
+// complete marks msg done and returns the last message of the contiguous
+// completed prefix of its partition, if that prefix grew.
+func (t *offsetTracker) complete(msg Message) (Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[topicPartition{msg.Topic, msg.Partition}]
+	if !ok {
+		return Message{}, false
+	}
+	p.done[msg.Offset] = true
+
+	var (
+		last     Message
+		advanced bool
+	)
+	for len(p.pending) > 0 && p.done[p.pending[0].Offset] {
+		last = p.pending[0]
+		delete(p.done, last.Offset)
+		p.pending = p.pending[1:]
+		advanced = true
+	}
+	return last, advanced
+}
+
+// revoke drops the state of partitions this member no longer owns. Messages
+// of them still in flight complete without a commit; the new owner resumes
+// from the last committed offset.
+func (t *offsetTracker) revoke(revoked []topicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tp := range revoked {
+		delete(t.partitions, tp)
+	}
+}
@@ -0,0 +1,85 @@
+package consumer
+
+import "testing"
+
+func TestOffsetTracker_CommitsHighestContiguous(t *testing.T) {
+	tr := newOffsetTracker()
+	msgs := []Message{
+		{Partition: 0, Offset: 10},
+		{Partition: 0, Offset: 11},
+		{Partition: 0, Offset: 12},
+		{Partition: 1, Offset: 5},
+	}
+	for _, m := range msgs {
+		tr.track(m)
+	}
+
+	if _, ok := tr.complete(msgs[2]); ok {
+		t.Fatalf("offset 12 must not be committed before 10 and 11")
+	}
+	if got, ok := tr.complete(msgs[3]); !ok || got.Partition != 1 || got.Offset != 5 {
+		t.Fatalf("expected partition 1 offset 5, got %+v ok=%v", got, ok)
+	}
+	if got, ok := tr.complete(msgs[0]); !ok || got.Offset != 10 {
+		t.Fatalf("expected offset 10, got %+v ok=%v", got, ok)
+	}
+	if got, ok := tr.complete(msgs[1]); !ok || got.Offset != 12 {
+		t.Fatalf("expected offset 12 after gap closed, got %+v ok=%v", got, ok)
+	}
+}
+
+func TestOffsetTracker_KeepsTopicsApart(t *testing.T) {
+	tr := newOffsetTracker()
+	order := Message{Topic: "orders", Partition: 0, Offset: 7}
+	retry := Message{Topic: "orders_retry", Partition: 0, Offset: 3}
+	tr.track(order)
+	tr.track(retry)
+
+	if got, ok := tr.complete(retry); !ok || got.Topic != "orders_retry" || got.Offset != 3 {
+		t.Fatalf("expected orders_retry offset 3, got %+v ok=%v", got, ok)
+	}
+	if got, ok := tr.complete(order); !ok || got.Topic != "orders" || got.Offset != 7 {
+		t.Fatalf("expected orders offset 7, got %+v ok=%v", got, ok)
+	}
+}
+
+func TestOffsetTracker_RevokeDropsPartition(t *testing.T) {
+	tr := newOffsetTracker()
+	msgs := []Message{
+		{Topic: "orders", Partition: 0, Offset: 10},
+		{Topic: "orders", Partition: 0, Offset: 11},
+		{Topic: "orders", Partition: 1, Offset: 4},
+	}
+	for _, m := range msgs {
+		tr.track(m)
+	}
+
+	tr.revoke([]topicPartition{{"orders", 0}})
+	if _, ok := tr.complete(msgs[1]); ok {
+		t.Fatalf("a revoked partition must not be committed")
+	}
+	if got, ok := tr.complete(msgs[2]); !ok || got.Offset != 4 {
+		t.Fatalf("expected partition 1 offset 4, got %+v ok=%v", got, ok)
+	}
+
+	// Reassigned later, the partition resumes from the committed offset.
+	tr.track(msgs[0])
+	if got, ok := tr.complete(msgs[0]); !ok || got.Offset != 10 {
+		t.Fatalf("expected offset 10 after reassignment, got %+v ok=%v", got, ok)
+	}
+}
+
+func TestWorkerFor_SameKeySameWorker(t *testing.T) {
+	c := NewConsumer(nil, nil, WithWorkers(8, OrderByKey))
+
+	a := c.workerFor(Message{Key: []byte("order-1"), Partition: 0})
+	b := c.workerFor(Message{Key: []byte("order-1"), Partition: 3})
+	if a != b {
+		t.Fatalf("same key routed to different workers: %d vs %d", a, b)
+	}
+
+	c = NewConsumer(nil, nil, WithWorkers(4, OrderByPartition))
+	if w := c.workerFor(Message{Key: []byte("x"), Partition: 6}); w != 2 {
+		t.Fatalf("expected worker 2 for partition 6, got %d", w)
+	}
+}
@@ -0,0 +1,98 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type segmentioSource struct {
+	r *kafka.Reader
+}
+
+func NewSegmentioSource(r *kafka.Reader) MessageSource {
+	return &segmentioSource{r: r}
+}
+
+func (s *segmentioSource) FetchMessage(ctx context.Context) (Message, error) {
+	msg, err := s.r.FetchMessage(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+	return fromKafka(msg), nil
+}
+
+func (s *segmentioSource) CommitMessages(ctx context.Context, msgs ...Message) error {
+	kmsgs := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		kmsgs[i] = kafka.Message{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset}
+	}
+	return s.r.CommitMessages(ctx, kmsgs...)
+}
+
+func (s *segmentioSource) Lag() int64 {
+	return s.r.Stats().Lag
+}
+
+func (s *segmentioSource) Ping(ctx context.Context) error {
+	var lastErr error
+	for _, broker := range s.r.Config().Brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		conn.Close()
+		return nil
+	}
+	return fmt.Errorf("kafka: no reachable brokers: %w", lastErr)
+}
+
+func (s *segmentioSource) Close() error {
+	return s.r.Close()
+}
+
+type segmentioSink struct {
+	w *kafka.Writer
+}
+
+// NewSegmentioSink wraps w, which must not have a topic of its own.
+func NewSegmentioSink(w *kafka.Writer) MessageSink {
+	return &segmentioSink{w: w}
+}
+
+func (s *segmentioSink) WriteMessages(ctx context.Context, msgs ...Message) error {
+	kmsgs := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		kmsgs[i] = toKafka(m)
+	}
+	return s.w.WriteMessages(ctx, kmsgs...)
+}
+
+func (s *segmentioSink) Close() error {
+	return s.w.Close()
+}
+
+func fromKafka(msg kafka.Message) Message {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	return Message{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   headers,
+	}
+}
+
+func toKafka(msg Message) kafka.Message {
+	headers := make([]kafka.Header, 0, len(msg.Headers))
+	for k, v := range msg.Headers {
+		headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	return kafka.Message{Topic: msg.Topic, Key: msg.Key, Value: msg.Value, Headers: headers}
+}
@@ -0,0 +1,33 @@
+package consumer
+
+import "context"
+
+// MessageSource is a consumer-group subscription. Messages of a partition are
+// fetched in offset order; committing a message marks it and everything before
+// it in the same partition as processed.
+type MessageSource interface {
+	FetchMessage(ctx context.Context) (Message, error)
+	CommitMessages(ctx context.Context, msgs ...Message) error
+	// Lag returns how many messages are behind the last fetched one, or -1
+	// when unknown.
+	Lag() int64
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+// revokeNotifier is implemented by sources that learn when a group rebalance
+// takes partitions away, so the consumer can drop what it tracks for them.
+type revokeNotifier interface {
+	onRevoked(fn func(revoked []topicPartition))
+}
+
+// MessageSink publishes messages to the topic set on each of them.
+type MessageSink interface {
+	WriteMessages(ctx context.Context, msgs ...Message) error
+	Close() error
+}
@@ -1,6 +1,9 @@
 package generator
 
 import (
+	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"orderservice/internal/model"
@@ -8,64 +11,246 @@ import (
 	"github.com/brianvoe/gofakeit/v7"
 )
 
-func init() {
-	gofakeit.Seed(time.Now().UnixNano())
+const maxKnownCustomers = 10000
+
+type Options struct {
+	// Seed makes the output reproducible; 0 picks a random seed. Full
+	// reproducibility also needs a fixed From/To range.
+	Seed uint64
+
+	// ItemCounts maps a number of items per order to its relative weight.
+	ItemCounts map[int]float64
+
+	Currencies []string
+	Locales    []string
+
+	// DateCreated is drawn uniformly from [From, To].
+	From time.Time
+	To   time.Time
+
+	// CustomerReuse is the probability that an order belongs to a customer
+	// generated earlier instead of a new one.
+	CustomerReuse float64
+
+	// Consistent keeps the financial invariants (item totals, goods total,
+	// amount) and clamps dates so that every order passes Order.Validate.
+	Consistent bool
 }
 
-func RandomOrder() *model.Order {
-	orderUID := gofakeit.UUID()
-	trackNumber := gofakeit.LetterN(2) + gofakeit.DigitN(10)
-	customerID := gofakeit.Username()
-	chrtID := gofakeit.Number(1000000, 9999999)
-	itemRID := gofakeit.UUID()
+func DefaultOptions() Options {
+	now := time.Now().UTC()
+	return Options{
+		ItemCounts: map[int]float64{1: 6, 2: 3, 3: 1},
+		Currencies: []string{"RUB", "USD", "EUR", "KZT", "BYN"},
+		Locales:    []string{"ru", "en"},
+		From:       now.AddDate(0, 0, -30),
+		To:         now,
+		Consistent: true,
+	}
+}
+
+type Generator struct {
+	mu        sync.Mutex
+	f         *gofakeit.Faker
+	opts      Options
+	counts    []int
+	weights   []float64
+	total     float64
+	customers []string
+}
+
+func New(opts Options) (*Generator, error) {
+	def := DefaultOptions()
+	if len(opts.ItemCounts) == 0 {
+		opts.ItemCounts = def.ItemCounts
+	}
+	if len(opts.Currencies) == 0 {
+		opts.Currencies = def.Currencies
+	}
+	if len(opts.Locales) == 0 {
+		opts.Locales = def.Locales
+	}
+	if opts.From.IsZero() {
+		opts.From = def.From
+	}
+	if opts.To.IsZero() {
+		opts.To = def.To
+	}
+	if opts.To.Before(opts.From) {
+		return nil, fmt.Errorf("generator: date range end %v is before start %v", opts.To, opts.From)
+	}
+	if opts.CustomerReuse < 0 || opts.CustomerReuse > 1 {
+		return nil, fmt.Errorf("generator: customer reuse ratio must be within [0, 1], got %v", opts.CustomerReuse)
+	}
+	if opts.Consistent {
+		now := time.Now()
+		oldest := now.AddDate(-10, 0, 0).Add(time.Hour)
+		if opts.From.Before(oldest) {
+			opts.From = oldest
+		}
+		if opts.To.After(now) {
+			opts.To = now
+		}
+		if opts.To.Before(opts.From) {
+			return nil, fmt.Errorf("generator: date range is outside of what Order.Validate accepts")
+		}
+	}
+
+	g := &Generator{f: gofakeit.New(opts.Seed), opts: opts}
+	for n := range opts.ItemCounts {
+		if n < 1 || opts.ItemCounts[n] < 0 {
+			return nil, fmt.Errorf("generator: invalid item count weight %d: %v", n, opts.ItemCounts[n])
+		}
+		g.counts = append(g.counts, n)
+	}
+	sort.Ints(g.counts)
+	for _, n := range g.counts {
+		g.total += opts.ItemCounts[n]
+		g.weights = append(g.weights, g.total)
+	}
+	if g.total <= 0 {
+		return nil, fmt.Errorf("generator: item count weights sum to zero")
+	}
+	return g, nil
+}
+
+func (g *Generator) Order() *model.Order {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f := g.f
+	orderUID := f.UUID()
+	trackNumber := f.LetterN(2) + f.DigitN(10)
+	dateCreated := g.date()
+
+	items := make([]model.Item, g.itemCount())
+	var goodsTotal int64
+	for i := range items {
+		items[i] = g.item(trackNumber)
+		goodsTotal += items[i].TotalPrice
+	}
+
+	payment := model.Payment{
+		Transaction:  orderUID,
+		RequestID:    "",
+		Currency:     f.RandomString(g.opts.Currencies),
+		Provider:     f.RandomString([]string{"wbpay", "sbp", "card"}),
+		PaymentDt:    dateCreated.Unix(),
+		Bank:         f.BankName(),
+		DeliveryCost: g.number64(0, 2000),
+		CustomFee:    g.number64(0, 100),
+	}
+	if g.opts.Consistent {
+		payment.GoodsTotal = goodsTotal
+		payment.Amount = goodsTotal + payment.DeliveryCost + payment.CustomFee
+	} else {
+		payment.GoodsTotal = g.number64(100, 3000)
+		payment.Amount = g.number64(100, 5000)
+	}
 
 	return &model.Order{
 		OrderUID:    orderUID,
 		TrackNumber: trackNumber,
-		Entry:       gofakeit.LetterN(4),
+		Entry:       "WBIL",
 		Delivery: model.Delivery{
-			Name:    gofakeit.Name(),
-			Phone:   gofakeit.Phone(),
-			Zip:     gofakeit.Zip(),
-			City:    gofakeit.City(),
-			Address: gofakeit.Street(),
-			Region:  gofakeit.State(),
-			Email:   gofakeit.Email(),
-		},
-		Payment: model.Payment{
-			Transaction:  gofakeit.UUID(),
-			RequestID:    gofakeit.UUID(),
-			Currency:     gofakeit.CurrencyShort(),
-			Provider:     gofakeit.Company(),
-			Amount:       gofakeit.Number(100, 5000),
-			PaymentDt:    int(time.Now().Unix()),
-			Bank:         gofakeit.BankName(),
-			DeliveryCost: gofakeit.Number(500, 2000),
-			GoodsTotal:   gofakeit.Number(100, 3000),
-			CustomFee:    gofakeit.Number(0, 100),
+			Name:    f.Name(),
+			Phone:   f.Phone(),
+			Zip:     f.Zip(),
+			City:    f.City(),
+			Address: f.Street(),
+			Region:  f.State(),
+			Email:   f.Email(),
 		},
-		Items: []model.Item{
-			{
-				ChrtID:      chrtID,
-				TrackNumber: trackNumber,
-				Price:       gofakeit.Number(50, 500),
-				RID:         itemRID,
-				Name:        gofakeit.ProductName(),
-				Sale:        gofakeit.Number(0, 50),
-				Size:        gofakeit.DigitN(1),
-				TotalPrice:  gofakeit.Number(50, 500),
-				NMID:        gofakeit.Number(1000000, 9999999),
-				Brand:       gofakeit.Company(),
-				Status:      202,
-			},
-		},
-		Locale:            "en",
+		Payment:           payment,
+		Items:             items,
+		Locale:            f.RandomString(g.opts.Locales),
 		InternalSignature: "",
-		CustomerID:        customerID,
-		DeliveryService:   gofakeit.Company(),
-		ShardKey:          gofakeit.DigitN(1),
-		SMID:              gofakeit.Number(1, 100),
-		DateCreated:       time.Now().UTC(),
-		OOFShard:          gofakeit.DigitN(1),
+		CustomerID:        g.customer(),
+		DeliveryService:   f.RandomString([]string{"meest", "cdek", "wb", "boxberry"}),
+		ShardKey:          f.DigitN(1),
+		SMID:              f.Number(1, 100),
+		DateCreated:       dateCreated,
+		OOFShard:          f.DigitN(1),
 	}
 }
+
+func (g *Generator) item(trackNumber string) model.Item {
+	f := g.f
+	price := g.number64(50, 5000)
+
+	var sale, total int64
+	if g.opts.Consistent {
+		sale = g.number64(0, int(price*9/10))
+		total = price - sale
+	} else {
+		sale = g.number64(0, 50)
+		total = g.number64(50, 500)
+	}
+
+	return model.Item{
+		ChrtID:      g.number64(1000000, 9999999),
+		TrackNumber: trackNumber,
+		Price:       price,
+		RID:         f.UUID(),
+		Name:        f.ProductName(),
+		Sale:        sale,
+		Size:        f.DigitN(1),
+		TotalPrice:  total,
+		NMID:        g.number64(1000000, 9999999),
+		Brand:       f.Company(),
+		Status:      model.ItemAccepted,
+	}
+}
+
+// number64 is f.Number for the int64 money and ID fields.
+func (g *Generator) number64(lo, hi int) int64 {
+	return int64(g.f.Number(lo, hi))
+}
+
+func (g *Generator) itemCount() int {
+	r := g.f.Float64() * g.total
+	for i, w := range g.weights {
+		if r < w {
+			return g.counts[i]
+		}
+	}
+	return g.counts[len(g.counts)-1]
+}
+
+func (g *Generator) date() time.Time {
+	span := g.opts.To.Sub(g.opts.From)
+	if span <= 0 {
+		return g.opts.From.UTC()
+	}
+	offset := time.Duration(g.f.Float64() * float64(span))
+	return g.opts.From.Add(offset).UTC().Truncate(time.Second)
+}
+
+func (g *Generator) customer() string {
+	if len(g.customers) > 0 && g.f.Float64() < g.opts.CustomerReuse {
+		return g.customers[g.f.IntN(len(g.customers))]
+	}
+	id := g.f.Username() + g.f.DigitN(4)
+	if len(g.customers) < maxKnownCustomers {
+		g.customers = append(g.customers, id)
+	}
+	return id
+}
+
+var (
+	defaultOnce sync.Once
+	defaultGen  *Generator
+)
+
+// RandomOrder returns a valid order from a randomly seeded generator with
+// default options.
+func RandomOrder() *model.Order {
+	defaultOnce.Do(func() {
+		gen, err := New(DefaultOptions())
+		if err != nil {
+			panic(err)
+		}
+		defaultGen = gen
+	})
+	return defaultGen.Order()
+}
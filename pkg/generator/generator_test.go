@@ -0,0 +1,80 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func fixedOptions(seed uint64) Options {
+	opts := DefaultOptions()
+	opts.Seed = seed
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	opts.From = day.AddDate(0, 0, -60)
+	opts.To = day.AddDate(0, 0, -1)
+	return opts
+}
+
+func TestGenerator_Deterministic(t *testing.T) {
+	a, err := New(fixedOptions(42))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	b, _ := New(fixedOptions(42))
+
+	for i := 0; i < 20; i++ {
+		if oa, ob := a.Order(), b.Order(); !reflect.DeepEqual(oa, ob) {
+			t.Fatalf("order %d differs for the same seed:\n%+v\n%+v", i, oa, ob)
+		}
+	}
+}
+
+func TestGenerator_ConsistentOrdersAreValid(t *testing.T) {
+	opts := fixedOptions(7)
+	opts.ItemCounts = map[int]float64{1: 1, 3: 1, 5: 1}
+	g, err := New(opts)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	for i := 0; i < 500; i++ {
+		o := g.Order()
+		if err := o.Validate(); err != nil {
+			t.Fatalf("order %d invalid: %v", i, err)
+		}
+
+		var goods int64
+		for _, it := range o.Items {
+			if it.TotalPrice != it.Price-it.Sale {
+				t.Fatalf("order %d: item total %d != price %d - sale %d", i, it.TotalPrice, it.Price, it.Sale)
+			}
+			goods += it.TotalPrice
+		}
+		if n := len(o.Items); n != 1 && n != 3 && n != 5 {
+			t.Fatalf("order %d: unexpected item count %d", i, n)
+		}
+		if o.Payment.GoodsTotal != goods {
+			t.Fatalf("order %d: goods_total %d != sum of items %d", i, o.Payment.GoodsTotal, goods)
+		}
+		if o.Payment.Amount != goods+o.Payment.DeliveryCost+o.Payment.CustomFee {
+			t.Fatalf("order %d: amount %d is not goods + delivery + fee", i, o.Payment.Amount)
+		}
+		if o.DateCreated.Before(opts.From) || o.DateCreated.After(opts.To) {
+			t.Fatalf("order %d: date %v out of range", i, o.DateCreated)
+		}
+	}
+}
+
+func TestGenerator_CustomerReuse(t *testing.T) {
+	opts := fixedOptions(1)
+	opts.CustomerReuse = 0.9
+	g, _ := New(opts)
+
+	seen := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		seen[g.Order().CustomerID] = true
+	}
+	if len(seen) > 60 {
+		t.Fatalf("expected customers to be reused, got %d distinct of 200", len(seen))
+	}
+}
@@ -0,0 +1,194 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Client interface {
+	GetSchema(ctx context.Context, id int) (string, error)
+	Register(ctx context.Context, subject, schema string) (int, error)
+}
+
+// New returns an HTTP client for http(s) URLs and a file-based stand-in for
+// file:// URLs or plain directory paths.
+func New(rawURL string) (Client, error) {
+	if rawURL == "" {
+		return nil, fmt.Errorf("schemaregistry: empty url")
+	}
+	if strings.HasPrefix(rawURL, "http://") || strings.HasPrefix(rawURL, "https://") {
+		return NewHTTPClient(rawURL), nil
+	}
+	return NewFileRegistry(strings.TrimPrefix(rawURL, "file://"))
+}
+
+type httpClient struct {
+	baseURL string
+	http    *http.Client
+
+	mu    sync.RWMutex
+	cache map[int]string
+}
+
+// NewHTTPClient talks to a Confluent-compatible schema registry REST API.
+func NewHTTPClient(baseURL string) Client {
+	return &httpClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 5 * time.Second},
+		cache:   make(map[int]string),
+	}
+}
+
+func (c *httpClient) GetSchema(ctx context.Context, id int) (string, error) {
+	c.mu.RLock()
+	schema, ok := c.cache[id]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	var resp struct {
+		Schema string `json:"schema"`
+	}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &resp); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.cache[id] = resp.Schema
+	c.mu.Unlock()
+	return resp.Schema, nil
+}
+
+func (c *httpClient) Register(ctx context.Context, subject, schema string) (int, error) {
+	body, err := json.Marshal(map[string]string{"schema": schema})
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		ID int `json:"id"`
+	}
+	path := "/subjects/" + url.PathEscape(subject) + "/versions"
+	if err := c.do(ctx, http.MethodPost, path, body, &resp); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	c.cache[resp.ID] = schema
+	c.mu.Unlock()
+	return resp.ID, nil
+}
+
+func (c *httpClient) do(ctx context.Context, method, path string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("schemaregistry: %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("schemaregistry: %s %s: unexpected status %d", method, path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("schemaregistry: decode response: %w", err)
+	}
+	return nil
+}
+
+// fileRegistry is a local stand-in that keeps every schema in <dir>/<id>.avsc.
+// Subjects are not tracked: registering a schema that is already stored
+// returns its existing ID.
+type fileRegistry struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewFileRegistry(dir string) (Client, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("schemaregistry: create dir: %w", err)
+	}
+	return &fileRegistry{dir: dir}, nil
+}
+
+func (f *fileRegistry) GetSchema(ctx context.Context, id int) (string, error) {
+	data, err := os.ReadFile(filepath.Join(f.dir, strconv.Itoa(id)+".avsc"))
+	if err != nil {
+		return "", fmt.Errorf("schemaregistry: schema %d: %w", id, err)
+	}
+	return string(data), nil
+}
+
+func (f *fileRegistry) Register(ctx context.Context, subject, schema string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ids, err := f.ids()
+	if err != nil {
+		return 0, err
+	}
+
+	want := compact(schema)
+	for _, id := range ids {
+		existing, err := f.GetSchema(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		if compact(existing) == want {
+			return id, nil
+		}
+	}
+
+	next := 1
+	if len(ids) > 0 {
+		next = ids[len(ids)-1] + 1
+	}
+	if err := os.WriteFile(filepath.Join(f.dir, strconv.Itoa(next)+".avsc"), []byte(schema), 0o644); err != nil {
+		return 0, fmt.Errorf("schemaregistry: write schema: %w", err)
+	}
+	return next, nil
+}
+
+func (f *fileRegistry) ids() ([]int, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, fmt.Errorf("schemaregistry: read dir: %w", err)
+	}
+	var ids []int
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".avsc")
+		if !ok {
+			continue
+		}
+		if id, err := strconv.Atoi(name); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func compact(schema string) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(schema)); err != nil {
+		return schema
+	}
+	return buf.String()
+}
@@ -0,0 +1,117 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testSchema = `{"type":"record","name":"Order","fields":[]}`
+
+func TestHTTPClient_GetSchemaCaches(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Method != http.MethodGet || r.URL.Path != "/schemas/ids/7" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"schema": testSchema})
+	}))
+	defer srv.Close()
+
+	c := NewHTTPClient(srv.URL + "/")
+	for range 3 {
+		got, err := c.GetSchema(context.Background(), 7)
+		if err != nil {
+			t.Fatalf("get schema: %v", err)
+		}
+		if got != testSchema {
+			t.Fatalf("expected %s, got %s", testSchema, got)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected a single request, got %d", n)
+	}
+}
+
+func TestHTTPClient_RegisterCachesSchema(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Method != http.MethodPost || r.URL.EscapedPath() != "/subjects/orders%2Fvalue/versions" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.EscapedPath())
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/vnd.schemaregistry.v1+json" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		var body struct {
+			Schema string `json:"schema"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Schema != testSchema {
+			t.Errorf("unexpected body %+v, %v", body, err)
+		}
+		json.NewEncoder(w).Encode(map[string]int{"id": 12})
+	}))
+	defer srv.Close()
+
+	c := NewHTTPClient(srv.URL)
+	id, err := c.Register(context.Background(), "orders/value", testSchema)
+	if err != nil || id != 12 {
+		t.Fatalf("expected id 12, got %d, %v", id, err)
+	}
+	// The registered schema is served from the cache.
+	got, err := c.GetSchema(context.Background(), 12)
+	if err != nil || got != testSchema {
+		t.Fatalf("expected the cached schema, got %q, %v", got, err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected a single request, got %d", n)
+	}
+}
+
+func TestHTTPClient_ErrorStatus(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/schemas/ids/1":
+			http.Error(w, `{"error_code":40403,"message":"Schema not found"}`, http.StatusNotFound)
+		case "/schemas/ids/2":
+			w.Write([]byte("not json"))
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewHTTPClient(srv.URL)
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name string
+		call func() error
+		want string
+	}{
+		{"not found", func() error { _, err := c.GetSchema(ctx, 1); return err }, "unexpected status 404"},
+		{"bad body", func() error { _, err := c.GetSchema(ctx, 2); return err }, "decode response"},
+		{"register", func() error { _, err := c.Register(ctx, "orders-value", testSchema); return err }, "unexpected status 500"},
+	} {
+		err := tc.call()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tc.name, tc.want, err)
+		}
+	}
+
+	// Failures are not cached.
+	if _, err := c.GetSchema(ctx, 1); err == nil {
+		t.Fatal("expected the lookup to fail again")
+	}
+	if n := calls.Load(); n != 4 {
+		t.Fatalf("expected 4 requests, got %d", n)
+	}
+}